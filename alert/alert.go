@@ -0,0 +1,98 @@
+// Package alert notifies operators of the feeder about conditions which
+// need a human, like the pricing key losing its operator role.
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+)
+
+// levels of alerts
+const (
+	INFO     string = "info"
+	WARNING  string = "warning"
+	CRITICAL string = "critical"
+
+	WEBHOOK_TIMEOUT time.Duration = 10 * time.Second
+)
+
+// Alerter sends an alert. Alerting is best effort, failures are logged
+// by the alerter rather than returned.
+type Alerter interface {
+	Alert(level string, message string)
+}
+
+// LogAlerter writes alerts to the log
+type LogAlerter struct{}
+
+func (self LogAlerter) Alert(level string, message string) {
+	logger := logging.Std()
+	switch level {
+	case INFO:
+		logger.Infof("ALERT [%s]: %s", level, message)
+	case WARNING:
+		logger.Warnf("ALERT [%s]: %s", level, message)
+	default:
+		logger.Errorf("ALERT [%s]: %s", level, message)
+	}
+}
+
+// WebhookAlerter posts alerts as json {"text": "..."} to a webhook, the
+// format of slack and mattermost incoming webhooks
+type WebhookAlerter struct {
+	url    string
+	client *http.Client
+}
+
+func (self *WebhookAlerter) send(text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	resp, err := self.client.Post(self.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New(fmt.Sprintf("webhook answered %s", resp.Status))
+	}
+	return nil
+}
+
+func (self *WebhookAlerter) Alert(level string, message string) {
+	if err := self.send(fmt.Sprintf("[%s] %s", level, message)); err != nil {
+		logging.Std().Warnf("Sending alert to webhook failed: %s", err)
+	}
+}
+
+func NewWebhookAlerter(url string) *WebhookAlerter {
+	return &WebhookAlerter{
+		url:    url,
+		client: &http.Client{Timeout: WEBHOOK_TIMEOUT},
+	}
+}
+
+// MultiAlerter sends every alert to all its alerters
+type MultiAlerter []Alerter
+
+func (self MultiAlerter) Alert(level string, message string) {
+	for _, alerter := range self {
+		alerter.Alert(level, message)
+	}
+}
+
+// NewAlerter logs alerts and also posts them to webhook if it is not
+// empty
+func NewAlerter(webhook string) Alerter {
+	if webhook == "" {
+		return LogAlerter{}
+	}
+	return MultiAlerter{LogAlerter{}, NewWebhookAlerter(webhook)}
+}
@@ -0,0 +1,249 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/KyberNetwork/dgx-price-feeder"
+	"github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/KyberNetwork/dgx-price-feeder/feed"
+	"github.com/KyberNetwork/dgx-price-feeder/history"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// EndpointMonitor reports the health of the rpc endpoints
+type EndpointMonitor interface {
+	EndpointsHealth() []blockchain.EndpointHealth
+}
+
+// HistoryReader queries the history of feeds and txs
+type HistoryReader interface {
+	Feeds(filter history.Filter) ([]history.Feed, error)
+	Txs(filter history.Filter) ([]history.Tx, error)
+}
+
+// Server exposes admin operations of a running feeder over http
+type Server struct {
+	feeder    *dgxpricing.PriceFeeder
+	endpoints EndpointMonitor
+	// history is nil when the feeder doesn't keep one
+	history HistoryReader
+	// signer manual feeds must be signed by, see feed.FeedCorpus
+	signer ethereum.Address
+	// token is the bearer token of the mutating routes, they are
+	// refused when it is empty
+	token []byte
+	addr  string
+	mux   *http.ServeMux
+}
+
+func (self *Server) writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logging.Std().Warnf("Writing response failed: %s", err)
+	}
+}
+
+func (self *Server) success(w http.ResponseWriter, data interface{}) {
+	self.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"data":    data,
+	})
+}
+
+func (self *Server) fail(w http.ResponseWriter, code int, reason string) {
+	self.writeJSON(w, code, map[string]interface{}{
+		"success": false,
+		"reason":  reason,
+	})
+}
+
+// authorized fails the request unless it is a POST carrying the bearer
+// token of the server
+func (self *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		self.fail(w, http.StatusMethodNotAllowed, "only POST is allowed")
+		return false
+	}
+	if len(self.token) == 0 {
+		self.fail(w, http.StatusForbidden, "mutating routes are disabled without -api-token")
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), self.token) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		self.fail(w, http.StatusUnauthorized, "invalid bearer token")
+		return false
+	}
+	return true
+}
+
+// Cancel sends a 0 ETH self transfer at the `nonce` form value, or at the
+// lowest journaled pending nonce if it is not set. It waits for a running
+// cycle and is refused with 409 when the instance is not the leader. The
+// cancel tx is monitored in background.
+func (self *Server) Cancel(w http.ResponseWriter, r *http.Request) {
+	if !self.authorized(w, r) {
+		return
+	}
+	var txNonce *big.Int
+	if str := r.FormValue("nonce"); str != "" {
+		n, ok := big.NewInt(0).SetString(str, 0)
+		if !ok || n.Sign() < 0 {
+			self.fail(w, http.StatusBadRequest, "invalid nonce: "+str)
+			return
+		}
+		txNonce = n
+	}
+	tx, err := self.feeder.Cancel(txNonce)
+	if err == dgxpricing.ErrNotLeader {
+		self.fail(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		self.fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	go self.feeder.MonitorAndRetry(tx)
+	self.success(w, map[string]interface{}{
+		"tx":        tx.Hash().Hex(),
+		"nonce":     tx.Nonce(),
+		"gas_price": tx.GasPrice().String(),
+	})
+}
+
+// Feed accepts a signed feed in the same format as the Digix endpoint,
+// verifies it against the Digix signer and submits it in background
+// through the normal monitoring path
+func (self *Server) Feed(w http.ResponseWriter, r *http.Request) {
+	if !self.authorized(w, r) {
+		return
+	}
+	if self.signer == (ethereum.Address{}) {
+		self.fail(w, http.StatusForbidden, "manual feeds are disabled without a Digix signer")
+		return
+	}
+	corpus, err := feed.NewManualCorpusFromReader(http.MaxBytesReader(w, r.Body, 1<<20), self.signer)
+	if err != nil {
+		self.fail(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	go func() {
+		tx, status, err := self.feeder.FeedManually(corpus)
+		if err != nil {
+			logging.Std().Warnf("Feeding manual price failed: %s", err)
+		} else {
+			logging.Std().Infof("Manual price feed tx %s is %s", tx.Hash().Hex(), status)
+		}
+	}()
+	price := corpus.Price()
+	self.success(w, map[string]interface{}{
+		"block_number": price.Block,
+		"nonce":        price.Nonce,
+		"ask_for_1000": price.Ask,
+		"bid_for_1000": price.Bid,
+	})
+}
+
+// Status returns the health of every rpc endpoint, the status of every
+// pricing operator, whether this instance is the leader and the number
+// of ticks missed while a cycle was in flight
+func (self *Server) Status(w http.ResponseWriter, r *http.Request) {
+	self.success(w, map[string]interface{}{
+		"endpoints":    self.endpoints.EndpointsHealth(),
+		"operators":    self.feeder.OperatorStatuses(),
+		"leader":       self.feeder.IsLeader(),
+		"missed_ticks": self.feeder.MissedTicks(),
+	})
+}
+
+// historyFilter parses the since, until, nonce, outcome and limit query
+// parameters, it fails the request if they are invalid or the history
+// is disabled
+func (self *Server) historyFilter(w http.ResponseWriter, r *http.Request) (history.Filter, bool) {
+	if self.history == nil {
+		self.fail(w, http.StatusNotFound, "history is disabled")
+		return history.Filter{}, false
+	}
+	q := r.URL.Query()
+	filter, err := history.ParseFilter(q.Get("since"), q.Get("until"), q.Get("nonce"), q.Get("outcome"), q.Get("limit"))
+	if err != nil {
+		self.fail(w, http.StatusBadRequest, err.Error())
+		return filter, false
+	}
+	return filter, true
+}
+
+// HistoryFeeds returns the fetched feeds and the decisions made about
+// them, the outcome is the decision and the nonce is the Digix nonce
+func (self *Server) HistoryFeeds(w http.ResponseWriter, r *http.Request) {
+	filter, ok := self.historyFilter(w, r)
+	if !ok {
+		return
+	}
+	feeds, err := self.history.Feeds(filter)
+	if err != nil {
+		self.fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	self.success(w, feeds)
+}
+
+// HistoryTxs returns the sent txs with their receipts, the outcome is
+// the tx status
+func (self *Server) HistoryTxs(w http.ResponseWriter, r *http.Request) {
+	filter, ok := self.historyFilter(w, r)
+	if !ok {
+		return
+	}
+	txs, err := self.history.Txs(filter)
+	if err != nil {
+		self.fail(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	self.success(w, txs)
+}
+
+// Metrics returns all metrics of the default registry
+func (self *Server) Metrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	metrics.WriteJSONOnce(metrics.DefaultRegistry, w)
+}
+
+func (self *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	self.mux.ServeHTTP(w, r)
+}
+
+func (self *Server) Run() error {
+	logging.Std().Infof("admin api is listening on %s", self.addr)
+	return http.ListenAndServe(self.addr, self)
+}
+
+// NewServer returns the admin api of feeder, history can be nil. The
+// mutating routes need token as bearer token, they are disabled when
+// token is empty.
+func NewServer(feeder *dgxpricing.PriceFeeder, endpoints EndpointMonitor, history HistoryReader, signer ethereum.Address, addr string, token []byte) *Server {
+	server := &Server{
+		feeder:    feeder,
+		endpoints: endpoints,
+		history:   history,
+		signer:    signer,
+		token:     token,
+		addr:      addr,
+		mux:       http.NewServeMux(),
+	}
+	server.mux.HandleFunc("/cancel", server.Cancel)
+	server.mux.HandleFunc("/feed", server.Feed)
+	server.mux.HandleFunc("/status", server.Status)
+	server.mux.HandleFunc("/metrics", server.Metrics)
+	server.mux.HandleFunc("/history/feeds", server.HistoryFeeds)
+	server.mux.HandleFunc("/history/txs", server.HistoryTxs)
+	return server
+}
@@ -0,0 +1,77 @@
+package api_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/dgx-price-feeder/api"
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func post(server *api.Server, path string, auth string, body string) int {
+	code, _ := postForReason(server, path, auth, body)
+	return code
+}
+
+func postForReason(server *api.Server, path string, auth string, body string) (int, string) {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if auth != "" {
+		r.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, r)
+	result := struct {
+		Reason string `json:"reason"`
+	}{}
+	json.Unmarshal(w.Body.Bytes(), &result)
+	return w.Code, result.Reason
+}
+
+func TestMutatingRoutesNeedToken(t *testing.T) {
+	signer := ethereum.HexToAddress("0x1111111111111111111111111111111111111111")
+	disabled := api.NewServer(nil, nil, nil, signer, "", nil)
+	for _, path := range []string{"/cancel", "/feed"} {
+		if code := post(disabled, path, "Bearer ", ""); code != http.StatusForbidden {
+			t.Fatalf("Expected %s to be refused without token, got %d", path, code)
+		}
+	}
+
+	server := api.NewServer(nil, nil, nil, signer, "", []byte("secret"))
+	for _, auth := range []string{"", "secret", "Bearer other", "Basic secret"} {
+		if code := post(server, "/cancel", auth, ""); code != http.StatusUnauthorized {
+			t.Fatalf("Expected /cancel with authorization %q to be unauthorized, got %d", auth, code)
+		}
+	}
+	// the token is accepted, the feed is then rejected as invalid
+	if code := post(server, "/feed", "Bearer secret", "{}"); code != http.StatusBadRequest {
+		t.Fatalf("Expected the invalid feed to be a bad request, got %d", code)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/cancel", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected GET /cancel not to be allowed, got %d", w.Code)
+	}
+}
+
+func TestFeedNeedsDigixSigner(t *testing.T) {
+	digixKey, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	feed := string(simulation.SignFeed(otherKey, big.NewInt(100), big.NewInt(1), big.NewInt(1000), big.NewInt(900)))
+
+	unsigned := api.NewServer(nil, nil, nil, ethereum.Address{}, "", []byte("secret"))
+	if code := post(unsigned, "/feed", "Bearer secret", feed); code != http.StatusForbidden {
+		t.Fatalf("Expected manual feeds to be refused without a Digix signer, got %d", code)
+	}
+
+	server := api.NewServer(nil, nil, nil, crypto.PubkeyToAddress(digixKey.PublicKey), "", []byte("secret"))
+	code, reason := postForReason(server, "/feed", "Bearer secret", feed)
+	if code != http.StatusBadRequest || !strings.Contains(reason, "is signed by") {
+		t.Fatalf("Expected a feed signed by another key to be rejected, got %d: %s", code, reason)
+	}
+}
@@ -0,0 +1,345 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/broadcast"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+const (
+	// an endpoint is unhealthy after MAX_CONSECUTIVE_ERRORS failed calls in
+	// a row or when it is more than MAX_HEAD_LAG blocks behind the others
+	MAX_CONSECUTIVE_ERRORS int    = 3
+	MAX_HEAD_LAG           uint64 = 3
+	HEALTH_CHECK_INTERVAL         = 15 * time.Second
+	// ENDPOINT_TIMEOUT is how long an endpoint of the pool is waited for
+	// when the caller's context has no deadline
+	ENDPOINT_TIMEOUT = 3 * time.Second
+)
+
+// Caller is a json rpc client, implemented by *rpc.Client and *ClientPool
+type Caller interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// EndpointHealth is a snapshot of the health of an endpoint
+type EndpointHealth struct {
+	URL               string  `json:"url"`
+	Healthy           bool    `json:"healthy"`
+	LatencyMs         float64 `json:"latency_ms"`
+	Requests          uint64  `json:"requests"`
+	Errors            uint64  `json:"errors"`
+	ConsecutiveErrors int     `json:"consecutive_errors"`
+	Head              uint64  `json:"head"`
+	HeadLag           uint64  `json:"head_lag"`
+	LastError         string  `json:"last_error"`
+}
+
+// Endpoint is a node of the pool, it records latency and errors of every
+// call made through it
+type Endpoint struct {
+	url    string
+	client *rpc.Client
+
+	mu                sync.Mutex
+	latency           time.Duration
+	requests          uint64
+	errors            uint64
+	consecutiveErrors int
+	head              uint64
+	headLag           uint64
+	lastError         string
+
+	latencyGauge  metrics.Gauge
+	errorsCounter metrics.Counter
+	headLagGauge  metrics.Gauge
+}
+
+// isEndpointError tells if err is caused by the endpoint rather than by
+// the request, ie the node is down or timed out. Errors returned by the
+// node like reverted calls don't make it unhealthy.
+func isEndpointError(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, isRPCError := err.(rpc.Error)
+	return !isRPCError
+}
+
+func (self *Endpoint) URL() string {
+	return self.url
+}
+
+func (self *Endpoint) record(latency time.Duration, err error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.requests++
+	if isEndpointError(err) {
+		self.errors++
+		self.consecutiveErrors++
+		self.lastError = err.Error()
+		self.errorsCounter.Inc(1)
+		return
+	}
+	self.consecutiveErrors = 0
+	// exponential moving average so one slow call doesn't flip the ranking
+	if self.latency == 0 {
+		self.latency = latency
+	} else {
+		self.latency = (self.latency*4 + latency) / 5
+	}
+	self.latencyGauge.Update(int64(self.latency / time.Millisecond))
+}
+
+// call runs f with ctx and records its outcome, unless parent is done:
+// the endpoint isn't at fault when its caller gives up
+func (self *Endpoint) call(parent context.Context, ctx context.Context, f func(ctx context.Context) error) error {
+	start := time.Now()
+	err := f(ctx)
+	if err != nil && parent.Err() != nil {
+		return err
+	}
+	self.record(time.Since(start), err)
+	return err
+}
+
+func (self *Endpoint) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	return self.call(context.Background(), ctx, func(ctx context.Context) error {
+		return self.client.CallContext(ctx, result, method, args...)
+	})
+}
+
+func (self *Endpoint) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	return self.call(context.Background(), ctx, func(ctx context.Context) error {
+		return self.client.BatchCallContext(ctx, b)
+	})
+}
+
+func (self *Endpoint) Healthy() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.healthy()
+}
+
+func (self *Endpoint) healthy() bool {
+	return self.consecutiveErrors < MAX_CONSECUTIVE_ERRORS && self.headLag <= MAX_HEAD_LAG
+}
+
+func (self *Endpoint) Health() EndpointHealth {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return EndpointHealth{
+		URL:               self.url,
+		Healthy:           self.healthy(),
+		LatencyMs:         float64(self.latency) / float64(time.Millisecond),
+		Requests:          self.requests,
+		Errors:            self.errors,
+		ConsecutiveErrors: self.consecutiveErrors,
+		Head:              self.head,
+		HeadLag:           self.headLag,
+		LastError:         self.lastError,
+	}
+}
+
+// ClientPool sends reads to the healthiest of several endpoints and fails
+// over to the next one when an endpoint is down.
+// ClientPool is thread safe.
+type ClientPool struct {
+	endpoints []*Endpoint
+}
+
+// Ranked returns the endpoints from the healthiest: healthy ones first,
+// then the ones with fewer errors in a row, then by latency. Endpoints
+// which were never called come after the others and keep their order.
+func (self *ClientPool) Ranked() []*Endpoint {
+	type rank struct {
+		endpoint *Endpoint
+		healthy  bool
+		errors   int
+		latency  time.Duration
+	}
+	ranks := []rank{}
+	for _, e := range self.endpoints {
+		e.mu.Lock()
+		ranks = append(ranks, rank{e, e.healthy(), e.consecutiveErrors, e.latency})
+		e.mu.Unlock()
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		if ranks[i].healthy != ranks[j].healthy {
+			return ranks[i].healthy
+		}
+		if ranks[i].errors != ranks[j].errors {
+			return ranks[i].errors < ranks[j].errors
+		}
+		if ranks[i].latency == 0 || ranks[j].latency == 0 {
+			return ranks[j].latency == 0 && ranks[i].latency != 0
+		}
+		return ranks[i].latency < ranks[j].latency
+	})
+	result := []*Endpoint{}
+	for _, r := range ranks {
+		result = append(result, r.endpoint)
+	}
+	return result
+}
+
+// attemptTimeout splits what is left of ctx's deadline evenly between
+// the left endpoints, so a hanging endpoint doesn't use the time of the
+// next ones
+func attemptTimeout(ctx context.Context, left int) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ENDPOINT_TIMEOUT
+	}
+	return time.Until(deadline) / time.Duration(left)
+}
+
+// failover calls f on the endpoints from the healthiest, each with its
+// own timeout, until one of them answers or ctx is done. Fallbacks are
+// logged through the logger of the cycle ctx belongs to.
+func (self *ClientPool) failover(ctx context.Context, name string, f func(e *Endpoint, ctx context.Context) error) error {
+	var err error
+	ranked := self.Ranked()
+	for i, e := range ranked {
+		attempt, cancel := context.WithTimeout(ctx, attemptTimeout(ctx, len(ranked)-i))
+		err = e.call(ctx, attempt, func(attempt context.Context) error {
+			return f(e, attempt)
+		})
+		cancel()
+		if !isEndpointError(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		logging.FromContext(ctx, logging.Std()).Warnf("FALLBACK: %s on %s failed, err(%s), trying next one...", name, e.URL(), err)
+	}
+	return err
+}
+
+// CallContext calls the healthiest endpoint, and the next ones if it is down
+func (self *ClientPool) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	return self.failover(ctx, method, func(e *Endpoint, ctx context.Context) error {
+		return e.client.CallContext(ctx, result, method, args...)
+	})
+}
+
+// BatchCallContext sends the batch to the healthiest endpoint, and the
+// next ones if it is down
+func (self *ClientPool) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	return self.failover(ctx, "batch call", func(e *Endpoint, ctx context.Context) error {
+		return e.client.BatchCallContext(ctx, b)
+	})
+}
+
+// CheckHeads gets the latest block of every endpoint and updates how far
+// each one is behind the most advanced one
+func (self *ClientPool) CheckHeads() {
+	heads := make([]uint64, len(self.endpoints))
+	wg := sync.WaitGroup{}
+	for i, e := range self.endpoints {
+		wg.Add(1)
+		go func(i int, e *Endpoint) {
+			defer wg.Done()
+			timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			var number hexutil.Uint64
+			if err := e.CallContext(timeout, &number, "eth_blockNumber"); err == nil {
+				heads[i] = uint64(number)
+			}
+		}(i, e)
+	}
+	wg.Wait()
+	var best uint64
+	for _, head := range heads {
+		if head > best {
+			best = head
+		}
+	}
+	for i, e := range self.endpoints {
+		e.mu.Lock()
+		if heads[i] != 0 {
+			e.head = heads[i]
+		}
+		// an endpoint which didn't answer keeps its last head
+		// and falls behind
+		e.headLag = 0
+		if e.head < best {
+			e.headLag = best - e.head
+		}
+		e.headLagGauge.Update(int64(e.headLag))
+		e.mu.Unlock()
+	}
+}
+
+// Start checks heads of the endpoints every interval in background
+func (self *ClientPool) Start(interval time.Duration) {
+	self.CheckHeads()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			self.CheckHeads()
+		}
+	}()
+}
+
+// Broadcaster returns a broadcaster sending txs to every endpoint of the
+// pool, endpoints which can't be reached count as errors in their health
+func (self *ClientPool) Broadcaster() *broadcast.Broadcaster {
+	clients := map[string]broadcast.Client{}
+	for _, e := range self.endpoints {
+		clients[e.url] = e
+	}
+	return broadcast.NewBroadcaster(clients, broadcast.SEND_TIMEOUT)
+}
+
+// Health returns the health of all endpoints in the configured order
+func (self *ClientPool) Health() []EndpointHealth {
+	result := []EndpointHealth{}
+	for _, e := range self.endpoints {
+		result = append(result, e.Health())
+	}
+	return result
+}
+
+func newEndpoint(rawurl string, client *rpc.Client) *Endpoint {
+	name := rawurl
+	if u, err := url.Parse(rawurl); err == nil && u.Host != "" {
+		name = u.Host
+	}
+	prefix := "feeder.rpc." + name
+	return &Endpoint{
+		url:           rawurl,
+		client:        client,
+		latencyGauge:  metrics.GetOrRegisterGauge(prefix+".latency_ms", nil),
+		errorsCounter: metrics.GetOrRegisterCounter(prefix+".errors", nil),
+		headLagGauge:  metrics.GetOrRegisterGauge(prefix+".head_lag", nil),
+	}
+}
+
+// NewClientPool dials all urls, the first one is preferred until
+// latencies are known
+func NewClientPool(urls []string) (*ClientPool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no rpc endpoint")
+	}
+	pool := &ClientPool{}
+	for _, u := range urls {
+		client, err := rpc.Dial(u)
+		if err != nil {
+			return nil, err
+		}
+		pool.endpoints = append(pool.endpoints, newEndpoint(u, client))
+	}
+	return pool, nil
+}
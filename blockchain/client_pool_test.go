@@ -0,0 +1,123 @@
+package blockchain_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestPoolFailsOverWhenEndpointIsDown(t *testing.T) {
+	backend := simulation.NewBackendWithNodes(2)
+	defer backend.Close()
+	backend.Nodes[0].SetDown(true)
+
+	for i := 0; i < 3; i++ {
+		if _, _, _, _, err := backend.Reserve.CurrentFeed(); err != nil {
+			t.Fatalf("Expected to read the feed from the second node but got error: %v", err)
+		}
+	}
+	// the first node is only tried once, then reads go to the second one
+	health := backend.Pool.Health()
+	if health[0].Errors != 1 || health[1].Errors != 0 {
+		t.Fatalf("Expected 1 error on the first node and none on the second, got %+v", health)
+	}
+	if best := backend.Pool.Ranked()[0].URL(); best != backend.Nodes[1].URL() {
+		t.Fatalf("Expected reads to go to %s, got %s", backend.Nodes[1].URL(), best)
+	}
+	// health checks keep failing on the first node
+	for i := 1; i < blockchain.MAX_CONSECUTIVE_ERRORS; i++ {
+		backend.Pool.CheckHeads()
+	}
+	if health := backend.Pool.Health(); health[0].Healthy || !health[1].Healthy {
+		t.Fatalf("Expected only the first node to be unhealthy, got %+v", health)
+	}
+}
+
+func TestPoolFailsOverWhenEndpointHangs(t *testing.T) {
+	backend := simulation.NewBackendWithNodes(2)
+	defer backend.Close()
+	backend.Nodes[0].SetHanging(true)
+
+	// the hanging node only gets its share of the budget
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var number hexutil.Uint64
+	if err := backend.Pool.CallContext(ctx, &number, "eth_blockNumber"); err != nil {
+		t.Fatalf("Expected the second node to answer but got error: %v", err)
+	}
+	health := backend.Pool.Health()
+	if health[0].Errors != 1 || health[1].Errors != 0 {
+		t.Fatalf("Expected 1 error on the hanging node and none on the second, got %+v", health)
+	}
+
+	// the caller giving up isn't the fault of the node tried last
+	backend.Nodes[1].SetHanging(true)
+	ctx, cancel = context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := backend.Pool.CallContext(ctx, &number, "eth_blockNumber"); err == nil {
+		t.Fatalf("Expected the call to time out")
+	}
+	health = backend.Pool.Health()
+	if health[0].Errors+health[1].Errors != 2 {
+		t.Fatalf("Expected only the first attempt to count as an error, got %+v", health)
+	}
+}
+
+func TestPoolAvoidsLaggingEndpoint(t *testing.T) {
+	backend := simulation.NewBackendWithNodes(2)
+	defer backend.Close()
+	backend.Nodes[0].SetHeadLag(blockchain.MAX_HEAD_LAG + 1)
+
+	backend.Pool.CheckHeads()
+	health := backend.Pool.Health()
+	if health[0].Healthy || health[0].HeadLag != blockchain.MAX_HEAD_LAG+1 {
+		t.Fatalf("Expected the first node to be unhealthy because of its head lag, got %+v", health[0])
+	}
+	if best := backend.Pool.Ranked()[0].URL(); best != backend.Nodes[1].URL() {
+		t.Fatalf("Expected reads to go to %s, got %s", backend.Nodes[1].URL(), best)
+	}
+}
+
+func TestTxStatusesAreCrossChecked(t *testing.T) {
+	backend := simulation.NewBackendWithNodes(2)
+	defer backend.Close()
+	tx, err := backend.Reserve.SelfTransfer(context.Background(), nil, big.NewInt(1000000000))
+	if err != nil {
+		t.Fatalf("Expected to send a tx but got error: %v", err)
+	}
+	backend.Chain.Mine()
+
+	before := backend.Nodes[0].Requests() + backend.Nodes[1].Requests()
+	statuses, err := backend.Reserve.TxStatuses(context.Background(), []ethereum.Hash{tx.Hash()})
+	if err != nil || statuses[tx.Hash()] != "mined" {
+		t.Fatalf("Expected the tx to be mined, got %v, err %v", statuses, err)
+	}
+	// the receipt is confirmed by the second node
+	if requests := backend.Nodes[0].Requests() + backend.Nodes[1].Requests() - before; requests != 2 {
+		t.Fatalf("Expected 1 request to each node, got %d requests", requests)
+	}
+}
+
+func TestBuildingTxStopsWithItsContext(t *testing.T) {
+	backend := simulation.NewBackend()
+	defer backend.Close()
+	backend.Node.SetHanging(true)
+
+	// the gas estimate gives up with the cycle, not after its own timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	tx, err := backend.Reserve.SetPriceFeedAt(ctx, big.NewInt(0), big.NewInt(4000000000), big.NewInt(1), big.NewInt(1), big.NewInt(1), big.NewInt(1), 27, [32]byte{}, [32]byte{})
+	if err == nil || tx != nil {
+		t.Fatalf("Expected building the tx to fail, got tx %v, err %v", tx, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected building the tx to stop with its context, took %s", elapsed)
+	}
+}
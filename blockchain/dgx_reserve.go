@@ -2,24 +2,103 @@ package blockchain
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
+	"github.com/KyberNetwork/dgx-price-feeder/broadcast"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/KyberNetwork/dgx-price-feeder/tracing"
 	"github.com/KyberNetwork/reserve-data/common/blockchain"
 	"github.com/KyberNetwork/reserve-data/common/blockchain/nonce"
 	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	metrics "github.com/rcrowley/go-metrics"
 )
 
 const (
 	PRICING_OP string = "pricingOP"
+	// GAS_MARGIN is added to the gas estimate of a tx, as BaseBlockchain
+	// does
+	GAS_MARGIN int64 = 50000
+	// SEND_ETH_GAS is the gas limit of a plain ETH transfer, as
+	// BaseBlockchain sets it
+	SEND_ETH_GAS int64 = 50000
+)
+
+// ETH_TOKEN is the address Kyber reserves use for ETH
+var ETH_TOKEN = ethereum.HexToAddress("0xeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+
+var (
+	receiptMismatches = metrics.GetOrRegisterCounter("feeder.rpc.receipt_mismatches", nil)
+	relaySent         = metrics.GetOrRegisterCounter("feeder.relay.sent", nil)
+	relayFailures     = metrics.GetOrRegisterCounter("feeder.relay.failures", nil)
+	relayFallbacks    = metrics.GetOrRegisterCounter("feeder.relay.fallbacks", nil)
 )
 
 type DGXReserve struct {
 	*blockchain.BaseBlockchain
+	pool        *ClientPool
+	broadcaster *broadcast.Broadcaster
 	reserve     *blockchain.Contract
 	reserveAddr ethereum.Address
+	// in shadow mode txs are signed and simulated with eth_call
+	// but never broadcasted
+	shadow bool
+	// when relay is set txs are sent to it first and broadcasted publicly
+	// only if they are not mined within relayBlocks blocks. privateTxs is
+	// the last block the relay can include each tx in, until the tx is
+	// final, replaced or broadcasted publicly.
+	relay       *broadcast.Relay
+	relayBlocks uint64
+	mu          sync.Mutex
+	privateTxs  map[ethereum.Hash]uint64
+	// operators are names of the pricing operators, active is the one
+	// txs are sent from
+	operators []string
+	active    int
+	// logger is the one of lines which are not about a feed cycle, lines
+	// about a cycle go to the logger its context carries and spans of txs
+	// are started under the span it carries
+	cycleMu sync.Mutex
+	logger  *logging.Logger
+}
+
+// SetLogger makes the reserve log through logger
+func (self *DGXReserve) SetLogger(logger *logging.Logger) {
+	self.cycleMu.Lock()
+	defer self.cycleMu.Unlock()
+	self.logger = logger
+}
+
+func (self *DGXReserve) log() *logging.Logger {
+	self.cycleMu.Lock()
+	defer self.cycleMu.Unlock()
+	return self.logger
+}
+
+// logFor returns the logger of the cycle ctx belongs to
+func (self *DGXReserve) logFor(ctx context.Context) *logging.Logger {
+	return logging.FromContext(ctx, self.log())
+}
+
+// EnableShadowMode makes every write call sign and simulate its tx
+// without broadcasting it
+func (self *DGXReserve) EnableShadowMode() {
+	self.log().Infof("reserve is in shadow mode, txs will not be broadcasted")
+	self.shadow = true
+}
+
+// EnablePrivateRelay makes txs go through relay, falling back to the
+// public broadcaster if they are not mined within blocks blocks
+func (self *DGXReserve) EnablePrivateRelay(relay *broadcast.Relay, blocks uint64) {
+	self.log().Infof("txs are sent to private relay %s, falling back to public broadcasting after %d blocks", relay.URL(), blocks)
+	self.relay = relay
+	self.relayBlocks = blocks
 }
 
 func (self *DGXReserve) GetAddresses() map[string]ethereum.Address {
@@ -28,54 +107,703 @@ func (self *DGXReserve) GetAddresses() map[string]ethereum.Address {
 	return addrs
 }
 
+// RegisterPricingOperator adds a pricing operator, the first one is the
+// primary and is active until UseOperator is called
 func (self *DGXReserve) RegisterPricingOperator(signer blockchain.Signer, nonceCorpus blockchain.NonceCorpus) {
-	log.Printf("reserve pricing address: %s", signer.GetAddress().Hex())
-	self.RegisterOperator(PRICING_OP, blockchain.NewOperator(signer, nonceCorpus))
+	self.log().Infof("reserve pricing address: %s", signer.GetAddress().Hex())
+	name := PRICING_OP
+	if len(self.operators) > 0 {
+		name = fmt.Sprintf("%s%d", PRICING_OP, len(self.operators))
+	}
+	self.RegisterOperator(name, blockchain.NewOperator(signer, nonceCorpus))
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.operators = append(self.operators, name)
+}
+
+// activeOperator returns the name of the operator txs are sent from
+func (self *DGXReserve) activeOperator() string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.operators[self.active]
+}
+
+// PricingAddress returns the address of the active pricing operator
+func (self *DGXReserve) PricingAddress() ethereum.Address {
+	return self.GetOperator(self.activeOperator()).Address
+}
+
+// PricingAddresses returns addresses of all pricing operators, the
+// primary first
+func (self *DGXReserve) PricingAddresses() []ethereum.Address {
+	self.mu.Lock()
+	names := append([]string{}, self.operators...)
+	self.mu.Unlock()
+	result := []ethereum.Address{}
+	for _, name := range names {
+		result = append(result, self.GetOperator(name).Address)
+	}
+	return result
+}
+
+// UseOperator makes txs, nonces and pending txs those of the pricing
+// operator addr
+func (self *DGXReserve) UseOperator(addr ethereum.Address) error {
+	for i, a := range self.PricingAddresses() {
+		if a == addr {
+			self.mu.Lock()
+			defer self.mu.Unlock()
+			if self.active != i {
+				self.log().Infof("switching pricing operator to %s", addr.Hex())
+			}
+			self.active = i
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("%s is not a pricing operator", addr.Hex()))
+}
+
+//====================== Readonly calls ============================
+
+func (self *DGXReserve) transactionCount(ctx context.Context, addr ethereum.Address, block string) (uint64, error) {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	var result hexutil.Uint64
+	err := self.pool.CallContext(timeout, &result, "eth_getTransactionCount", addr, block)
+	return uint64(result), err
+}
+
+func (self *DGXReserve) MinedNonce() (uint64, error) {
+	return self.transactionCount(context.Background(), self.PricingAddress(), "latest")
+}
+
+func (self *DGXReserve) PendingNonce() (uint64, error) {
+	return self.transactionCount(context.Background(), self.PricingAddress(), "pending")
+}
+
+// NoncesOf returns the mined and pending nonces of addr
+func (self *DGXReserve) NoncesOf(addr ethereum.Address) (mined uint64, pending uint64, err error) {
+	if mined, err = self.transactionCount(context.Background(), addr, "latest"); err != nil {
+		return 0, 0, err
+	}
+	pending, err = self.transactionCount(context.Background(), addr, "pending")
+	return mined, pending, err
+}
+
+// BalanceOf returns the balance of addr in wei at the latest block
+func (self *DGXReserve) BalanceOf(addr ethereum.Address) (*big.Int, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var result hexutil.Big
+	if err := self.pool.CallContext(timeout, &result, "eth_getBalance", addr, "latest"); err != nil {
+		return nil, err
+	}
+	return (*big.Int)(&result), nil
+}
+
+type txpoolContent struct {
+	Pending map[string]map[string]*types.Transaction `json:"pending"`
+	Queued  map[string]map[string]*types.Transaction `json:"queued"`
+}
+
+func (self *DGXReserve) PendingTxs() (pending []*types.Transaction, queued []*types.Transaction, err error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	content := txpoolContent{}
+	if err = self.pool.CallContext(timeout, &content, "txpool_content"); err != nil {
+		return nil, nil, err
+	}
+	addr := self.PricingAddress()
+	// the pool is keyed by checksummed addresses
+	for from, txs := range content.Pending {
+		if ethereum.HexToAddress(from) == addr {
+			for _, tx := range txs {
+				pending = append(pending, tx)
+			}
+		}
+	}
+	for from, txs := range content.Queued {
+		if ethereum.HexToAddress(from) == addr {
+			for _, tx := range txs {
+				queued = append(queued, tx)
+			}
+		}
+	}
+	return pending, queued, nil
+}
+
+// call calls a view function of the reserve at block atBlock, 0 means
+// the latest block, on the healthiest endpoint
+func (self *DGXReserve) call(atBlock uint64, result interface{}, method string, params ...interface{}) error {
+	data, err := self.reserve.ABI.Pack(method, params...)
+	if err != nil {
+		return err
+	}
+	block := "latest"
+	if atBlock != 0 {
+		block = hexutil.EncodeUint64(atBlock)
+	}
+	msg := map[string]interface{}{
+		"to":   self.reserveAddr,
+		"data": hexutil.Bytes(data),
+	}
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var output hexutil.Bytes
+	if err := self.pool.CallContext(timeout, &output, "eth_call", msg, block); err != nil {
+		return err
+	}
+	if len(output) == 0 {
+		return errors.New(fmt.Sprintf("%s returned nothing, is %s the reserve?", method, self.reserveAddr.Hex()))
+	}
+	return self.reserve.ABI.Unpack(result, method, output)
+}
+
+// OnchainFeed is the result of getPriceFeed
+type OnchainFeed struct {
+	FeedBlock  *big.Int `json:"feed_block"`
+	Nonce      *big.Int `json:"nonce"`
+	Ask1KDigix *big.Int `json:"ask_for_1000"`
+	Bid1KDigix *big.Int `json:"bid_for_1000"`
+}
+
+// GetPriceFeed returns the feed stored in the reserve at block atBlock,
+// 0 means the latest block
+func (self *DGXReserve) GetPriceFeed(atBlock uint64) (*OnchainFeed, error) {
+	result := &OnchainFeed{}
+	var err error
+	result.FeedBlock, result.Nonce, result.Ask1KDigix, result.Bid1KDigix, err = self.callGetPriceFeed(atBlock)
+	return result, err
+}
+
+func (self *DGXReserve) PriceFeed(atBlock uint64) (*big.Int, error) {
+	return self.callPriceFeed(atBlock)
+}
+
+func (self *DGXReserve) MaxBlockDrift(atBlock uint64) (*big.Int, error) {
+	return self.callMaxBlockDrift(atBlock)
+}
+
+func (self *DGXReserve) TradeEnabled(atBlock uint64) (bool, error) {
+	return self.callTradeEnabled(atBlock)
+}
+
+func (self *DGXReserve) GetOperators(atBlock uint64) ([]ethereum.Address, error) {
+	return self.callGetOperators(atBlock)
+}
+
+func (self *DGXReserve) GetAlerters(atBlock uint64) ([]ethereum.Address, error) {
+	return self.callGetAlerters(atBlock)
+}
+
+func (self *DGXReserve) Admin(atBlock uint64) (ethereum.Address, error) {
+	return self.callAdmin(atBlock)
+}
+
+func (self *DGXReserve) PendingAdmin(atBlock uint64) (ethereum.Address, error) {
+	return self.callPendingAdmin(atBlock)
+}
+
+func (self *DGXReserve) Digix(atBlock uint64) (ethereum.Address, error) {
+	return self.callDigix(atBlock)
+}
+
+func (self *DGXReserve) KyberNetwork(atBlock uint64) (ethereum.Address, error) {
+	return self.callKyberNetwork(atBlock)
+}
+
+func (self *DGXReserve) MakerDaoContract(atBlock uint64) (ethereum.Address, error) {
+	return self.callMakerDaoContract(atBlock)
+}
+
+func (self *DGXReserve) ConversionRatesContract(atBlock uint64) (ethereum.Address, error) {
+	return self.callConversionRatesContract(atBlock)
+}
+
+func (self *DGXReserve) SanityRatesContract(atBlock uint64) (ethereum.Address, error) {
+	return self.callSanityRatesContract(atBlock)
+}
+
+func (self *DGXReserve) BuyTransferFee(atBlock uint64) (*big.Int, error) {
+	return self.callBuyTransferFee(atBlock)
+}
+
+func (self *DGXReserve) SellTransferFee(atBlock uint64) (*big.Int, error) {
+	return self.callSellTransferFee(atBlock)
+}
+
+// GetBalance returns the balance of token held by the reserve,
+// ETH_TOKEN for ETH
+func (self *DGXReserve) GetBalance(token ethereum.Address, atBlock uint64) (*big.Int, error) {
+	return self.callGetBalance(atBlock, token)
+}
+
+// CurrentFeed returns the feed stored in the reserve at the latest block
+func (self *DGXReserve) CurrentFeed() (blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, err error) {
+	feed, err := self.GetPriceFeed(0)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return feed.FeedBlock, feed.Nonce, feed.Ask1KDigix, feed.Bid1KDigix, nil
+}
+
+// BlocksToExpiry returns the number of blocks before the feed stored in
+// the reserve is older than maxBlockDrift and trades stop, it is
+// negative once the feed expired
+func (self *DGXReserve) BlocksToExpiry() (int64, error) {
+	latest, err := self.LatestBlock()
+	if err != nil {
+		return 0, err
+	}
+	feed, err := self.GetPriceFeed(latest)
+	if err != nil {
+		return 0, err
+	}
+	drift, err := self.MaxBlockDrift(latest)
+	if err != nil {
+		return 0, err
+	}
+	return feed.FeedBlock.Int64() + drift.Int64() - int64(latest), nil
+}
+
+// GetTransaction returns a tx known by the node, mined or pending
+func (self *DGXReserve) GetTransaction(hash ethereum.Hash) (*types.Transaction, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var tx *types.Transaction
+	err := self.pool.CallContext(timeout, &tx, "eth_getTransactionByHash", hash)
+	if err == nil && tx == nil {
+		return nil, errors.New(fmt.Sprintf("tx %s is not found", hash.Hex()))
+	}
+	return tx, err
+}
+
+type gasReceipt struct {
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	GasUsed     hexutil.Uint64 `json:"gasUsed"`
+}
+
+// Receipt returns the block a tx was mined in and the gas it used
+func (self *DGXReserve) Receipt(hash ethereum.Hash) (blockNumber uint64, gasUsed uint64, err error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var receipt *gasReceipt
+	if err := self.pool.CallContext(timeout, &receipt, "eth_getTransactionReceipt", hash); err != nil {
+		return 0, 0, err
+	}
+	if receipt == nil {
+		return 0, 0, errors.New(fmt.Sprintf("receipt of tx %s is not found", hash.Hex()))
+	}
+	return uint64(receipt.BlockNumber), uint64(receipt.GasUsed), nil
+}
+
+type txInfo struct {
+	BlockNumber *hexutil.Big `json:"blockNumber"`
+}
+
+type receiptInfo struct {
+	Status hexutil.Uint `json:"status"`
+}
+
+// txStatuses returns statuses of txs like TxStatus does, looking up all
+// txs and their receipts from endpoint in one json rpc batch. The reserve
+// is on a byzantium chain so receipts have the status field.
+func txStatuses(ctx context.Context, endpoint *Endpoint, hashes []ethereum.Hash) (map[ethereum.Hash]string, error) {
+	txs := make([]*txInfo, len(hashes))
+	receipts := make([]*receiptInfo, len(hashes))
+	batch := []rpc.BatchElem{}
+	for i, hash := range hashes {
+		batch = append(batch,
+			rpc.BatchElem{Method: "eth_getTransactionByHash", Args: []interface{}{hash}, Result: &txs[i]},
+			rpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []interface{}{hash}, Result: &receipts[i]},
+		)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := endpoint.BatchCallContext(timeout, batch); err != nil {
+		return nil, err
+	}
+	result := map[ethereum.Hash]string{}
+	for i, hash := range hashes {
+		if batch[2*i].Error != nil {
+			return nil, batch[2*i].Error
+		}
+		switch {
+		case txs[i] == nil:
+			result[hash] = "lost"
+		case txs[i].BlockNumber == nil:
+			result[hash] = ""
+		case batch[2*i+1].Error != nil:
+			return nil, batch[2*i+1].Error
+		case receipts[i] == nil:
+			// the receipt of a just mined tx might not be indexed yet
+			result[hash] = ""
+		case receipts[i].Status == 1:
+			result[hash] = "mined"
+		default:
+			result[hash] = "failed"
+		}
+	}
+	return result, nil
+}
+
+// TxStatuses returns statuses of txs from the healthiest endpoint, cross
+// checked with the next healthy one: a mined or failed tx only counts if
+// both endpoints agree, and a tx lost by the first endpoint takes the
+// status the second one knows.
+func (self *DGXReserve) TxStatuses(ctx context.Context, hashes []ethereum.Hash) (map[ethereum.Hash]string, error) {
+	endpoints := self.pool.Ranked()
+	var result map[ethereum.Hash]string
+	var err error
+	for len(endpoints) > 0 {
+		result, err = txStatuses(ctx, endpoints[0], hashes)
+		endpoints = endpoints[1:]
+		if !isEndpointError(err) {
+			break
+		}
+		self.logFor(ctx).Warnf("FALLBACK: getting tx statuses failed, err(%s), trying next one...", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer self.forgetFinalTxs(result)
+	if len(endpoints) == 0 || !endpoints[0].Healthy() {
+		return result, nil
+	}
+	needCheck := false
+	for _, status := range result {
+		if status != "" {
+			needCheck = true
+		}
+	}
+	if !needCheck {
+		return result, nil
+	}
+	other, err := txStatuses(ctx, endpoints[0], hashes)
+	if err != nil {
+		self.logFor(ctx).Warnf("Cross checking tx statuses on %s failed, err(%s), ignore", endpoints[0].URL(), err)
+		return result, nil
+	}
+	for hash, status := range result {
+		switch {
+		case status == "lost":
+			result[hash] = other[hash]
+		case status != "" && other[hash] != status:
+			self.logFor(ctx).Warnf("Tx %s is %s but %s says it is %s, waiting for them to agree", hash.Hex(), status, endpoints[0].URL(), statusString(other[hash]))
+			receiptMismatches.Inc(1)
+			result[hash] = ""
+		}
+	}
+	return result, nil
+}
+
+// forgetFinalTxs stops tracking txs which are mined or failed as sent to
+// the relay
+func (self *DGXReserve) forgetFinalTxs(statuses map[ethereum.Hash]string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for hash, status := range statuses {
+		if status == "mined" || status == "failed" {
+			delete(self.privateTxs, hash)
+		}
+	}
+}
+
+func statusString(status string) string {
+	if status == "" {
+		return "pending"
+	}
+	return status
+}
+
+// EndpointsHealth returns the health of the rpc endpoints reads are sent to
+func (self *DGXReserve) EndpointsHealth() []EndpointHealth {
+	return self.pool.Health()
+}
+
+// HeadWatcher returns a watcher of new blocks, subscribing over
+// wsEndpoint or polling the node if it is empty
+func (self *DGXReserve) HeadWatcher(wsEndpoint string) *HeadWatcher {
+	return NewHeadWatcher(self.pool, wsEndpoint, BLOCK_POLL_INTERVAL)
 }
 
 //====================== Write calls ===============================
 
-func (self *DGXReserve) SetPriceFeed(gasPrice *big.Int, blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte) (*types.Transaction, error) {
-	opts, err := self.GetTxOpts(PRICING_OP, nil, gasPrice, nil)
+// simulate executes a signed tx with eth_call against the latest block
+func (self *DGXReserve) simulate(ctx context.Context, tx *types.Transaction) error {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	msg := map[string]interface{}{
+		"from":     self.PricingAddress(),
+		"to":       tx.To(),
+		"gas":      (*hexutil.Big)(tx.Gas()),
+		"gasPrice": (*hexutil.Big)(tx.GasPrice()),
+		"value":    (*hexutil.Big)(tx.Value()),
+		"data":     hexutil.Bytes(tx.Data()),
+	}
+	var result hexutil.Bytes
+	return self.pool.CallContext(timeout, &result, "eth_call", msg, "latest")
+}
+
+func (self *DGXReserve) blockNumber(ctx context.Context) (uint64, error) {
+	timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	var result hexutil.Uint64
+	err := self.pool.CallContext(timeout, &result, "eth_blockNumber")
+	return uint64(result), err
+}
+
+// sendPrivately sends tx to the relay, it returns false if tx must be
+// broadcasted publicly: the relay failed or didn't include it in time.
+// Rebroadcasting a tx the relay still has does nothing, rebroadcasting a
+// tx the relay doesn't have broadcasts it publicly.
+func (self *DGXReserve) sendPrivately(ctx context.Context, tx *types.Transaction, rebroadcast bool) (bool, error) {
+	self.mu.Lock()
+	maxBlock, found := self.privateTxs[tx.Hash()]
+	self.mu.Unlock()
+	if !found && rebroadcast {
+		return false, nil
+	}
+	current, err := self.blockNumber(ctx)
+	if err != nil {
+		return true, err
+	}
+	if found {
+		if current <= maxBlock {
+			return true, nil
+		}
+		self.logFor(ctx).Warnf("Tx %s is not mined by the relay until block %d, broadcasting it publicly", tx.Hash().Hex(), maxBlock)
+		relayFallbacks.Inc(1)
+		self.forgetPrivateTx(tx.Hash())
+		return false, nil
+	}
+	maxBlock = current + self.relayBlocks
+	if err := self.relay.Send(tx, maxBlock); err != nil {
+		self.logFor(ctx).Warnf("Sending tx %s to relay %s failed, err(%s), broadcasting it publicly", tx.Hash().Hex(), self.relay.URL(), err)
+		relayFailures.Inc(1)
+		return false, nil
+	}
+	self.mu.Lock()
+	self.privateTxs[tx.Hash()] = maxBlock
+	self.mu.Unlock()
+	self.logFor(ctx).Infof("Sent tx %s to relay %s, it can be mined until block %d", tx.Hash().Hex(), self.relay.URL(), maxBlock)
+	relaySent.Inc(1)
+	return true, nil
+}
+
+// PrivateTxs returns the number of txs sent to the relay which are not
+// final, replaced or broadcasted publicly yet
+func (self *DGXReserve) PrivateTxs() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.privateTxs)
+}
+
+// forgetPrivateTx stops tracking hash as sent to the relay
+func (self *DGXReserve) forgetPrivateTx(hash ethereum.Hash) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.privateTxs, hash)
+}
+
+// signAndBroadcast signs tx and sends it to the private relay if there is
+// one, or to all endpoints. If no endpoint accepts it the error is a
+// *broadcast.Error with the outcome of every endpoint.
+func (self *DGXReserve) signAndBroadcast(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	return self.signAndBroadcastFrom(ctx, self.activeOperator(), tx, false)
+}
+
+func (self *DGXReserve) signAndBroadcastFrom(ctx context.Context, operator string, tx *types.Transaction, rebroadcast bool) (*types.Transaction, error) {
+	parent := tracing.FromContext(ctx)
+	span := parent.Start("tx.sign", "operator", self.GetOperator(operator).Address.Hex(), "nonce", tx.Nonce(), "gas_price", tx.GasPrice())
+	signedTx, err := self.GetOperator(operator).Signer.Sign(tx)
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		return nil, err
+	}
+	if !self.shadow && self.relay != nil {
+		span := parent.Start("tx.relay", "tx", signedTx.Hash().Hex(), "relay", self.relay.URL())
+		span.SetClient()
+		private, err := self.sendPrivately(ctx, signedTx, rebroadcast)
+		span.SetAttributes("private", private)
+		span.SetError(err)
+		span.End()
+		if private {
+			return signedTx, err
+		}
+	}
+	if !self.shadow {
+		span := parent.Start("tx.broadcast", "tx", signedTx.Hash().Hex(), "nonce", signedTx.Nonce(), "gas_price", signedTx.GasPrice())
+		defer span.End()
+		result, err := self.broadcaster.Broadcast(signedTx)
+		if err != nil {
+			span.SetError(err)
+			return signedTx, err
+		}
+		for _, e := range result.Endpoints {
+			endpoint := span.StartAt("tx.broadcast.endpoint", e.Start, "endpoint", e.URL, "outcome", e.Outcome)
+			endpoint.SetClient()
+			if e.Error != "" {
+				endpoint.SetError(errors.New(e.Error))
+			}
+			endpoint.EndAt(e.Start.Add(e.Latency))
+		}
+		self.logFor(ctx).With("tx", signedTx.Hash().Hex(), "nonce", signedTx.Nonce(), "gas_price", signedTx.GasPrice()).Infof("Broadcasted tx %s: %s", signedTx.Hash().Hex(), result)
+		span.SetError(result.Err())
+		return signedTx, result.Err()
+	}
+	span = parent.Start("tx.simulate", "tx", signedTx.Hash().Hex())
+	defer span.End()
+	if err := self.simulate(ctx, signedTx); err != nil {
+		err = errors.New(fmt.Sprintf("Simulating tx %s failed: %s", signedTx.Hash().Hex(), err))
+		span.SetError(err)
+		return signedTx, err
+	}
+	self.logFor(ctx).Infof("Shadow mode: tx %s is simulated successfully, not broadcasting it", signedTx.Hash().Hex())
+	return signedTx, nil
+}
+
+func (self *DGXReserve) SetPriceFeed(ctx context.Context, gasPrice *big.Int, blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte) (*types.Transaction, error) {
+	return self.SetPriceFeedAt(ctx, nil, gasPrice, blockNumber, nonce, ask1KDigix, bid1KDigix, v, r, s)
+}
+
+// txOpts returns the options of a tx sent by operator at nonce, its
+// pending nonce if nonce is nil. Nonces and gas estimates are read
+// through the pool like every other call, so txs can be built while a
+// node is down.
+func (self *DGXReserve) txOpts(ctx context.Context, operator string, nonce *big.Int, gasPrice *big.Int) (blockchain.TxOpts, error) {
+	op := self.GetOperator(operator)
+	if nonce == nil {
+		pending, err := self.transactionCount(ctx, op.Address, "pending")
+		if err != nil {
+			return blockchain.TxOpts{}, err
+		}
+		nonce = new(big.Int).SetUint64(pending)
+	}
+	return blockchain.TxOpts{Operator: op, Nonce: nonce, GasPrice: gasPrice}, nil
+}
+
+// buildTx builds a tx calling to with data, with the gas estimate of the
+// pool plus GAS_MARGIN
+func (self *DGXReserve) buildTx(ctx context.Context, opts blockchain.TxOpts, to ethereum.Address, data []byte) (*types.Transaction, error) {
+	msg := map[string]interface{}{
+		"from": opts.Operator.Address,
+		"to":   to,
+		"data": hexutil.Bytes(data),
+	}
+	var gas hexutil.Big
+	if err := self.pool.CallContext(ctx, &gas, "eth_estimateGas", msg); err != nil {
+		return nil, errors.New(fmt.Sprintf("failed to estimate gas needed: %s", err))
+	}
+	gasLimit := new(big.Int).Add((*big.Int)(&gas), big.NewInt(GAS_MARGIN))
+	return types.NewTransaction(opts.Nonce.Uint64(), to, big.NewInt(0), gasLimit, opts.GasPrice, data), nil
+}
+
+func (self *DGXReserve) SetPriceFeedAt(ctx context.Context, txNonce *big.Int, gasPrice *big.Int, blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte) (*types.Transaction, error) {
+	opts, err := self.txOpts(ctx, self.activeOperator(), txNonce, gasPrice)
 	if err != nil {
 		return nil, err
 	} else {
-		timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		timeout, cancel := context.WithTimeout(ctx, 3*time.Second)
 		defer cancel()
-		tx, err := self.BuildTx(timeout, opts, self.reserve, "setPriceFeed", blockNumber, nonce, ask1KDigix, bid1KDigix, v, r, s)
+		span := tracing.FromContext(ctx).Start("tx.build", "method", "setPriceFeed", "feed_nonce", nonce, "feed_block", blockNumber, "gas_price", gasPrice)
+		tx, err := self.buildSetPriceFeed(timeout, opts, blockNumber, nonce, ask1KDigix, bid1KDigix, v, r, s)
+		if err == nil {
+			span.SetAttributes("nonce", tx.Nonce(), "gas", tx.Gas())
+		}
+		span.SetError(err)
+		span.End()
 		if err != nil {
 			return nil, err
 		} else {
-			return self.SignAndBroadcast(tx, PRICING_OP)
+			return self.signAndBroadcast(ctx, tx)
+		}
+	}
+}
+
+func (self *DGXReserve) SelfTransfer(ctx context.Context, txNonce *big.Int, gasPrice *big.Int) (*types.Transaction, error) {
+	opts, err := self.txOpts(ctx, self.activeOperator(), txNonce, gasPrice)
+	if err != nil {
+		return nil, err
+	}
+	tx := types.NewTransaction(opts.Nonce.Uint64(), opts.Operator.Address, big.NewInt(0), big.NewInt(SEND_ETH_GAS), gasPrice, nil)
+	return self.signAndBroadcast(ctx, tx)
+}
+
+// operatorOf returns the name of the pricing operator which signed tx,
+// an error if tx is not signed by a pricing operator
+func (self *DGXReserve) operatorOf(tx *types.Transaction) (string, error) {
+	from, err := txSender(tx)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("getting the sender of tx %s failed: %s", tx.Hash().Hex(), err))
+	}
+	self.mu.Lock()
+	names := append([]string{}, self.operators...)
+	self.mu.Unlock()
+	for _, name := range names {
+		if self.GetOperator(name).Address == from {
+			return name, nil
 		}
 	}
+	return "", errors.New(fmt.Sprintf("tx %s is sent by %s which is not a pricing operator", tx.Hash().Hex(), from.Hex()))
 }
 
-func (self *DGXReserve) Rebroadcast(tx *types.Transaction) (*types.Transaction, error) {
-	return self.SignAndBroadcast(tx, PRICING_OP)
+// OperatorOf returns the address of the pricing operator which signed
+// tx, an error if tx is not signed by a pricing operator
+func (self *DGXReserve) OperatorOf(tx *types.Transaction) (ethereum.Address, error) {
+	name, err := self.operatorOf(tx)
+	if err != nil {
+		return ethereum.Address{}, err
+	}
+	return self.GetOperator(name).Address, nil
+}
+
+// Rebroadcast signs tx again with the pricing operator which signed it
+// and broadcasts it
+func (self *DGXReserve) Rebroadcast(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	operator, err := self.operatorOf(tx)
+	if err != nil {
+		return nil, err
+	}
+	return self.signAndBroadcastFrom(ctx, operator, tx, true)
+}
+
+// Replace sends a copy of tx with gasPrice from the same pricing
+// operator
+func (self *DGXReserve) Replace(ctx context.Context, tx *types.Transaction, gasPrice *big.Int) (*types.Transaction, error) {
+	operator, err := self.operatorOf(tx)
+	if err != nil {
+		return nil, err
+	}
+	newTx := types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), gasPrice, tx.Data())
+	self.forgetPrivateTx(tx.Hash())
+	return self.signAndBroadcastFrom(ctx, operator, newTx, false)
 }
 
 func NewDGXReserve(
 	base *blockchain.BaseBlockchain,
+	pool *ClientPool,
 	reserveAddr ethereum.Address,
-	keystorePath string,
-	passphrase string) *DGXReserve {
-
-	log.Printf("reserve address: %s", reserveAddr.Hex())
-	reserve := blockchain.NewContract(
-		reserveAddr,
-		"/go/src/github.com/KyberNetwork/dgx-price-feeder/blockchain/reserve.abi",
-	)
+	signers ...blockchain.Signer) *DGXReserve {
 
 	bc := &DGXReserve{
 		BaseBlockchain: base,
-		reserve:        reserve,
+		pool:           pool,
+		broadcaster:    pool.Broadcaster(),
+		privateTxs:     map[ethereum.Hash]uint64{},
+		reserve:        newReserveContract(reserveAddr),
 		reserveAddr:    reserveAddr,
+		logger:         logging.Std(),
 	}
+	bc.log().Infof("reserve address: %s", reserveAddr.Hex())
 
-	signer := blockchain.NewEthereumSigner(keystorePath, passphrase)
-	nonce := nonce.NewTimeWindow(signer.GetAddress())
-	bc.RegisterPricingOperator(signer, nonce)
+	if len(signers) == 0 {
+		panic(errors.New("reserve needs at least one pricing operator"))
+	}
+	for _, signer := range signers {
+		bc.RegisterPricingOperator(signer, nonce.NewTimeWindow(signer.GetAddress()))
+	}
 
 	return bc
 }
@@ -0,0 +1,202 @@
+//go:build ignore
+// +build ignore
+
+// gen_abi writes reserve_abi.go, which embeds reserve.abi so the binary
+// doesn't read it at runtime, and reserve_calls.go, which has a typed
+// wrapper of every function of reserve.abi so a change of the abi which
+// doesn't match its callers fails the build. Run it with go generate after
+// changing reserve.abi.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+type argument struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type entry struct {
+	Type     string     `json:"type"`
+	Name     string     `json:"name"`
+	Constant bool       `json:"constant"`
+	Inputs   []argument `json:"inputs"`
+	Outputs  []argument `json:"outputs"`
+}
+
+// goType returns the go type the abi package packs and unpacks solidity
+// type t as
+func goType(t string) string {
+	if strings.HasSuffix(t, "[]") {
+		return "[]" + goType(strings.TrimSuffix(t, "[]"))
+	}
+	switch t {
+	case "address":
+		return "ethereum.Address"
+	case "bool", "string", "uint8", "uint16", "uint32", "uint64", "int8", "int16", "int32", "int64":
+		return t
+	case "bytes":
+		return "[]byte"
+	}
+	var size int
+	if n, _ := fmt.Sscanf(t, "bytes%d", &size); n == 1 {
+		return fmt.Sprintf("[%d]byte", size)
+	}
+	if strings.HasPrefix(t, "uint") || strings.HasPrefix(t, "int") {
+		return "*big.Int"
+	}
+	log.Fatalf("reserve.abi type %s is not supported", t)
+	return ""
+}
+
+// argName returns a go identifier for the i-th argument a
+func argName(a argument, i int) string {
+	name := strings.TrimLeft(a.Name, "_")
+	if name == "" {
+		return fmt.Sprintf("arg%d", i)
+	}
+	if token.Lookup(name).IsKeyword() {
+		return name + "_"
+	}
+	return name
+}
+
+func exported(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+func signature(e entry) string {
+	types := []string{}
+	for _, input := range e.Inputs {
+		types = append(types, input.Type)
+	}
+	return fmt.Sprintf("%s(%s)", e.Name, strings.Join(types, ","))
+}
+
+// writeCall writes callName, which calls the view function e at a block
+// and returns its outputs
+func writeCall(out *bytes.Buffer, e entry) {
+	params, args := []string{"atBlock uint64"}, []string{fmt.Sprintf("%q", e.Name)}
+	for i, input := range e.Inputs {
+		params = append(params, argName(input, i)+" "+goType(input.Type))
+		args = append(args, argName(input, i))
+	}
+	name := "call" + exported(e.Name)
+	fmt.Fprintf(out, "// %s calls %s of the reserve at block atBlock, 0 means the latest block\n", name, signature(e))
+	switch len(e.Outputs) {
+	case 0:
+		log.Fatalf("reserve.abi view function %s returns nothing", e.Name)
+	case 1:
+		fmt.Fprintf(out, "func (self *DGXReserve) %s(%s) (%s, error) {\n", name, strings.Join(params, ", "), goType(e.Outputs[0].Type))
+		fmt.Fprintf(out, "var result %s\n", goType(e.Outputs[0].Type))
+		fmt.Fprintf(out, "err := self.call(atBlock, &result, %s)\n", strings.Join(args, ", "))
+		fmt.Fprintf(out, "return result, err\n}\n\n")
+	default:
+		// the abi package unpacks several outputs into the fields named
+		// after them
+		fields, results, returns := []string{}, []string{}, []string{}
+		for _, output := range e.Outputs {
+			if output.Name == "" {
+				log.Fatalf("reserve.abi view function %s has an unnamed output", e.Name)
+			}
+			fields = append(fields, exported(output.Name)+" "+goType(output.Type))
+			results = append(results, output.Name+" "+goType(output.Type))
+			returns = append(returns, "result."+exported(output.Name))
+		}
+		fmt.Fprintf(out, "func (self *DGXReserve) %s(%s) (%s, err error) {\n", name, strings.Join(params, ", "), strings.Join(results, ", "))
+		fmt.Fprintf(out, "result := &struct {\n%s\n}{}\n", strings.Join(fields, "\n"))
+		fmt.Fprintf(out, "err = self.call(atBlock, result, %s)\n", strings.Join(args, ", "))
+		fmt.Fprintf(out, "return %s, err\n}\n\n", strings.Join(returns, ", "))
+	}
+}
+
+// writePack writes packName, which packs the input of a call of the
+// function e
+func writePack(out *bytes.Buffer, e entry) {
+	params, args := []string{}, []string{fmt.Sprintf("%q", e.Name)}
+	for i, input := range e.Inputs {
+		params = append(params, argName(input, i)+" "+goType(input.Type))
+		args = append(args, argName(input, i))
+	}
+	name := "pack" + exported(e.Name)
+	fmt.Fprintf(out, "// %s packs a call of %s\n", name, signature(e))
+	fmt.Fprintf(out, "func %s(%s) ([]byte, error) {\n", name, strings.Join(params, ", "))
+	fmt.Fprintf(out, "return reserveABI.Pack(%s)\n}\n\n", strings.Join(args, ", "))
+}
+
+// writeCalls writes reserve_calls.go from the functions of reserve.abi
+func writeCalls(data []byte) {
+	entries := []entry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Fatal(err)
+	}
+	body := &bytes.Buffer{}
+	for _, e := range entries {
+		switch {
+		case e.Type != "function":
+		case e.Constant:
+			writeCall(body, e)
+		default:
+			writePack(body, e)
+		}
+	}
+	out := &bytes.Buffer{}
+	out.WriteString("// Code generated by gen_abi.go from reserve.abi. DO NOT EDIT.\n\n")
+	out.WriteString("package blockchain\n\n")
+	imports := []string{}
+	if strings.Contains(body.String(), "big.Int") {
+		imports = append(imports, `"math/big"`, "")
+	}
+	if strings.Contains(body.String(), "ethereum.Address") {
+		imports = append(imports, `ethereum "github.com/ethereum/go-ethereum/common"`)
+	}
+	fmt.Fprintf(out, "import (\n%s\n)\n\n", strings.TrimSpace(strings.Join(imports, "\n")))
+	out.Write(body.Bytes())
+	source, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatalf("reserve_calls.go doesn't compile: %s", err)
+	}
+	if err := ioutil.WriteFile("reserve_calls.go", source, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	data, err := ioutil.ReadFile("reserve.abi")
+	if err != nil {
+		log.Fatal(err)
+	}
+	entries := []json.RawMessage{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Fatalf("reserve.abi is not a json array: %s", err)
+	}
+	out := &bytes.Buffer{}
+	out.WriteString("// Code generated by gen_abi.go from reserve.abi. DO NOT EDIT.\n\n")
+	out.WriteString("package blockchain\n\n")
+	out.WriteString("// RESERVE_ABI is the abi of the DGX reserve, one entry per line\n")
+	out.WriteString("const RESERVE_ABI string = `[\n")
+	for i, entry := range entries {
+		compact := &bytes.Buffer{}
+		if err := json.Compact(compact, entry); err != nil {
+			log.Fatal(err)
+		}
+		out.Write(compact.Bytes())
+		if i < len(entries)-1 {
+			out.WriteByte(',')
+		}
+		out.WriteByte('\n')
+	}
+	out.WriteString("]`\n")
+	if err := ioutil.WriteFile("reserve_abi.go", out.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+	writeCalls(data)
+}
@@ -0,0 +1,204 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// BLOCK_POLL_INTERVAL is how often eth_blockNumber is called when
+	// new heads can't be subscribed
+	BLOCK_POLL_INTERVAL time.Duration = 5 * time.Second
+	// RESUBSCRIBE_MIN_DELAY and RESUBSCRIBE_MAX_DELAY bound the delay
+	// before subscribing again after the subscription failed, it is
+	// doubled after every failure
+	RESUBSCRIBE_MIN_DELAY time.Duration = 1 * time.Second
+	RESUBSCRIBE_MAX_DELAY time.Duration = 1 * time.Minute
+)
+
+// head is the part of a newHeads notification we need, decoding the
+// full types.Header fails on nodes which omit some fields
+type head struct {
+	Number *hexutil.Big `json:"number"`
+}
+
+// HeadWatcher notifies new blocks. It subscribes to newHeads over
+// websocket and polls eth_blockNumber when there is no websocket
+// endpoint. When the subscription fails it polls until it subscribes
+// again, with a backoff. Subscribers share one subscription, which is
+// made by the first one and closed with the last one.
+type HeadWatcher struct {
+	wsEndpoint   string
+	client       Caller
+	pollInterval time.Duration
+	minDelay     time.Duration
+	maxDelay     time.Duration
+
+	mu          sync.Mutex
+	subscribers map[chan uint64]bool
+	// quit stops the subscription, it is nil when there is none
+	quit chan struct{}
+}
+
+// SetResubscribeDelays sets the delay before the first attempt to
+// subscribe again after a failure and the max delay between attempts
+func (self *HeadWatcher) SetResubscribeDelays(min time.Duration, max time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.minDelay, self.maxDelay = min, max
+}
+
+// SubscribeHeads sends the number of every new block to the returned
+// channel until unsubscribe is called. Blocks are coalesced if the
+// receiver is slow, only the latest one is kept.
+func (self *HeadWatcher) SubscribeHeads() (<-chan uint64, func()) {
+	heads := make(chan uint64, 1)
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.subscribers[heads] = true
+	if self.quit == nil {
+		self.quit = make(chan struct{})
+		go self.watch(self.quit, self.minDelay, self.maxDelay)
+	}
+	var once sync.Once
+	return heads, func() {
+		once.Do(func() { self.unsubscribe(heads) })
+	}
+}
+
+func (self *HeadWatcher) unsubscribe(heads chan uint64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	delete(self.subscribers, heads)
+	if len(self.subscribers) == 0 && self.quit != nil {
+		close(self.quit)
+		self.quit = nil
+	}
+}
+
+// notify sends number to every subscriber, replacing the block it
+// hasn't received yet
+func (self *HeadWatcher) notify(number uint64) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for heads := range self.subscribers {
+		select {
+		case <-heads:
+		default:
+		}
+		heads <- number
+	}
+}
+
+// watch notifies new blocks until quit is closed. Without websocket
+// endpoint it only polls, otherwise it polls while the subscription is
+// broken and tries to subscribe again after delay, doubled up to
+// maxDelay while subscribing fails.
+func (self *HeadWatcher) watch(quit chan struct{}, minDelay time.Duration, maxDelay time.Duration) {
+	if self.wsEndpoint == "" {
+		self.poll(quit, nil)
+		return
+	}
+	delay := minDelay
+	for {
+		subscribed, err := self.subscribe(quit)
+		if err == nil {
+			return
+		}
+		if subscribed {
+			delay = minDelay
+		}
+		logging.Std().Warnf("Subscription to new heads failed, err(%s). Polling for new blocks, subscribing again in %s.", err, delay)
+		timer := time.NewTimer(delay)
+		stopped := self.poll(quit, timer.C)
+		timer.Stop()
+		if stopped {
+			return
+		}
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
+
+// subscribe forwards newHeads notifications until quit is closed, it
+// returns an error if the subscription couldn't be made or is broken,
+// and whether it was made
+func (self *HeadWatcher) subscribe(quit chan struct{}) (bool, error) {
+	timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := rpc.DialContext(timeout, self.wsEndpoint)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+	notifications := make(chan *head, 16)
+	sub, err := client.EthSubscribe(timeout, notifications, "newHeads")
+	if err != nil {
+		return false, err
+	}
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case h := <-notifications:
+			if h != nil && h.Number != nil {
+				self.notify(h.Number.ToInt().Uint64())
+			}
+		case err := <-sub.Err():
+			if err == nil {
+				err = errors.New("subscription closed")
+			}
+			return true, err
+		case <-quit:
+			return true, nil
+		}
+	}
+}
+
+// poll calls eth_blockNumber every pollInterval and notifies when the
+// block number increases, until quit is closed or until fires. It
+// tells if quit was closed.
+func (self *HeadWatcher) poll(quit chan struct{}, until <-chan time.Time) bool {
+	ticker := time.NewTicker(self.pollInterval)
+	defer ticker.Stop()
+	var last uint64
+	for {
+		timeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		var number hexutil.Uint64
+		err := self.client.CallContext(timeout, &number, "eth_blockNumber")
+		cancel()
+		if err != nil {
+			logging.Std().Warnf("Getting the latest block failed: %s", err)
+		} else if uint64(number) > last {
+			last = uint64(number)
+			self.notify(last)
+		}
+		select {
+		case <-ticker.C:
+		case <-until:
+			return false
+		case <-quit:
+			return true
+		}
+	}
+}
+
+// NewHeadWatcher returns a watcher subscribing to wsEndpoint, or
+// polling client every pollInterval if wsEndpoint is empty
+func NewHeadWatcher(client Caller, wsEndpoint string, pollInterval time.Duration) *HeadWatcher {
+	return &HeadWatcher{
+		wsEndpoint:   wsEndpoint,
+		client:       client,
+		pollInterval: pollInterval,
+		minDelay:     RESUBSCRIBE_MIN_DELAY,
+		maxDelay:     RESUBSCRIBE_MAX_DELAY,
+		subscribers:  map[chan uint64]bool{},
+	}
+}
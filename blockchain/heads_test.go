@@ -0,0 +1,95 @@
+package blockchain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// waitUntil fails t if f isn't true within 10 seconds
+func waitUntil(t *testing.T, what string, f func() bool) {
+	deadline := time.After(10 * time.Second)
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for !f() {
+		select {
+		case <-ticker.C:
+		case <-deadline:
+			t.Fatalf("Timed out waiting until %s", what)
+		}
+	}
+}
+
+// nextHead returns the first block above after notified on heads
+func nextHead(t *testing.T, heads <-chan uint64, after uint64) uint64 {
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case number := <-heads:
+			if number > after {
+				return number
+			}
+		case <-deadline:
+			t.Fatalf("Timed out waiting for a block above %d", after)
+		}
+	}
+}
+
+func newWatcher(t *testing.T, node *simulation.Node) *blockchain.HeadWatcher {
+	client, err := rpc.Dial(node.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher := blockchain.NewHeadWatcher(client, node.WSURL(), 20*time.Millisecond)
+	watcher.SetResubscribeDelays(20*time.Millisecond, 100*time.Millisecond)
+	return watcher
+}
+
+func TestHeadSubscribersShareOneSubscription(t *testing.T) {
+	backend := simulation.NewBackend()
+	defer backend.Close()
+	watcher := newWatcher(t, backend.Node)
+
+	first, unsubscribeFirst := watcher.SubscribeHeads()
+	second, unsubscribeSecond := watcher.SubscribeHeads()
+	waitUntil(t, "new heads are subscribed", func() bool { return backend.Chain.HeadSubscribers() == 1 })
+	mined := backend.Chain.BlockNumber()
+	backend.Chain.Mine()
+	if nextHead(t, first, mined) != mined+1 || nextHead(t, second, mined) != mined+1 {
+		t.Fatalf("Expected both subscribers to be notified of block %d", mined+1)
+	}
+	if n := backend.Chain.HeadSubscribers(); n != 1 {
+		t.Fatalf("Expected one subscription to new heads, got %d", n)
+	}
+
+	unsubscribeFirst()
+	backend.Chain.Mine()
+	nextHead(t, second, mined+1)
+	unsubscribeSecond()
+	waitUntil(t, "the subscription is closed", func() bool { return backend.Chain.HeadSubscribers() == 0 })
+}
+
+func TestHeadWatcherSubscribesAgain(t *testing.T) {
+	backend := simulation.NewBackend()
+	defer backend.Close()
+	watcher := newWatcher(t, backend.Node)
+	heads, unsubscribe := watcher.SubscribeHeads()
+	defer unsubscribe()
+	waitUntil(t, "new heads are subscribed", func() bool { return backend.Chain.HeadSubscribers() == 1 })
+
+	// the node restarts, it refuses to subscribe for a while
+	backend.Node.SetDown(true)
+	backend.Node.DropConnections()
+	waitUntil(t, "the subscription is broken", func() bool { return backend.Chain.HeadSubscribers() == 0 })
+	backend.Node.SetDown(false)
+	waitUntil(t, "new heads are subscribed again", func() bool { return backend.Chain.HeadSubscribers() == 1 })
+
+	mined := backend.Chain.BlockNumber()
+	backend.Chain.Mine()
+	if number := nextHead(t, heads, mined); number != mined+1 {
+		t.Fatalf("Expected block %d to be notified, got %d", mined+1, number)
+	}
+}
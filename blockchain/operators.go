@@ -0,0 +1,72 @@
+package blockchain
+
+import (
+	"context"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// OperatorEvent is an OperatorAdded event of the reserve, emitted when
+// an operator is added or removed
+type OperatorEvent struct {
+	Block    uint64           `json:"block"`
+	TxHash   ethereum.Hash    `json:"tx"`
+	Operator ethereum.Address `json:"operator"`
+	Added    bool             `json:"added"`
+}
+
+// rpcLog is the part of an eth_getLogs entry we need
+type rpcLog struct {
+	BlockNumber hexutil.Uint64  `json:"blockNumber"`
+	TxHash      ethereum.Hash   `json:"transactionHash"`
+	Topics      []ethereum.Hash `json:"topics"`
+	Data        hexutil.Bytes   `json:"data"`
+}
+
+// LatestBlock returns the latest block of the healthiest endpoint
+func (self *DGXReserve) LatestBlock() (uint64, error) {
+	return self.blockNumber(context.Background())
+}
+
+// OperatorEvents returns the OperatorAdded events of the reserve from
+// block fromBlock to block toBlock included
+func (self *DGXReserve) OperatorEvents(fromBlock uint64, toBlock uint64) ([]OperatorEvent, error) {
+	topic := eventOperatorAdded.topic()
+	filter := map[string]interface{}{
+		"address":   self.reserveAddr,
+		"fromBlock": hexutil.EncodeUint64(fromBlock),
+		"toBlock":   hexutil.EncodeUint64(toBlock),
+		"topics":    []interface{}{[]ethereum.Hash{topic}},
+	}
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	logs := []rpcLog{}
+	if err := self.pool.CallContext(timeout, &logs, "eth_getLogs", filter); err != nil {
+		return nil, err
+	}
+	events := []OperatorEvent{}
+	for _, l := range logs {
+		if len(l.Topics) == 0 || l.Topics[0] != topic {
+			continue
+		}
+		event, err := DecodeOperatorAdded(types.Log{
+			Topics:      l.Topics,
+			Data:        l.Data,
+			BlockNumber: uint64(l.BlockNumber),
+			TxHash:      l.TxHash,
+		})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, OperatorEvent{
+			Block:    uint64(l.BlockNumber),
+			TxHash:   l.TxHash,
+			Operator: event.NewOperator,
+			Added:    event.IsAdd,
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,121 @@
+package blockchain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// signTxArgs is the tx to sign in the format of clef's account_signTransaction
+type signTxArgs struct {
+	From     ethereum.Address  `json:"from"`
+	To       *ethereum.Address `json:"to"`
+	Gas      hexutil.Uint64    `json:"gas"`
+	GasPrice *hexutil.Big      `json:"gasPrice"`
+	Value    *hexutil.Big      `json:"value"`
+	Nonce    hexutil.Uint64    `json:"nonce"`
+	Data     hexutil.Bytes     `json:"data"`
+}
+
+type signTxResult struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+// RemoteSigner signs txs with an external signing service (clef style
+// account_signTransaction) over http or a unix socket, so the key never
+// lives on the feeder host
+type RemoteSigner struct {
+	endpoint string
+	client   *rpc.Client
+	address  ethereum.Address
+	timeout  time.Duration
+}
+
+func (self *RemoteSigner) GetAddress() ethereum.Address {
+	return self.address
+}
+
+func txSender(tx *types.Transaction) (ethereum.Address, error) {
+	if tx.Protected() {
+		return types.Sender(types.NewEIP155Signer(tx.ChainId()), tx)
+	}
+	return types.Sender(types.HomesteadSigner{}, tx)
+}
+
+// checkSigned makes sure the signer signed tx as it was asked, from our
+// address
+func (self *RemoteSigner) checkSigned(tx *types.Transaction, signed *types.Transaction) error {
+	from, err := txSender(signed)
+	if err != nil {
+		return err
+	}
+	if from != self.address {
+		return errors.New(fmt.Sprintf("tx is signed by %s instead of %s", from.Hex(), self.address.Hex()))
+	}
+	sameTo := (tx.To() == nil && signed.To() == nil) ||
+		(tx.To() != nil && signed.To() != nil && *tx.To() == *signed.To())
+	if !sameTo || tx.Nonce() != signed.Nonce() || tx.Gas().Cmp(signed.Gas()) != 0 ||
+		tx.GasPrice().Cmp(signed.GasPrice()) != 0 || tx.Value().Cmp(signed.Value()) != 0 ||
+		!bytes.Equal(tx.Data(), signed.Data()) {
+		return errors.New("signed tx doesn't match the tx to sign")
+	}
+	return nil
+}
+
+func (self *RemoteSigner) Sign(tx *types.Transaction) (*types.Transaction, error) {
+	args := signTxArgs{
+		From:     self.address,
+		To:       tx.To(),
+		Gas:      hexutil.Uint64(tx.Gas().Uint64()),
+		GasPrice: (*hexutil.Big)(tx.GasPrice()),
+		Value:    (*hexutil.Big)(tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     hexutil.Bytes(tx.Data()),
+	}
+	timeout, cancel := context.WithTimeout(context.Background(), self.timeout)
+	defer cancel()
+	result := signTxResult{}
+	if err := self.client.CallContext(timeout, &result, "account_signTransaction", args); err != nil {
+		return nil, errors.New(fmt.Sprintf("Remote signer %s failed to sign tx: %s", self.endpoint, err))
+	}
+	signed := &types.Transaction{}
+	if err := rlp.DecodeBytes(result.Raw, signed); err != nil {
+		return nil, errors.New(fmt.Sprintf("Remote signer %s returned an invalid tx: %s", self.endpoint, err))
+	}
+	if err := self.checkSigned(tx, signed); err != nil {
+		return nil, errors.New(fmt.Sprintf("Remote signer %s returned an unexpected tx: %s", self.endpoint, err))
+	}
+	return signed, nil
+}
+
+// NewRemoteSigner connects to the signer at endpoint, an http url or the
+// path of a unix socket, and checks it manages address.
+// The signer must approve signing requests within timeout.
+func NewRemoteSigner(endpoint string, address ethereum.Address, timeout time.Duration) (*RemoteSigner, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var accounts []ethereum.Address
+	if err := client.CallContext(ctx, &accounts, "account_list"); err != nil {
+		return nil, errors.New(fmt.Sprintf("Listing accounts of remote signer %s failed: %s", endpoint, err))
+	}
+	for _, account := range accounts {
+		if account == address {
+			logging.Std().Infof("remote signer %s manages %s", endpoint, address.Hex())
+			return &RemoteSigner{endpoint, client, address, timeout}, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("Remote signer %s doesn't manage %s", endpoint, address.Hex()))
+}
@@ -0,0 +1,48 @@
+package blockchain_test
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	backend := simulation.NewBackend()
+	defer backend.Close()
+	server := simulation.NewSignerServer(backend.OperatorKey, filepath.Join(backend.Dir, "signer.ipc"))
+	defer server.Close()
+
+	for _, endpoint := range []string{server.URL(), server.Socket()} {
+		signer, err := blockchain.NewRemoteSigner(endpoint, backend.Operator(), time.Second)
+		if err != nil {
+			t.Fatalf("Expected to connect to the signer at %s but got error: %v", endpoint, err)
+		}
+		tx := types.NewTransaction(3, backend.Operator(), big.NewInt(0), big.NewInt(21000), big.NewInt(1000000000), nil)
+		signed, err := signer.Sign(tx)
+		if err != nil {
+			t.Fatalf("Expected to sign at %s but got error: %v", endpoint, err)
+		}
+		from, err := types.Sender(types.HomesteadSigner{}, signed)
+		if err != nil || from != backend.Operator() || signed.Nonce() != 3 {
+			t.Fatalf("Expected the tx to be signed by %s, got %s", backend.Operator().Hex(), from.Hex())
+		}
+	}
+}
+
+func TestRemoteSignerChecksAddress(t *testing.T) {
+	backend := simulation.NewBackend()
+	defer backend.Close()
+	server := simulation.NewSignerServer(backend.OperatorKey, filepath.Join(backend.Dir, "signer.ipc"))
+	defer server.Close()
+
+	other := ethereum.HexToAddress("0x0000000000000000000000000000000000000001")
+	if _, err := blockchain.NewRemoteSigner(server.URL(), other, time.Second); err == nil {
+		t.Fatalf("Expected the signer to be rejected as it doesn't manage %s", other.Hex())
+	}
+}
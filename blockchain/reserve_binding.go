@@ -0,0 +1,123 @@
+package blockchain
+
+//go:generate go run gen_abi.go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/KyberNetwork/reserve-data/common/blockchain"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// reserveABI is RESERVE_ABI parsed, the binary doesn't need reserve.abi
+// at runtime
+var reserveABI = mustParseReserveABI()
+
+func mustParseReserveABI() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(RESERVE_ABI))
+	if err != nil {
+		panic(err)
+	}
+	if err := checkBindings(parsed); err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// checkBindings makes sure the events of this package match parsed, ie
+// after reserve.abi is regenerated. Functions are called through the
+// wrappers generated in reserve_calls.go, which don't build when they
+// don't match their callers.
+func checkBindings(parsed abi.ABI) error {
+	for _, e := range reserveEvents {
+		event, found := parsed.Events[e.name]
+		if !found {
+			return errors.New(fmt.Sprintf("reserve abi has no event %s", e.name))
+		}
+		indexed := []bool{}
+		for _, input := range event.Inputs {
+			indexed = append(indexed, input.Indexed)
+		}
+		if event.Id() != e.topic() || fmt.Sprint(indexed) != fmt.Sprint(e.indexed) {
+			return errors.New(fmt.Sprintf("reserve abi event %s doesn't match binding %s with indexed inputs %v", e.name, e.signature, e.indexed))
+		}
+	}
+	return nil
+}
+
+// ReserveABI returns the abi of the reserve, ie for a stand-in of the
+// reserve contract
+func ReserveABI() abi.ABI {
+	return reserveABI
+}
+
+func newReserveContract(address ethereum.Address) *blockchain.Contract {
+	return &blockchain.Contract{Address: address, ABI: reserveABI}
+}
+
+// buildSetPriceFeed builds a setPriceFeed tx with opts
+func (self *DGXReserve) buildSetPriceFeed(ctx context.Context, opts blockchain.TxOpts, blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte) (*types.Transaction, error) {
+	data, err := packSetPriceFeed(blockNumber, nonce, ask1KDigix, bid1KDigix, v, r, s)
+	if err != nil {
+		return nil, err
+	}
+	return self.buildTx(ctx, opts, self.reserveAddr, data)
+}
+
+// reserveEvent is an event of the reserve abi, it is checked against
+// the abi when the package is loaded
+type reserveEvent struct {
+	name      string
+	signature string
+	// indexed tells which inputs are topics, in the order of the inputs
+	indexed []bool
+}
+
+func (self reserveEvent) topic() ethereum.Hash {
+	return crypto.Keccak256Hash([]byte(self.signature))
+}
+
+// words returns the 32 bytes values of the inputs of the event l, from
+// the topics for indexed inputs and from the data for the others
+func (self reserveEvent) words(l types.Log) ([][]byte, error) {
+	if len(l.Topics) == 0 || l.Topics[0] != self.topic() {
+		return nil, errors.New(fmt.Sprintf("log %d of tx %s is not a %s event", l.Index, l.TxHash.Hex(), self.name))
+	}
+	result := [][]byte{}
+	topics, data := l.Topics[1:], l.Data
+	for _, indexed := range self.indexed {
+		switch {
+		case indexed && len(topics) > 0:
+			result = append(result, topics[0].Bytes())
+			topics = topics[1:]
+		case !indexed && len(data) >= 32:
+			result = append(result, data[:32])
+			data = data[32:]
+		default:
+			return nil, errors.New(fmt.Sprintf("invalid %s event in tx %s", self.name, l.TxHash.Hex()))
+		}
+	}
+	if len(topics) != 0 || len(data) != 0 {
+		return nil, errors.New(fmt.Sprintf("invalid %s event in tx %s", self.name, l.TxHash.Hex()))
+	}
+	return result, nil
+}
+
+func wordAddress(word []byte) ethereum.Address {
+	return ethereum.BytesToAddress(word[12:])
+}
+
+func wordUint(word []byte) *big.Int {
+	return new(big.Int).SetBytes(word)
+}
+
+func wordBool(word []byte) bool {
+	return word[31] != 0
+}
@@ -0,0 +1,64 @@
+package blockchain_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestEmbeddedABIIsUpToDate(t *testing.T) {
+	data, err := ioutil.ReadFile("reserve.abi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file, embedded interface{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(blockchain.RESERVE_ABI), &embedded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(file, embedded) {
+		t.Fatalf("Expected RESERVE_ABI to match reserve.abi, run go generate in blockchain")
+	}
+}
+
+func TestDecodeEvents(t *testing.T) {
+	origin := ethereum.HexToAddress("0x1111111111111111111111111111111111111111")
+	dest := ethereum.HexToAddress("0x2222222222222222222222222222222222222222")
+	word := func(b []byte) []byte { return ethereum.LeftPadBytes(b, 32) }
+	data := []byte{}
+	for _, w := range [][]byte{
+		word(blockchain.ETH_TOKEN.Bytes()), math.PaddedBigBytes(big.NewInt(1000), 32),
+		word(dest.Bytes()), math.PaddedBigBytes(big.NewInt(7), 32), word(origin.Bytes()),
+	} {
+		data = append(data, w...)
+	}
+	trade := types.Log{
+		Topics: []ethereum.Hash{blockchain.ReserveABI().Events["TradeExecute"].Id(), ethereum.BytesToHash(origin.Bytes())},
+		Data:   data,
+	}
+	event, err := blockchain.DecodeTradeExecute(trade)
+	if err != nil {
+		t.Fatalf("Expected a TradeExecute event, got %s", err)
+	}
+	// origin is indexed, the other inputs are in the data
+	if event.Origin != origin || event.Src != blockchain.ETH_TOKEN || event.SrcAmount.Int64() != 1000 ||
+		event.DestToken != dest || event.DestAmount.Int64() != 7 || event.DestAddress != origin {
+		t.Fatalf("Expected the inputs of the trade, got %+v", event)
+	}
+	if _, err := blockchain.DecodeOperatorAdded(trade); err == nil {
+		t.Fatalf("Expected a TradeExecute log not to decode as OperatorAdded")
+	}
+	trade.Data = data[:64]
+	if _, err := blockchain.DecodeTradeExecute(trade); err == nil {
+		t.Fatalf("Expected a truncated TradeExecute log to be invalid")
+	}
+}
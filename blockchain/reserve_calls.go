@@ -0,0 +1,247 @@
+// Code generated by gen_abi.go from reserve.abi. DO NOT EDIT.
+
+package blockchain
+
+import (
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// packEnableTrade packs a call of enableTrade()
+func packEnableTrade() ([]byte, error) {
+	return reserveABI.Pack("enableTrade")
+}
+
+// packRemoveAlerter packs a call of removeAlerter(address)
+func packRemoveAlerter(alerter ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("removeAlerter", alerter)
+}
+
+// packSetKyberNetworkAddress packs a call of setKyberNetworkAddress(address)
+func packSetKyberNetworkAddress(kyberNetwork ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("setKyberNetworkAddress", kyberNetwork)
+}
+
+// callPendingAdmin calls pendingAdmin() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callPendingAdmin(atBlock uint64) (ethereum.Address, error) {
+	var result ethereum.Address
+	err := self.call(atBlock, &result, "pendingAdmin")
+	return result, err
+}
+
+// callGetOperators calls getOperators() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callGetOperators(atBlock uint64) ([]ethereum.Address, error) {
+	var result []ethereum.Address
+	err := self.call(atBlock, &result, "getOperators")
+	return result, err
+}
+
+// packSetMaxBlockDrift packs a call of setMaxBlockDrift(uint256)
+func packSetMaxBlockDrift(numBlocks *big.Int) ([]byte, error) {
+	return reserveABI.Pack("setMaxBlockDrift", numBlocks)
+}
+
+// packSetSellFeeBps packs a call of setSellFeeBps(uint256)
+func packSetSellFeeBps(fee *big.Int) ([]byte, error) {
+	return reserveABI.Pack("setSellFeeBps", fee)
+}
+
+// packWithdrawToken packs a call of withdrawToken(address,uint256,address)
+func packWithdrawToken(token ethereum.Address, amount *big.Int, sendTo ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("withdrawToken", token, amount, sendTo)
+}
+
+// packAddAlerter packs a call of addAlerter(address)
+func packAddAlerter(newAlerter ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("addAlerter", newAlerter)
+}
+
+// callSanityRatesContract calls sanityRatesContract() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callSanityRatesContract(atBlock uint64) (ethereum.Address, error) {
+	var result ethereum.Address
+	err := self.call(atBlock, &result, "sanityRatesContract")
+	return result, err
+}
+
+// callDigix calls digix() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callDigix(atBlock uint64) (ethereum.Address, error) {
+	var result ethereum.Address
+	err := self.call(atBlock, &result, "digix")
+	return result, err
+}
+
+// packApproveWithdrawAddress packs a call of approveWithdrawAddress(address,address,bool)
+func packApproveWithdrawAddress(token ethereum.Address, addr ethereum.Address, approve bool) ([]byte, error) {
+	return reserveABI.Pack("approveWithdrawAddress", token, addr, approve)
+}
+
+// packWithdraw packs a call of withdraw(address,uint256,address)
+func packWithdraw(token ethereum.Address, amount *big.Int, destination ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("withdraw", token, amount, destination)
+}
+
+// packDisableTrade packs a call of disableTrade()
+func packDisableTrade() ([]byte, error) {
+	return reserveABI.Pack("disableTrade")
+}
+
+// packTrade packs a call of trade(address,uint256,address,address,uint256,bool)
+func packTrade(srcToken ethereum.Address, srcAmount *big.Int, destToken ethereum.Address, destAddress ethereum.Address, conversionRate *big.Int, validate bool) ([]byte, error) {
+	return reserveABI.Pack("trade", srcToken, srcAmount, destToken, destAddress, conversionRate, validate)
+}
+
+// callPriceFeed calls priceFeed() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callPriceFeed(atBlock uint64) (*big.Int, error) {
+	var result *big.Int
+	err := self.call(atBlock, &result, "priceFeed")
+	return result, err
+}
+
+// packTransferAdmin packs a call of transferAdmin(address)
+func packTransferAdmin(newAdmin ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("transferAdmin", newAdmin)
+}
+
+// callMakerDaoContract calls makerDaoContract() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callMakerDaoContract(atBlock uint64) (ethereum.Address, error) {
+	var result ethereum.Address
+	err := self.call(atBlock, &result, "makerDaoContract")
+	return result, err
+}
+
+// packClaimAdmin packs a call of claimAdmin()
+func packClaimAdmin() ([]byte, error) {
+	return reserveABI.Pack("claimAdmin")
+}
+
+// packTransferAdminQuickly packs a call of transferAdminQuickly(address)
+func packTransferAdminQuickly(newAdmin ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("transferAdminQuickly", newAdmin)
+}
+
+// callGetAlerters calls getAlerters() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callGetAlerters(atBlock uint64) ([]ethereum.Address, error) {
+	var result []ethereum.Address
+	err := self.call(atBlock, &result, "getAlerters")
+	return result, err
+}
+
+// callGetConversionRate calls getConversionRate(address,address,uint256,uint256) of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callGetConversionRate(atBlock uint64, src ethereum.Address, dest ethereum.Address, srcQty *big.Int, blockNumber *big.Int) (*big.Int, error) {
+	var result *big.Int
+	err := self.call(atBlock, &result, "getConversionRate", src, dest, srcQty, blockNumber)
+	return result, err
+}
+
+// packSetMakerDaoContract packs a call of setMakerDaoContract(address)
+func packSetMakerDaoContract(daoContract ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("setMakerDaoContract", daoContract)
+}
+
+// packSetBuyFeeBps packs a call of setBuyFeeBps(uint256)
+func packSetBuyFeeBps(fee *big.Int) ([]byte, error) {
+	return reserveABI.Pack("setBuyFeeBps", fee)
+}
+
+// packAddOperator packs a call of addOperator(address)
+func packAddOperator(newOperator ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("addOperator", newOperator)
+}
+
+// callGetPriceFeed calls getPriceFeed() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callGetPriceFeed(atBlock uint64) (feedBlock *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, err error) {
+	result := &struct {
+		FeedBlock  *big.Int
+		Nonce      *big.Int
+		Ask1KDigix *big.Int
+		Bid1KDigix *big.Int
+	}{}
+	err = self.call(atBlock, result, "getPriceFeed")
+	return result.FeedBlock, result.Nonce, result.Ask1KDigix, result.Bid1KDigix, err
+}
+
+// packRemoveOperator packs a call of removeOperator(address)
+func packRemoveOperator(operator ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("removeOperator", operator)
+}
+
+// callKyberNetwork calls kyberNetwork() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callKyberNetwork(atBlock uint64) (ethereum.Address, error) {
+	var result ethereum.Address
+	err := self.call(atBlock, &result, "kyberNetwork")
+	return result, err
+}
+
+// callMaxBlockDrift calls maxBlockDrift() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callMaxBlockDrift(atBlock uint64) (*big.Int, error) {
+	var result *big.Int
+	err := self.call(atBlock, &result, "maxBlockDrift")
+	return result, err
+}
+
+// packWithdrawEther packs a call of withdrawEther(uint256,address)
+func packWithdrawEther(amount *big.Int, sendTo ethereum.Address) ([]byte, error) {
+	return reserveABI.Pack("withdrawEther", amount, sendTo)
+}
+
+// callConversionRatesContract calls conversionRatesContract() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callConversionRatesContract(atBlock uint64) (ethereum.Address, error) {
+	var result ethereum.Address
+	err := self.call(atBlock, &result, "conversionRatesContract")
+	return result, err
+}
+
+// callTradeEnabled calls tradeEnabled() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callTradeEnabled(atBlock uint64) (bool, error) {
+	var result bool
+	err := self.call(atBlock, &result, "tradeEnabled")
+	return result, err
+}
+
+// callApprovedWithdrawAddresses calls approvedWithdrawAddresses(bytes32) of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callApprovedWithdrawAddresses(atBlock uint64, arg0 [32]byte) (bool, error) {
+	var result bool
+	err := self.call(atBlock, &result, "approvedWithdrawAddresses", arg0)
+	return result, err
+}
+
+// packSetPriceFeed packs a call of setPriceFeed(uint256,uint256,uint256,uint256,uint8,bytes32,bytes32)
+func packSetPriceFeed(blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte) ([]byte, error) {
+	return reserveABI.Pack("setPriceFeed", blockNumber, nonce, ask1KDigix, bid1KDigix, v, r, s)
+}
+
+// callBuyTransferFee calls buyTransferFee() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callBuyTransferFee(atBlock uint64) (*big.Int, error) {
+	var result *big.Int
+	err := self.call(atBlock, &result, "buyTransferFee")
+	return result, err
+}
+
+// callSellTransferFee calls sellTransferFee() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callSellTransferFee(atBlock uint64) (*big.Int, error) {
+	var result *big.Int
+	err := self.call(atBlock, &result, "sellTransferFee")
+	return result, err
+}
+
+// callAdmin calls admin() of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callAdmin(atBlock uint64) (ethereum.Address, error) {
+	var result ethereum.Address
+	err := self.call(atBlock, &result, "admin")
+	return result, err
+}
+
+// callGetBalance calls getBalance(address) of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callGetBalance(atBlock uint64, token ethereum.Address) (*big.Int, error) {
+	var result *big.Int
+	err := self.call(atBlock, &result, "getBalance", token)
+	return result, err
+}
+
+// callGetDestQty calls getDestQty(address,address,uint256,uint256) of the reserve at block atBlock, 0 means the latest block
+func (self *DGXReserve) callGetDestQty(atBlock uint64, src ethereum.Address, dest ethereum.Address, srcQty *big.Int, rate *big.Int) (*big.Int, error) {
+	var result *big.Int
+	err := self.call(atBlock, &result, "getDestQty", src, dest, srcQty, rate)
+	return result, err
+}
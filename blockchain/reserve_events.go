@@ -0,0 +1,229 @@
+package blockchain
+
+import (
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Typed events of the reserve abi. Each Decode function returns an error
+// if the log is not an event of its kind.
+
+var (
+	eventTradeExecute            = reserveEvent{"TradeExecute", "TradeExecute(address,address,uint256,address,uint256,address)", []bool{true, false, false, false, false, false}}
+	eventTradeEnabled            = reserveEvent{"TradeEnabled", "TradeEnabled(bool)", []bool{false}}
+	eventWithdrawAddressApproved = reserveEvent{"WithdrawAddressApproved", "WithdrawAddressApproved(address,address,bool)", []bool{false, false, false}}
+	eventWithdrawFunds           = reserveEvent{"WithdrawFunds", "WithdrawFunds(address,uint256,address)", []bool{false, false, false}}
+	eventTokenWithdraw           = reserveEvent{"TokenWithdraw", "TokenWithdraw(address,uint256,address)", []bool{false, false, false}}
+	eventEtherWithdraw           = reserveEvent{"EtherWithdraw", "EtherWithdraw(uint256,address)", []bool{false, false}}
+	eventTransferAdminPending    = reserveEvent{"TransferAdminPending", "TransferAdminPending(address)", []bool{false}}
+	eventAdminClaimed            = reserveEvent{"AdminClaimed", "AdminClaimed(address,address)", []bool{false, false}}
+	eventAlerterAdded            = reserveEvent{"AlerterAdded", "AlerterAdded(address,bool)", []bool{false, false}}
+	eventOperatorAdded           = reserveEvent{"OperatorAdded", "OperatorAdded(address,bool)", []bool{false, false}}
+
+	reserveEvents = []reserveEvent{
+		eventTradeExecute, eventTradeEnabled, eventWithdrawAddressApproved, eventWithdrawFunds, eventTokenWithdraw,
+		eventEtherWithdraw, eventTransferAdminPending, eventAdminClaimed, eventAlerterAdded, eventOperatorAdded,
+	}
+)
+
+// TradeExecuteEvent is an executed trade
+type TradeExecuteEvent struct {
+	Origin      ethereum.Address
+	Src         ethereum.Address
+	SrcAmount   *big.Int
+	DestToken   ethereum.Address
+	DestAmount  *big.Int
+	DestAddress ethereum.Address
+	Raw         types.Log
+}
+
+func DecodeTradeExecute(l types.Log) (*TradeExecuteEvent, error) {
+	words, err := eventTradeExecute.words(l)
+	if err != nil {
+		return nil, err
+	}
+	return &TradeExecuteEvent{
+		Origin:      wordAddress(words[0]),
+		Src:         wordAddress(words[1]),
+		SrcAmount:   wordUint(words[2]),
+		DestToken:   wordAddress(words[3]),
+		DestAmount:  wordUint(words[4]),
+		DestAddress: wordAddress(words[5]),
+		Raw:         l,
+	}, nil
+}
+
+// TradeEnabledEvent is trades being enabled or disabled
+type TradeEnabledEvent struct {
+	Enable bool
+	Raw    types.Log
+}
+
+func DecodeTradeEnabled(l types.Log) (*TradeEnabledEvent, error) {
+	words, err := eventTradeEnabled.words(l)
+	if err != nil {
+		return nil, err
+	}
+	return &TradeEnabledEvent{
+		Enable: wordBool(words[0]),
+		Raw:    l,
+	}, nil
+}
+
+// WithdrawAddressApprovedEvent is an address being approved or not to receive withdrawals of a token
+type WithdrawAddressApprovedEvent struct {
+	Token   ethereum.Address
+	Addr    ethereum.Address
+	Approve bool
+	Raw     types.Log
+}
+
+func DecodeWithdrawAddressApproved(l types.Log) (*WithdrawAddressApprovedEvent, error) {
+	words, err := eventWithdrawAddressApproved.words(l)
+	if err != nil {
+		return nil, err
+	}
+	return &WithdrawAddressApprovedEvent{
+		Token:   wordAddress(words[0]),
+		Addr:    wordAddress(words[1]),
+		Approve: wordBool(words[2]),
+		Raw:     l,
+	}, nil
+}
+
+// WithdrawFundsEvent is a withdrawal to an approved address
+type WithdrawFundsEvent struct {
+	Token       ethereum.Address
+	Amount      *big.Int
+	Destination ethereum.Address
+	Raw         types.Log
+}
+
+func DecodeWithdrawFunds(l types.Log) (*WithdrawFundsEvent, error) {
+	words, err := eventWithdrawFunds.words(l)
+	if err != nil {
+		return nil, err
+	}
+	return &WithdrawFundsEvent{
+		Token:       wordAddress(words[0]),
+		Amount:      wordUint(words[1]),
+		Destination: wordAddress(words[2]),
+		Raw:         l,
+	}, nil
+}
+
+// TokenWithdrawEvent is a token withdrawal by the admin
+type TokenWithdrawEvent struct {
+	Token  ethereum.Address
+	Amount *big.Int
+	SendTo ethereum.Address
+	Raw    types.Log
+}
+
+func DecodeTokenWithdraw(l types.Log) (*TokenWithdrawEvent, error) {
+	words, err := eventTokenWithdraw.words(l)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenWithdrawEvent{
+		Token:  wordAddress(words[0]),
+		Amount: wordUint(words[1]),
+		SendTo: wordAddress(words[2]),
+		Raw:    l,
+	}, nil
+}
+
+// EtherWithdrawEvent is an ETH withdrawal by the admin
+type EtherWithdrawEvent struct {
+	Amount *big.Int
+	SendTo ethereum.Address
+	Raw    types.Log
+}
+
+func DecodeEtherWithdraw(l types.Log) (*EtherWithdrawEvent, error) {
+	words, err := eventEtherWithdraw.words(l)
+	if err != nil {
+		return nil, err
+	}
+	return &EtherWithdrawEvent{
+		Amount: wordUint(words[0]),
+		SendTo: wordAddress(words[1]),
+		Raw:    l,
+	}, nil
+}
+
+// TransferAdminPendingEvent is an admin transfer waiting to be claimed
+type TransferAdminPendingEvent struct {
+	PendingAdmin ethereum.Address
+	Raw          types.Log
+}
+
+func DecodeTransferAdminPending(l types.Log) (*TransferAdminPendingEvent, error) {
+	words, err := eventTransferAdminPending.words(l)
+	if err != nil {
+		return nil, err
+	}
+	return &TransferAdminPendingEvent{
+		PendingAdmin: wordAddress(words[0]),
+		Raw:          l,
+	}, nil
+}
+
+// AdminClaimedEvent is an admin transfer being claimed
+type AdminClaimedEvent struct {
+	NewAdmin      ethereum.Address
+	PreviousAdmin ethereum.Address
+	Raw           types.Log
+}
+
+func DecodeAdminClaimed(l types.Log) (*AdminClaimedEvent, error) {
+	words, err := eventAdminClaimed.words(l)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminClaimedEvent{
+		NewAdmin:      wordAddress(words[0]),
+		PreviousAdmin: wordAddress(words[1]),
+		Raw:           l,
+	}, nil
+}
+
+// AlerterAddedEvent is an alerter being added or removed
+type AlerterAddedEvent struct {
+	NewAlerter ethereum.Address
+	IsAdd      bool
+	Raw        types.Log
+}
+
+func DecodeAlerterAdded(l types.Log) (*AlerterAddedEvent, error) {
+	words, err := eventAlerterAdded.words(l)
+	if err != nil {
+		return nil, err
+	}
+	return &AlerterAddedEvent{
+		NewAlerter: wordAddress(words[0]),
+		IsAdd:      wordBool(words[1]),
+		Raw:        l,
+	}, nil
+}
+
+// OperatorAddedEvent is an operator being added or removed
+type OperatorAddedEvent struct {
+	NewOperator ethereum.Address
+	IsAdd       bool
+	Raw         types.Log
+}
+
+func DecodeOperatorAdded(l types.Log) (*OperatorAddedEvent, error) {
+	words, err := eventOperatorAdded.words(l)
+	if err != nil {
+		return nil, err
+	}
+	return &OperatorAddedEvent{
+		NewOperator: wordAddress(words[0]),
+		IsAdd:       wordBool(words[1]),
+		Raw:         l,
+	}, nil
+}
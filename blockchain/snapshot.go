@@ -0,0 +1,105 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// ReserveSnapshot is the configuration and balances of the reserve at a
+// block, as returned by its view functions
+type ReserveSnapshot struct {
+	Block                   uint64             `json:"block"`
+	Reserve                 ethereum.Address   `json:"reserve"`
+	Admin                   ethereum.Address   `json:"admin"`
+	PendingAdmin            ethereum.Address   `json:"pending_admin"`
+	Operators               []ethereum.Address `json:"operators"`
+	Alerters                []ethereum.Address `json:"alerters"`
+	Digix                   ethereum.Address   `json:"digix"`
+	KyberNetwork            ethereum.Address   `json:"kyber_network"`
+	MakerDaoContract        ethereum.Address   `json:"maker_dao_contract"`
+	ConversionRatesContract ethereum.Address   `json:"conversion_rates_contract"`
+	SanityRatesContract     ethereum.Address   `json:"sanity_rates_contract"`
+	BuyTransferFee          *big.Int           `json:"buy_transfer_fee"`
+	SellTransferFee         *big.Int           `json:"sell_transfer_fee"`
+	TradeEnabled            bool               `json:"trade_enabled"`
+	MaxBlockDrift           *big.Int           `json:"max_block_drift"`
+	PriceFeed               *big.Int           `json:"price_feed"`
+	Feed                    *OnchainFeed       `json:"get_price_feed"`
+	// balances held by the reserve, DGX is the balance of the digix
+	// token
+	ETHBalance *big.Int `json:"eth_balance"`
+	DGXBalance *big.Int `json:"dgx_balance"`
+}
+
+// Snapshot reads every view function of the reserve at block atBlock, 0
+// means the latest block. All values are read at the same block.
+func (self *DGXReserve) Snapshot(atBlock uint64) (*ReserveSnapshot, error) {
+	if atBlock == 0 {
+		latest, err := self.LatestBlock()
+		if err != nil {
+			return nil, err
+		}
+		atBlock = latest
+	}
+	result := &ReserveSnapshot{Block: atBlock, Reserve: self.reserveAddr}
+	var err error
+	// wrap tells which view function failed
+	wrap := func(method string, err error) error {
+		return errors.New(fmt.Sprintf("%s failed: %s", method, err))
+	}
+	if result.Admin, err = self.Admin(atBlock); err != nil {
+		return nil, wrap("admin", err)
+	}
+	if result.PendingAdmin, err = self.PendingAdmin(atBlock); err != nil {
+		return nil, wrap("pendingAdmin", err)
+	}
+	if result.Operators, err = self.GetOperators(atBlock); err != nil {
+		return nil, wrap("getOperators", err)
+	}
+	if result.Alerters, err = self.GetAlerters(atBlock); err != nil {
+		return nil, wrap("getAlerters", err)
+	}
+	if result.Digix, err = self.Digix(atBlock); err != nil {
+		return nil, wrap("digix", err)
+	}
+	if result.KyberNetwork, err = self.KyberNetwork(atBlock); err != nil {
+		return nil, wrap("kyberNetwork", err)
+	}
+	if result.MakerDaoContract, err = self.MakerDaoContract(atBlock); err != nil {
+		return nil, wrap("makerDaoContract", err)
+	}
+	if result.ConversionRatesContract, err = self.ConversionRatesContract(atBlock); err != nil {
+		return nil, wrap("conversionRatesContract", err)
+	}
+	if result.SanityRatesContract, err = self.SanityRatesContract(atBlock); err != nil {
+		return nil, wrap("sanityRatesContract", err)
+	}
+	if result.BuyTransferFee, err = self.BuyTransferFee(atBlock); err != nil {
+		return nil, wrap("buyTransferFee", err)
+	}
+	if result.SellTransferFee, err = self.SellTransferFee(atBlock); err != nil {
+		return nil, wrap("sellTransferFee", err)
+	}
+	if result.TradeEnabled, err = self.TradeEnabled(atBlock); err != nil {
+		return nil, wrap("tradeEnabled", err)
+	}
+	if result.MaxBlockDrift, err = self.MaxBlockDrift(atBlock); err != nil {
+		return nil, wrap("maxBlockDrift", err)
+	}
+	if result.PriceFeed, err = self.PriceFeed(atBlock); err != nil {
+		return nil, wrap("priceFeed", err)
+	}
+	if result.Feed, err = self.GetPriceFeed(atBlock); err != nil {
+		return nil, wrap("getPriceFeed", err)
+	}
+	if result.ETHBalance, err = self.GetBalance(ETH_TOKEN, atBlock); err != nil {
+		return nil, wrap("getBalance(ETH)", err)
+	}
+	if result.DGXBalance, err = self.GetBalance(result.Digix, atBlock); err != nil {
+		return nil, wrap("getBalance(DGX)", err)
+	}
+	return result, nil
+}
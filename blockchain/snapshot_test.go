@@ -0,0 +1,48 @@
+package blockchain_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSnapshot(t *testing.T) {
+	backend := simulation.NewBackend()
+	defer backend.Close()
+	digix := ethereum.HexToAddress("0x4f3afec4e5a3f2a6a1a411def7d7dfe50ee057bf")
+	network := ethereum.HexToAddress("0x818e6fecd516ecc3849daf6845e3ec868087b755")
+	backend.Chain.WithReserve(func(reserve *simulation.Reserve) {
+		reserve.SetContract("digix", digix)
+		reserve.SetContract("kyberNetwork", network)
+		reserve.SetTransferFees(big.NewInt(13), big.NewInt(13))
+		reserve.SetBalance(blockchain.ETH_TOKEN, big.NewInt(5000))
+		reserve.SetBalance(digix, big.NewInt(42))
+	})
+
+	snapshot, err := backend.Reserve.Snapshot(0)
+	if err != nil {
+		t.Fatalf("Expected a snapshot of the reserve, got %s", err)
+	}
+	if snapshot.Block != simulation.START_BLOCK {
+		t.Fatalf("Expected the snapshot at the latest block %d, got %d", simulation.START_BLOCK, snapshot.Block)
+	}
+	if snapshot.Admin != crypto.PubkeyToAddress(backend.AdminKey.PublicKey) {
+		t.Fatalf("Expected the admin of the reserve, got %s", snapshot.Admin.Hex())
+	}
+	if snapshot.Digix != digix || snapshot.KyberNetwork != network || snapshot.SanityRatesContract != (ethereum.Address{}) {
+		t.Fatalf("Expected the contracts of the reserve, got %+v", snapshot)
+	}
+	if snapshot.BuyTransferFee.Int64() != 13 || snapshot.SellTransferFee.Int64() != 13 {
+		t.Fatalf("Expected transfer fees of 13, got %s and %s", snapshot.BuyTransferFee, snapshot.SellTransferFee)
+	}
+	if snapshot.ETHBalance.Int64() != 5000 || snapshot.DGXBalance.Int64() != 42 {
+		t.Fatalf("Expected balances of 5000 wei and 42 DGX, got %s and %s", snapshot.ETHBalance, snapshot.DGXBalance)
+	}
+	if len(snapshot.Operators) != 1 || snapshot.Operators[0] != backend.Operator() || !snapshot.TradeEnabled {
+		t.Fatalf("Expected the pricing operator and trade enabled, got %+v", snapshot)
+	}
+}
@@ -0,0 +1,211 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// outcome of sending a tx to one endpoint
+const (
+	ACCEPTED           string = "accepted"
+	KNOWN              string = "known"
+	NONCE_TOO_LOW      string = "nonce_too_low"
+	UNDERPRICED        string = "underpriced"
+	INSUFFICIENT_FUNDS string = "insufficient_funds"
+	// the node answered with another error
+	REJECTED string = "rejected"
+	// the node couldn't be reached or timed out
+	UNREACHABLE string = "unreachable"
+
+	SEND_TIMEOUT time.Duration = 3 * time.Second
+)
+
+// number of endpoints per outcome, registered in the default registry
+var outcomes = map[string]metrics.Counter{}
+
+func init() {
+	for _, outcome := range []string{ACCEPTED, KNOWN, NONCE_TOO_LOW, UNDERPRICED, INSUFFICIENT_FUNDS, REJECTED, UNREACHABLE} {
+		outcomes[outcome] = metrics.GetOrRegisterCounter("feeder.broadcast."+outcome, nil)
+	}
+}
+
+// Client sends json rpc calls to one endpoint
+type Client interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// Classify tells the outcome of eth_sendRawTransaction from its error.
+// Messages are the ones of geth and parity.
+func Classify(err error) string {
+	if err == nil {
+		return ACCEPTED
+	}
+	if _, isRPCError := err.(rpc.Error); !isRPCError {
+		return UNREACHABLE
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "known transaction"),
+		strings.Contains(msg, "already known"),
+		strings.Contains(msg, "already imported"):
+		return KNOWN
+	case strings.Contains(msg, "nonce too low"),
+		strings.Contains(msg, "nonce is too low"):
+		return NONCE_TOO_LOW
+	case strings.Contains(msg, "underpriced"),
+		strings.Contains(msg, "gas price too low"):
+		return UNDERPRICED
+	case strings.Contains(msg, "insufficient funds"):
+		return INSUFFICIENT_FUNDS
+	default:
+		return REJECTED
+	}
+}
+
+// EndpointResult is the outcome of sending a tx to one endpoint
+type EndpointResult struct {
+	URL     string        `json:"url"`
+	Outcome string        `json:"outcome"`
+	Error   string        `json:"error,omitempty"`
+	Start   time.Time     `json:"start"`
+	Latency time.Duration `json:"latency"`
+}
+
+// Result is the outcome of broadcasting a tx to all endpoints, sorted by url
+type Result struct {
+	Hash      string           `json:"hash"`
+	Endpoints []EndpointResult `json:"endpoints"`
+}
+
+// Count returns the number of endpoints with the outcome
+func (self *Result) Count(outcome string) int {
+	count := 0
+	for _, e := range self.Endpoints {
+		if e.Outcome == outcome {
+			count++
+		}
+	}
+	return count
+}
+
+// Accepted tells if at least one endpoint has the tx in its pool
+func (self *Result) Accepted() bool {
+	return self.Count(ACCEPTED)+self.Count(KNOWN) > 0
+}
+
+// Rejected tells if no endpoint accepted the tx and every endpoint which
+// answered rejected it with outcome
+func (self *Result) Rejected(outcome string) bool {
+	return !self.Accepted() && self.Count(outcome) > 0 &&
+		self.Count(outcome)+self.Count(UNREACHABLE) == len(self.Endpoints)
+}
+
+func (self *Result) String() string {
+	parts := []string{}
+	for _, e := range self.Endpoints {
+		if e.Error == "" {
+			parts = append(parts, fmt.Sprintf("%s: %s", e.URL, e.Outcome))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %s (%s)", e.URL, e.Outcome, e.Error))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Err returns nil if the tx was accepted, an *Error otherwise
+func (self *Result) Err() error {
+	if self.Accepted() {
+		return nil
+	}
+	return &Error{self}
+}
+
+// Error is returned when no endpoint accepted the tx
+type Error struct {
+	Result *Result
+}
+
+func (self *Error) Error() string {
+	return fmt.Sprintf("Broadcasting tx %s failed: %s", self.Result.Hash, self.Result)
+}
+
+// ResultOf returns the broadcast result carried by err, nil if err is not
+// a broadcast error
+func ResultOf(err error) *Result {
+	if e, ok := err.(*Error); ok {
+		return e.Result
+	}
+	return nil
+}
+
+// Broadcaster sends signed txs to all its endpoints concurrently, each
+// with its own timeout
+type Broadcaster struct {
+	clients map[string]Client
+	timeout time.Duration
+}
+
+func (self *Broadcaster) send(url string, client Client, data hexutil.Bytes) EndpointResult {
+	timeout, cancel := context.WithTimeout(context.Background(), self.timeout)
+	defer cancel()
+	start := time.Now()
+	var hash hexutil.Bytes
+	err := client.CallContext(timeout, &hash, "eth_sendRawTransaction", data)
+	result := EndpointResult{
+		URL:     url,
+		Outcome: Classify(err),
+		Start:   start,
+		Latency: time.Since(start),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	outcomes[result.Outcome].Inc(1)
+	return result
+}
+
+// Broadcast sends tx to all endpoints and waits for all of them to answer
+// or time out
+func (self *Broadcaster) Broadcast(tx *types.Transaction) (*Result, error) {
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Hash: tx.Hash().Hex()}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for url, client := range self.clients {
+		wg.Add(1)
+		go func(url string, client Client) {
+			defer wg.Done()
+			r := self.send(url, client, data)
+			mu.Lock()
+			defer mu.Unlock()
+			result.Endpoints = append(result.Endpoints, r)
+		}(url, client)
+	}
+	wg.Wait()
+	sort.Slice(result.Endpoints, func(i, j int) bool { return result.Endpoints[i].URL < result.Endpoints[j].URL })
+	return result, nil
+}
+
+func NewBroadcaster(clients map[string]Client, timeout time.Duration) *Broadcaster {
+	if len(clients) == 0 {
+		panic(errors.New("broadcaster needs at least one endpoint"))
+	}
+	return &Broadcaster{
+		clients: clients,
+		timeout: timeout,
+	}
+}
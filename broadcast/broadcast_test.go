@@ -0,0 +1,88 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+type rpcError string
+
+func (self rpcError) Error() string  { return string(self) }
+func (self rpcError) ErrorCode() int { return -32000 }
+
+type fakeClient struct {
+	delay time.Duration
+	err   error
+}
+
+func (self fakeClient) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	select {
+	case <-time.After(self.delay):
+		return self.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestClassify(t *testing.T) {
+	cases := map[error]string{
+		nil:                                 ACCEPTED,
+		rpcError("known transaction: 0x01"): KNOWN,
+		rpcError("nonce too low"):           NONCE_TOO_LOW,
+		rpcError("replacement transaction underpriced"):           UNDERPRICED,
+		rpcError("insufficient funds for gas * price + value"):    INSUFFICIENT_FUNDS,
+		rpcError("exceeds block gas limit"):                       REJECTED,
+		errors.New("dial tcp 127.0.0.1:8545: connection refused"): UNREACHABLE,
+	}
+	for err, expected := range cases {
+		if outcome := Classify(err); outcome != expected {
+			t.Fatalf("Expected %v to be %s, got %s", err, expected, outcome)
+		}
+	}
+}
+
+func TestBroadcastIsConcurrent(t *testing.T) {
+	broadcaster := NewBroadcaster(map[string]Client{
+		"a": fakeClient{delay: 200 * time.Millisecond},
+		"b": fakeClient{delay: 200 * time.Millisecond, err: rpcError("nonce too low")},
+		"c": fakeClient{delay: time.Hour},
+	}, 300*time.Millisecond)
+	tx := types.NewTransaction(0, ethereum.Address{}, big.NewInt(0), big.NewInt(21000), big.NewInt(1), nil)
+
+	start := time.Now()
+	result, err := broadcaster.Broadcast(tx)
+	if err != nil {
+		t.Fatalf("Expected to broadcast but got error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 600*time.Millisecond {
+		t.Fatalf("Expected endpoints to be called concurrently, took %s", elapsed)
+	}
+	expected := []string{ACCEPTED, NONCE_TOO_LOW, UNREACHABLE}
+	for i, e := range result.Endpoints {
+		if e.Outcome != expected[i] {
+			t.Fatalf("Expected %s to be %s, got %s", e.URL, expected[i], e.Outcome)
+		}
+	}
+	if !result.Accepted() || result.Err() != nil {
+		t.Fatalf("Expected the tx to be accepted, got %s", result)
+	}
+}
+
+func TestRejected(t *testing.T) {
+	result := &Result{Endpoints: []EndpointResult{
+		{URL: "a", Outcome: UNDERPRICED},
+		{URL: "b", Outcome: UNREACHABLE},
+	}}
+	if !result.Rejected(UNDERPRICED) || result.Rejected(NONCE_TOO_LOW) {
+		t.Fatalf("Expected the tx to be rejected as underpriced only")
+	}
+	if ResultOf(result.Err()) != result {
+		t.Fatalf("Expected the error to carry the result")
+	}
+}
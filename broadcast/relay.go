@@ -0,0 +1,48 @@
+package broadcast
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// privateTx is the parameter of eth_sendPrivateTransaction
+type privateTx struct {
+	Tx             hexutil.Bytes  `json:"tx"`
+	MaxBlockNumber hexutil.Uint64 `json:"maxBlockNumber"`
+}
+
+// Relay sends txs to a private relay which forwards them to miners
+// without going through the public mempool
+type Relay struct {
+	url    string
+	client Client
+}
+
+func (self *Relay) URL() string {
+	return self.url
+}
+
+// Send asks the relay to include tx in a block up to maxBlock, after that
+// the relay drops it
+func (self *Relay) Send(tx *types.Transaction, maxBlock uint64) error {
+	data, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+	timeout, cancel := context.WithTimeout(context.Background(), SEND_TIMEOUT)
+	defer cancel()
+	var hash hexutil.Bytes
+	return self.client.CallContext(timeout, &hash, "eth_sendPrivateTransaction", privateTx{data, hexutil.Uint64(maxBlock)})
+}
+
+func NewRelay(url string) (*Relay, error) {
+	client, err := rpc.Dial(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Relay{url, client}, nil
+}
@@ -0,0 +1,90 @@
+package dgxpricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// cancelNonce returns the nonce to cancel when the user doesn't specify one:
+// the lowest journaled nonce which is not mined yet
+func (self *PriceFeeder) cancelNonce() (uint64, error) {
+	mined, err := self.reserve.MinedNonce()
+	if err != nil {
+		return 0, err
+	}
+	for _, n := range self.journal.PendingNonces(self.reserve.PricingAddress()) {
+		if n >= mined {
+			return n, nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("There is no journaled pending tx at or above mined nonce %d", mined))
+}
+
+// cancelGasPrice returns a gas price higher than every tx we know at txNonce,
+// from the journal and from the node's pool
+func (self *PriceFeeder) cancelGasPrice(ctx context.Context, txNonce uint64) *big.Int {
+	highest := big.NewInt(0)
+	txs := self.journal.TxsAtNonce(self.reserve.PricingAddress(), txNonce)
+	pending, queued, err := self.reserve.PendingTxs()
+	if err != nil {
+		self.log(ctx).Warnf("Couldn't get pending txs of the pricing operator: %s", err)
+	}
+	for _, tx := range append(pending, queued...) {
+		if tx.Nonce() == txNonce {
+			txs = append(txs, tx)
+		}
+	}
+	for _, tx := range txs {
+		if tx.GasPrice().Cmp(highest) > 0 {
+			highest = tx.GasPrice()
+		}
+	}
+	if highest.Sign() == 0 {
+		return big.NewInt(INIT_GASPRICE)
+	}
+	return bumpGasPrice(highest)
+}
+
+// Cancel sends a 0 ETH self transfer from the pricing operator at txNonce
+// to replace whatever tx is at that nonce. If txNonce is nil, the lowest
+// journaled pending nonce is used. The cancel is refused when it is over
+// the gas budget or the instance is not the leader, and waits for a
+// running cycle so they don't pick the same nonce. A cancel which might
+// be in a pool is returned without error even if sending it failed. The
+// returned tx is not monitored, callers should pass it to MonitorAndRetry.
+func (self *PriceFeeder) Cancel(txNonce *big.Int) (*types.Transaction, error) {
+	self.cycle.Lock()
+	defer self.cycle.Unlock()
+	ctx, end := self.beginCycle("cancel.cycle")
+	defer end()
+	if !self.IsLeader() {
+		return nil, ErrNotLeader
+	}
+	var n uint64
+	if txNonce == nil {
+		var err error
+		if n, err = self.cancelNonce(); err != nil {
+			return nil, err
+		}
+	} else {
+		n = txNonce.Uint64()
+	}
+	gasPrice := self.cancelGasPrice(ctx, n)
+	if err := self.checkBudget(ctx, gasPrice, CANCEL_GAS_ESTIMATE, 0); err != nil {
+		return nil, err
+	}
+	self.log(ctx).Infof("Cancelling nonce %d with gas price %s", n, gasPrice)
+	tx, err := self.reserve.SelfTransfer(ctx, big.NewInt(int64(n)), gasPrice)
+	if err != nil && (tx == nil || !mightBeInPool(err)) {
+		return nil, err
+	}
+	if err != nil {
+		self.log(ctx).Warnf("Sending cancel %s might have failed, it might be in a pool: %s", tx.Hash().Hex(), err)
+	}
+	self.record(ctx, "cancel", tx)
+	return tx, nil
+}
@@ -0,0 +1,54 @@
+package clock
+
+import (
+	"time"
+)
+
+// Clock is the source of time of the feeder, the monitor and the runners
+// so tests can control when txs are replaced and when ticks happen
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is the Clock of the time package
+type RealClock struct{}
+
+func (self RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (self RealClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (self RealClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (self RealClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (self realTicker) C() <-chan time.Time {
+	return self.ticker.C
+}
+
+func (self realTicker) Stop() {
+	self.ticker.Stop()
+}
+
+func NewRealClock() RealClock {
+	return RealClock{}
+}
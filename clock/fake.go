@@ -0,0 +1,129 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type waiter struct {
+	until time.Time
+	ch    chan time.Time
+}
+
+type fakeTicker struct {
+	clock   *FakeClock
+	period  time.Duration
+	next    time.Time
+	ch      chan time.Time
+	stopped bool
+}
+
+func (self *fakeTicker) C() <-chan time.Time {
+	return self.ch
+}
+
+func (self *fakeTicker) Stop() {
+	self.clock.mu.Lock()
+	defer self.clock.mu.Unlock()
+	self.stopped = true
+}
+
+// FakeClock only moves when Advance is called. Sleepers and tickers are
+// woken up by Advance, BlockUntil lets tests wait until the code under
+// test is sleeping before moving the time.
+// FakeClock is thread safe.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+	tickers []*fakeTicker
+}
+
+func (self *FakeClock) Now() time.Time {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.now
+}
+
+func (self *FakeClock) After(d time.Duration) <-chan time.Time {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- self.now
+		return ch
+	}
+	self.waiters = append(self.waiters, &waiter{self.now.Add(d), ch})
+	self.cond.Broadcast()
+	return ch
+}
+
+func (self *FakeClock) Sleep(d time.Duration) {
+	<-self.After(d)
+}
+
+func (self *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	ticker := &fakeTicker{
+		clock:  self,
+		period: d,
+		next:   self.now.Add(d),
+		// like time.Ticker, ticks are dropped for slow receivers
+		ch: make(chan time.Time, 1),
+	}
+	self.tickers = append(self.tickers, ticker)
+	return ticker
+}
+
+// Advance moves the time forward by d, firing sleepers and tickers
+// which are due
+func (self *FakeClock) Advance(d time.Duration) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.now = self.now.Add(d)
+	waiters := []*waiter{}
+	for _, w := range self.waiters {
+		if w.until.After(self.now) {
+			waiters = append(waiters, w)
+		} else {
+			w.ch <- self.now
+		}
+	}
+	self.waiters = waiters
+	for _, t := range self.tickers {
+		for !t.stopped && !t.next.After(self.now) {
+			select {
+			case t.ch <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+// Waiters returns the number of pending sleepers and After calls
+func (self *FakeClock) Waiters() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.waiters)
+}
+
+// BlockUntil blocks until at least n sleepers or After calls are pending
+func (self *FakeClock) BlockUntil(n int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for len(self.waiters) < n {
+		self.cond.Wait()
+	}
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	result := &FakeClock{now: now}
+	result.cond = sync.NewCond(&result.mu)
+	return result
+}
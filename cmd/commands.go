@@ -0,0 +1,811 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder"
+	"github.com/KyberNetwork/dgx-price-feeder/alert"
+	"github.com/KyberNetwork/dgx-price-feeder/api"
+	rsblockchain "github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/KyberNetwork/dgx-price-feeder/broadcast"
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/feed"
+	"github.com/KyberNetwork/dgx-price-feeder/history"
+	"github.com/KyberNetwork/dgx-price-feeder/journal"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/KyberNetwork/dgx-price-feeder/runner"
+	"github.com/KyberNetwork/dgx-price-feeder/secret"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const FEED_INTERVAL time.Duration = 30 * time.Minute
+
+// commonFlags are shared by all commands
+type commonFlags struct {
+	asJSON      *bool
+	digixSigner *string
+	stuckPolicy *string
+	wsEndpoint  *string
+	relay       *string
+	relayBlocks *uint64
+	signer      *string
+	signerAddr  *string
+	passphrase  *string
+	masterKey   *string
+	trim        *string
+	webhook     *string
+	keystores   *string
+	policy      *string
+	history     *string
+	budget      budgetFlags
+	retry       retryFlags
+	log         logFlags
+	trace       traceFlags
+}
+
+// traceFlags configure where the spans of feeding cycles are exported
+type traceFlags struct {
+	endpoint *string
+	service  *string
+}
+
+// logFlags configure the structured log
+type logFlags struct {
+	level      *string
+	format     *string
+	file       *string
+	maxSize    *int
+	maxAge     *int
+	maxBackups *int
+	rotate     *string
+}
+
+// retryFlags configure how a failed feed is retried
+type retryFlags struct {
+	attempts *int
+	delay    *time.Duration
+	maxDelay *time.Duration
+	jitter   *float64
+}
+
+// budgetFlags configure the gas budget, prices are in gwei and amounts
+// in ETH
+type budgetFlags struct {
+	maxGasPrice       *float64
+	maxReplacements   *int
+	daily             *float64
+	emergencyBlocks   *uint64
+	emergencyGasPrice *float64
+}
+
+func newFlagSet(name string) (*flag.FlagSet, commonFlags) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	return flags, commonFlags{
+		asJSON:      flags.Bool("json", false, "print the result as json"),
+		digixSigner: flags.String("digix-signer", os.Getenv("DIGIX_SIGNER"), "address feeds must be signed by, defaults to DIGIX_SIGNER"),
+		stuckPolicy: flags.String(
+			"stuck-policy", dgxpricing.STUCK_POLICY_REPORT,
+			"what to do with unknown pending txs of the pricing operator: report, cancel or refeed",
+		),
+		wsEndpoint:  flags.String("ws", "", "websocket endpoint to subscribe to new heads, empty to poll for new blocks"),
+		relay:       flags.String("relay", "", "private relay to send txs to (eth_sendPrivateTransaction), empty to broadcast publicly"),
+		relayBlocks: flags.Uint64("relay-blocks", 5, "number of blocks the relay has to include a tx before it is broadcasted publicly"),
+		signer:      flags.String("signer", "", "remote signer (http url or unix socket path) signing with account_signTransaction, empty to use the keystore"),
+		signerAddr:  flags.String("signer-address", "", "comma separated pricing operator addresses managed by the remote signer, the primary first"),
+		keystores:   flags.String("keystore", KEYSTORE_FILE, "comma separated keystores of the pricing operators, the primary first, unlocked with the same passphrase"),
+		policy: flags.String(
+			"operator-policy", dgxpricing.OPERATOR_POLICY_NOT_STUCK,
+			"how the pricing operator of each feed is chosen among the ones which are not stuck: not-stuck (the primary first), round-robin or balance",
+		),
+		passphrase: flags.String(
+			"passphrase", "file:"+PASSPHRASE_FILE,
+			"keystore passphrase source: file:<path>, env:<name>, fd:<number>, docker:<name>, tty or encrypted:<path>",
+		),
+		masterKey: flags.String("master-key", "", "source of the master key of an encrypted passphrase, in the format of -passphrase"),
+		webhook:   flags.String("alert-webhook", "", "webhook alerts are posted to as {\"text\": ...} besides the log, ie a slack incoming webhook"),
+		history:   flags.String("history", HISTORY_FILE, "database keeping fetched feeds, decisions, sent txs and receipts, empty to disable it"),
+		budget: budgetFlags{
+			maxGasPrice:     flags.Float64("max-gas-price", 60, "max gas price of a tx in gwei, 0 for no max"),
+			maxReplacements: flags.Int("max-replacements", dgxpricing.NO_STEP, "max number of replacements of a tx, -1 for no max"),
+			daily:           flags.Float64("daily-budget", 1, "max ETH spent by the pricing operators in 24 hours, 0 for no budget"),
+			emergencyBlocks: flags.Uint64(
+				"emergency-blocks", 0,
+				"lift the budget when the on-chain feed expires (maxBlockDrift) in this number of blocks or less, 0 to never lift it",
+			),
+			emergencyGasPrice: flags.Float64("emergency-max-gas-price", 200, "max gas price in gwei when the budget is lifted, 0 for no max"),
+		},
+		retry: retryFlags{
+			attempts: flags.Int("retries", dgxpricing.NO_RETRY, "number of tries to feed the price, each with a fresh feed, before giving up"),
+			delay:    flags.Duration("retry-delay", dgxpricing.RETRY_INITIAL_DELAY, "wait after the first failed try, doubled after every try"),
+			maxDelay: flags.Duration("retry-max-delay", dgxpricing.RETRY_MAX_DELAY, "max wait between tries"),
+			jitter:   flags.Float64("retry-jitter", dgxpricing.RETRY_JITTER, "fraction of the wait between tries which is randomized"),
+		},
+		log: logFlags{
+			level:      flags.String("log-level", "info", "lowest level logged: debug, info, warn or error"),
+			format:     flags.String("log-format", logging.FORMAT_LOGFMT, "log line format: logfmt or json"),
+			file:       flags.String("log-file", LOG_FILE, "file the log is written to besides the console, empty to only log to the console"),
+			maxSize:    flags.Int("log-max-size", 100, "size in megabytes the log file is rotated at"),
+			maxAge:     flags.Int("log-max-age", 0, "days rotated log files are kept, 0 to keep them all"),
+			maxBackups: flags.Int("log-max-backups", 0, "number of rotated log files kept, 0 to keep them all"),
+			rotate:     flags.String("log-rotate", "@daily", "cron spec the log file is rotated at besides its size, empty to rotate by size only"),
+		},
+		trace: traceFlags{
+			endpoint: flags.String("trace", "", "OTLP/HTTP collector the spans of feeding cycles are exported to (ie http://localhost:4318), stdout to print them as json lines, empty to disable tracing"),
+			service:  flags.String("trace-service", TRACE_SERVICE, "service name of the exported spans"),
+		},
+		trim: flags.String("passphrase-trim", secret.TRIM_SPACE, "whitespace removed from the passphrase: none, newline (one trailing new line) or space (all surrounding)"),
+	}
+}
+
+// newFeeder builds the feeder of a command, prices is where it fetches
+// feeds, noFeeds for commands which never fetch one
+func newFeeder(reserve *rsblockchain.DGXReserve, common commonFlags, prices dgxpricing.PriceCorpus, shadow bool) *dgxpricing.PriceFeeder {
+	clk := clock.NewRealClock()
+	feeder := dgxpricing.NewPriceFeeder(
+		runner.NewTickerRunner(FEED_INTERVAL, clk),
+		reserve,
+		prices,
+		newJournal(),
+		*common.stuckPolicy,
+		clk,
+	)
+	budget := newGasBudget(common.budget, clk)
+	if store := newHistory(common); store != nil {
+		feeder.SetHistory(store)
+		txs, err := store.Txs(history.Filter{Since: clk.Now().Add(-dgxpricing.BUDGET_WINDOW)})
+		if err != nil {
+			logger.Warnf("Reading the txs of the last 24 hours failed, the daily budget starts from 0: %s", err)
+		}
+		budget.Seed(txs)
+	}
+	feeder.SetLogger(logger)
+	feeder.SetTracer(newTracer(common.trace))
+	feeder.SetGasBudget(budget)
+	if *common.retry.attempts < 1 {
+		log.Fatalf("invalid -retries %d, expected at least 1", *common.retry.attempts)
+	}
+	feeder.SetRetryPolicy(dgxpricing.RetryPolicy{
+		Attempts:     *common.retry.attempts,
+		InitialDelay: *common.retry.delay,
+		MaxDelay:     *common.retry.maxDelay,
+		Jitter:       *common.retry.jitter,
+	})
+	feeder.SetAlerter(alert.NewAlerter(*common.webhook))
+	feeder.SetHeadSource(reserve.HeadWatcher(*common.wsEndpoint))
+	feeder.EnableOperatorSelection(reserve, *common.policy)
+	if *common.relay != "" {
+		relay, err := broadcast.NewRelay(*common.relay)
+		if err != nil {
+			log.Fatalf("invalid relay %s: %s", *common.relay, err)
+		}
+		reserve.EnablePrivateRelay(relay, *common.relayBlocks)
+	}
+	if shadow {
+		reserve.EnableShadowMode()
+		feeder.EnableShadowMode()
+	}
+	return feeder
+}
+
+// checkOperator exits if the pricing operator is not an operator of
+// the reserve, except in shadow mode where feeds are only simulated
+func checkOperator(reserve *rsblockchain.DGXReserve, common commonFlags, shadow bool) *dgxpricing.OperatorGuard {
+	guard := dgxpricing.NewOperatorGuard(reserve, alert.NewAlerter(*common.webhook), clock.NewRealClock())
+	guard.SetLogger(logger)
+	if err := guard.Check(); err != nil && !shadow {
+		log.Fatalf("%s", err)
+	}
+	return guard
+}
+
+func shadowFlag(flags *flag.FlagSet) *bool {
+	return flags.Bool("shadow", false, "fetch, validate, sign and simulate txs without broadcasting them")
+}
+
+func txReport(tx *types.Transaction, status string, err error) report {
+	r := report{}
+	if tx != nil {
+		r = append(r,
+			field{"tx", tx.Hash().Hex()},
+			field{"nonce", tx.Nonce()},
+			field{"gas_price", tx.GasPrice().String()},
+		)
+	}
+	return append(r, field{"status", status}, field{"error", errString(err)})
+}
+
+func hexAddresses(addrs []ethereum.Address) []string {
+	result := []string{}
+	for _, addr := range addrs {
+		result = append(result, addr.Hex())
+	}
+	return result
+}
+
+// operatorsReport tells if each pricing operator is in operators
+func operatorsReport(pricing []ethereum.Address, operators []ethereum.Address) report {
+	r := report{}
+	for _, addr := range pricing {
+		authorized := "authorised"
+		if !dgxpricing.IsOperator(operators, addr) {
+			authorized = "not an operator"
+		}
+		r = append(r, field{addr.Hex(), authorized})
+	}
+	return r
+}
+
+func run(args []string) {
+	flags, common := newFlagSet("run")
+	apiAddr := flags.String("api-addr", API_ADDR, "listen address of the admin api, empty to disable it")
+	apiToken := flags.String("api-token", "", "source of the bearer token of the mutating admin routes (/cancel, /feed) in the format of -passphrase, empty to disable them")
+	shadow := shadowFlag(flags)
+	leaseSpec := flags.String(
+		"lease", "",
+		"lease instances compete for so only one feeds: file:<path> on shared storage, redis://<host:port>/<key> or chain (nonce activity of the pricing operators), empty to always feed",
+	)
+	leaseTTL := flags.Duration("lease-ttl", LEASE_TTL, "how long the lease lasts without renewal, a standby takes over at most 4/3 of it after the leader stops; with -lease chain it must be longer than the feed interval")
+	haID := flags.String("ha-id", defaultHAID(), "name of this instance in the lease")
+	reportDir := flags.String("report-dir", REPORT_DIR, "directory the daily report of the previous day is written to every day, empty to disable it; needs -history")
+	reportWebhook := flags.String("report-webhook", "", "webhook the summary of the daily report is posted to as {\"text\": ...}, empty to only log it")
+	tickPolicy := flags.String(
+		"tick-policy", dgxpricing.TICK_POLICY_SKIP,
+		"what to do with a tick arriving while a feed tx is not final: skip, supersede (replace the pending tx with a newer feed at the same nonce) or queue (feed again once it is final)",
+	)
+	flags.Parse(args)
+
+	configLog(os.Stdout, common.log)
+
+	reserve := newReserve(common)
+	guard := checkOperator(reserve, common, *shadow)
+	guard.Start(dgxpricing.OPERATOR_CHECK_INTERVAL)
+	feeder := newFeeder(reserve, common, newFeedCorpus(*common.digixSigner), *shadow)
+	feeder.SetOperatorGuard(guard)
+	switch *tickPolicy {
+	case dgxpricing.TICK_POLICY_SKIP, dgxpricing.TICK_POLICY_SUPERSEDE, dgxpricing.TICK_POLICY_QUEUE:
+		feeder.SetTickPolicy(*tickPolicy)
+	default:
+		log.Fatalf("invalid -tick-policy %s, expected skip, supersede or queue", *tickPolicy)
+	}
+	if *leaseSpec != "" {
+		feeder.SetLeadership(newElector(reserve, *leaseSpec, *leaseTTL, *haID))
+	}
+	if store := newHistory(common); store != nil && *reportDir != "" {
+		scheduleDailyReport(store, *reportDir, reserve, alert.NewAlerter(*reportWebhook))
+	}
+	if *apiAddr != "" {
+		var reader api.HistoryReader
+		if store := newHistory(common); store != nil {
+			reader = store
+		}
+		var token []byte
+		if *apiToken != "" {
+			var err error
+			if token, err = readSecret("Admin api token", *apiToken, *common.masterKey, *common.trim); err != nil {
+				log.Fatalf("%s", err)
+			}
+			if len(token) == 0 {
+				log.Fatalf("-api-token is empty")
+			}
+		}
+		server := api.NewServer(feeder, reserve, reader, digixSigner(*common.digixSigner), *apiAddr, token)
+		go func() {
+			if err := server.Run(); err != nil {
+				logger.Errorf("admin api stopped: %s", err)
+			}
+		}()
+	}
+	feeder.Run()
+}
+
+func feedOnce(args []string) {
+	flags, common := newFlagSet("feed-once")
+	shadow := shadowFlag(flags)
+	flags.Parse(args)
+
+	configLog(os.Stderr, common.log)
+
+	reserve := newReserve(common)
+	guard := checkOperator(reserve, common, *shadow)
+	feeder := newFeeder(reserve, common, newFeedCorpus(*common.digixSigner), *shadow)
+	feeder.SetOperatorGuard(guard)
+	tx, status, err := feeder.FeedOnce()
+	flushTraces()
+	printReport(*common.asJSON, txReport(tx, status, err))
+	if err != nil || (status != "mined" && status != "shadow") {
+		os.Exit(1)
+	}
+}
+
+func submit(args []string) {
+	flags, common := newFlagSet("submit")
+	file := flags.String("file", "-", "file containing the signed feed, - for stdin")
+	flags.Parse(args)
+
+	configLog(os.Stderr, common.log)
+
+	corpus, err := feed.NewManualCorpusFromFile(*file, digixSigner(*common.digixSigner))
+	if err != nil {
+		log.Fatalf("Reading manual feed failed: %s", err)
+	}
+	reserve := newReserve(common)
+	guard := checkOperator(reserve, common, false)
+	feeder := newFeeder(reserve, common, corpus, false)
+	feeder.SetOperatorGuard(guard)
+	tx, status, err := feeder.FeedManually(corpus)
+	flushTraces()
+	printReport(*common.asJSON, txReport(tx, status, err))
+	if err != nil || status != "mined" {
+		os.Exit(1)
+	}
+}
+
+func fetch(args []string) {
+	flags, common := newFlagSet("fetch")
+	flags.Parse(args)
+
+	configLog(os.Stderr, common.log)
+
+	corpus := newFeedCorpus(*common.digixSigner)
+	price, err := corpus.GetFeedFromEndpoint()
+	if err != nil {
+		log.Fatalf("Fetching feed failed: %s", err)
+	}
+	verifyErr := corpus.Verify(price)
+	recovered, _ := price.RecoverSigner()
+	printReport(*common.asJSON, report{
+		field{"endpoint", corpus.Endpoint()},
+		field{"block_number", price.Block},
+		field{"nonce", price.Nonce},
+		field{"ask_for_1000", price.Ask},
+		field{"bid_for_1000", price.Bid},
+		field{"hash", price.Hash.Hex()},
+		field{"signer", price.Signer.Hex()},
+		field{"recovered_signer", recovered.Hex()},
+		field{"v", price.V},
+		field{"r", hexutil.Encode(price.R[:])},
+		field{"s", hexutil.Encode(price.S[:])},
+		field{"valid", verifyErr == nil},
+		field{"error", errString(verifyErr)},
+	})
+	if verifyErr != nil {
+		os.Exit(1)
+	}
+}
+
+func onchain(args []string) {
+	flags, common := newFlagSet("onchain")
+	block := flags.Uint64("block", 0, "block to read the reserve at, 0 for the latest")
+	flags.Parse(args)
+
+	configLog(os.Stderr, common.log)
+
+	reserve := newReserve(common)
+	onchainFeed, err := reserve.GetPriceFeed(*block)
+	if err != nil {
+		log.Fatalf("getPriceFeed failed: %s", err)
+	}
+	priceFeed, err := reserve.PriceFeed(*block)
+	if err != nil {
+		log.Fatalf("priceFeed failed: %s", err)
+	}
+	drift, err := reserve.MaxBlockDrift(*block)
+	if err != nil {
+		log.Fatalf("maxBlockDrift failed: %s", err)
+	}
+	tradeEnabled, err := reserve.TradeEnabled(*block)
+	if err != nil {
+		log.Fatalf("tradeEnabled failed: %s", err)
+	}
+	operators, err := reserve.GetOperators(*block)
+	if err != nil {
+		log.Fatalf("getOperators failed: %s", err)
+	}
+	alerters, err := reserve.GetAlerters(*block)
+	if err != nil {
+		log.Fatalf("getAlerters failed: %s", err)
+	}
+	printReport(*common.asJSON, report{
+		field{"reserve", reserve.GetAddresses()["dgx_reserve"].Hex()},
+		field{"pricing_operators", operatorsReport(reserve.PricingAddresses(), operators)},
+		field{"get_price_feed", report{
+			field{"feed_block", onchainFeed.FeedBlock},
+			field{"nonce", onchainFeed.Nonce},
+			field{"ask_for_1000", onchainFeed.Ask1KDigix},
+			field{"bid_for_1000", onchainFeed.Bid1KDigix},
+		}},
+		field{"price_feed", priceFeed},
+		field{"max_block_drift", drift},
+		field{"trade_enabled", tradeEnabled},
+		field{"operators", hexAddresses(operators)},
+		field{"alerters", hexAddresses(alerters)},
+	})
+}
+
+func inspect(args []string) {
+	flags, common := newFlagSet("inspect")
+	block := flags.Uint64("block", 0, "block to read the reserve at, 0 for the latest")
+	flags.Parse(args)
+
+	configLog(os.Stderr, common.log)
+
+	reserve := newReserve(common)
+	snapshot, err := reserve.Snapshot(*block)
+	if err != nil {
+		log.Fatalf("Reading the reserve failed: %s", err)
+	}
+	printReport(*common.asJSON, report{
+		field{"block", snapshot.Block},
+		field{"reserve", snapshot.Reserve.Hex()},
+		field{"admin", snapshot.Admin.Hex()},
+		field{"pending_admin", snapshot.PendingAdmin.Hex()},
+		field{"operators", hexAddresses(snapshot.Operators)},
+		field{"alerters", hexAddresses(snapshot.Alerters)},
+		field{"pricing_operators", operatorsReport(reserve.PricingAddresses(), snapshot.Operators)},
+		field{"contracts", report{
+			field{"digix", snapshot.Digix.Hex()},
+			field{"kyber_network", snapshot.KyberNetwork.Hex()},
+			field{"maker_dao", snapshot.MakerDaoContract.Hex()},
+			field{"conversion_rates", snapshot.ConversionRatesContract.Hex()},
+			field{"sanity_rates", snapshot.SanityRatesContract.Hex()},
+		}},
+		field{"buy_transfer_fee", snapshot.BuyTransferFee},
+		field{"sell_transfer_fee", snapshot.SellTransferFee},
+		field{"trade_enabled", snapshot.TradeEnabled},
+		field{"max_block_drift", snapshot.MaxBlockDrift},
+		field{"price_feed", snapshot.PriceFeed},
+		field{"get_price_feed", report{
+			field{"feed_block", snapshot.Feed.FeedBlock},
+			field{"nonce", snapshot.Feed.Nonce},
+			field{"ask_for_1000", snapshot.Feed.Ask1KDigix},
+			field{"bid_for_1000", snapshot.Feed.Bid1KDigix},
+		}},
+		field{"balances", report{
+			field{"eth", snapshot.ETHBalance},
+			field{"dgx", snapshot.DGXBalance},
+		}},
+	})
+}
+
+func monitorTx(args []string) {
+	flags, common := newFlagSet("tx")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: tx [flags] <hash>")
+	}
+
+	configLog(os.Stderr, common.log)
+
+	reserve := newReserve(common)
+	tx, err := reserve.GetTransaction(ethereum.HexToHash(flags.Arg(0)))
+	if err != nil {
+		log.Fatalf("Getting tx failed: %s", err)
+	}
+	// only txs of the pricing operators are replaced, nonces are checked
+	// against the one which sent the tx
+	from, err := reserve.OperatorOf(tx)
+	if err != nil {
+		log.Fatalf("Not monitoring tx %s: %s", tx.Hash().Hex(), err)
+	}
+	if err := reserve.UseOperator(from); err != nil {
+		log.Fatalf("%s", err)
+	}
+	final, status, err := newFeeder(reserve, common, noFeeds{}, false).MonitorAndRetry(tx)
+	printReport(*common.asJSON, txReport(final, status, err))
+}
+
+func cancel(args []string) {
+	flags, common := newFlagSet("cancel")
+	nonce := flags.Int64("nonce", -1, "nonce to cancel, default to the lowest journaled pending nonce")
+	operator := flags.String("operator", "", "pricing operator whose nonce is cancelled, default to the primary")
+	flags.Parse(args)
+
+	configLog(os.Stderr, common.log)
+
+	var txNonce *big.Int
+	if *nonce >= 0 {
+		txNonce = big.NewInt(*nonce)
+	}
+	reserve := newReserve(common)
+	if *operator != "" {
+		if err := reserve.UseOperator(ethereum.HexToAddress(*operator)); err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+	feeder := newFeeder(reserve, common, noFeeds{}, false)
+	tx, err := feeder.Cancel(txNonce)
+	if err != nil {
+		log.Fatalf("Cancelling failed: %s", err)
+	}
+	final, status, err := feeder.MonitorAndRetry(tx)
+	printReport(*common.asJSON, txReport(final, status, err))
+}
+
+func encryptSecret(args []string) {
+	flags, common := newFlagSet("encrypt-secret")
+	out := flags.String("out", "", "file to write the encrypted secret to")
+	flags.Parse(args)
+	if *out == "" || *common.masterKey == "" {
+		log.Fatalf("Usage: encrypt-secret -passphrase <source> -master-key <source> -out <file>")
+	}
+
+	configLog(os.Stderr, common.log)
+
+	passphrase, err := readSecret("Passphrase", *common.passphrase, "", *common.trim)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	defer secret.Zero(passphrase)
+	masterKey, err := readSecret("Master key", *common.masterKey, "", *common.trim)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	defer secret.Zero(masterKey)
+	data, err := secret.Encrypt(passphrase, masterKey)
+	if err != nil {
+		log.Fatalf("Encrypting failed: %s", err)
+	}
+	if err := ioutil.WriteFile(*out, data, 0600); err != nil {
+		log.Fatalf("Writing %s failed: %s", *out, err)
+	}
+	printReport(*common.asJSON, report{field{"file", *out}})
+}
+
+func operators(args []string) {
+	flags, common := newFlagSet("operators")
+	flags.Parse(args)
+
+	configLog(os.Stderr, common.log)
+
+	reserve := newReserve(common)
+	feeder := newFeeder(reserve, common, noFeeds{}, false)
+	printReport(*common.asJSON, operatorStatusesReport(feeder.OperatorStatuses()))
+}
+
+func operatorStatusesReport(statuses []dgxpricing.OperatorStatus) report {
+	r := report{}
+	for _, status := range statuses {
+		r = append(r, field{status.Address.Hex(), report{
+			field{"primary", status.Primary},
+			field{"mined_nonce", status.MinedNonce},
+			field{"pending_nonce", status.PendingNonce},
+			field{"balance", status.Balance.String()},
+			field{"stuck", status.Stuck},
+			field{"error", status.Error},
+		}})
+	}
+	return r
+}
+
+// feedStatusReport reports how old the feed stored in the reserve is and
+// when it expires, expired is true once trades stopped
+func feedStatusReport(reserve *rsblockchain.DGXReserve) (r report, expired bool) {
+	latest, err := reserve.LatestBlock()
+	if err != nil {
+		return report{field{"error", err.Error()}}, false
+	}
+	onchainFeed, err := reserve.GetPriceFeed(latest)
+	if err != nil {
+		return report{field{"error", err.Error()}}, false
+	}
+	drift, err := reserve.MaxBlockDrift(latest)
+	if err != nil {
+		return report{field{"error", err.Error()}}, false
+	}
+	age := int64(latest) - onchainFeed.FeedBlock.Int64()
+	toExpiry := drift.Int64() - age
+	return report{
+		field{"latest_block", latest},
+		field{"feed_block", onchainFeed.FeedBlock},
+		field{"nonce", onchainFeed.Nonce},
+		field{"age_blocks", age},
+		field{"max_block_drift", drift},
+		field{"blocks_to_expiry", toExpiry},
+		field{"expired", toExpiry < 0},
+	}, toExpiry < 0
+}
+
+// pendingTxsReport lists the hashes of the journaled txs of every pricing
+// operator by nonce, for nonces which are not final yet
+func pendingTxsReport(reserve *rsblockchain.DGXReserve, txJournal *journal.FileJournal) report {
+	r := report{}
+	for _, addr := range reserve.PricingAddresses() {
+		nonces := report{}
+		for _, n := range txJournal.PendingNonces(addr) {
+			hashes := []string{}
+			for _, tx := range txJournal.TxsAtNonce(addr, n) {
+				hashes = append(hashes, tx.Hash().Hex())
+			}
+			nonces = append(nonces, field{fmt.Sprintf("%d", n), hashes})
+		}
+		r = append(r, field{addr.Hex(), nonces})
+	}
+	return r
+}
+
+func endpointsReport(health []rsblockchain.EndpointHealth) report {
+	r := report{}
+	for _, h := range health {
+		r = append(r, field{h.URL, report{
+			field{"healthy", h.Healthy},
+			field{"latency_ms", h.LatencyMs},
+			field{"head", h.Head},
+			field{"head_lag", h.HeadLag},
+			field{"consecutive_errors", h.ConsecutiveErrors},
+			field{"last_error", h.LastError},
+		}})
+	}
+	return r
+}
+
+func showStatus(args []string) {
+	flags, common := newFlagSet("status")
+	flags.Parse(args)
+
+	configLog(os.Stderr, common.log)
+
+	reserve := newReserve(common)
+	feeder := newFeeder(reserve, common, noFeeds{}, false)
+	onchainFeed, expired := feedStatusReport(reserve)
+	printReport(*common.asJSON, report{
+		field{"onchain_feed", onchainFeed},
+		field{"pending_txs", pendingTxsReport(reserve, newJournal())},
+		field{"operators", operatorStatusesReport(feeder.OperatorStatuses())},
+		field{"endpoints", endpointsReport(reserve.EndpointsHealth())},
+	})
+	if expired {
+		os.Exit(1)
+	}
+}
+
+func showHistory(args []string) {
+	flags, common := newFlagSet("history")
+	txs := flags.Bool("txs", false, "list sent txs instead of fetched feeds")
+	since := flags.String("since", "", "only list those from this time on, RFC3339 or YYYY-MM-DD")
+	until := flags.String("until", "", "only list those before this time, RFC3339 or YYYY-MM-DD")
+	nonce := flags.String("nonce", "", "only list feeds with this Digix nonce, or txs with this nonce with -txs")
+	outcome := flags.String("outcome", "", "only list feeds with this decision (submitted, skipped, rejected), or txs with this status with -txs (pending, mined, failed, lost, replaced, shadow)")
+	limit := flags.String("limit", "", "only list the latest ones")
+	flags.Parse(args)
+
+	configLog(os.Stderr, common.log)
+
+	store := newHistory(common)
+	if store == nil {
+		log.Fatalf("-history is not set")
+	}
+	filter, err := history.ParseFilter(*since, *until, *nonce, *outcome, *limit)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	r := report{}
+	if *txs {
+		list, err := store.Txs(filter)
+		if err != nil {
+			log.Fatalf("Reading history failed: %s", err)
+		}
+		for _, tx := range list {
+			r = append(r, field{tx.Hash.Hex(), report{
+				field{"time", tx.Time.Format(time.RFC3339)},
+				field{"kind", tx.Kind},
+				field{"from", tx.From.Hex()},
+				field{"nonce", tx.Nonce},
+				field{"gas_price", tx.GasPrice.String()},
+				field{"status", tx.Status},
+				field{"block", tx.Block},
+				field{"gas_used", tx.GasUsed},
+			}})
+		}
+	} else {
+		list, err := store.Feeds(filter)
+		if err != nil {
+			log.Fatalf("Reading history failed: %s", err)
+		}
+		for _, f := range list {
+			tx := ""
+			if f.Tx != nil {
+				tx = f.Tx.Hex()
+			}
+			fr := report{
+				field{"time", f.Time.Format(time.RFC3339)},
+				field{"block_number", f.BlockNumber},
+				field{"nonce", f.Nonce},
+				field{"ask_for_1000", f.Ask},
+				field{"bid_for_1000", f.Bid},
+				field{"verified", f.Verified},
+				field{"decision", f.Decision},
+				field{"reason", f.Reason},
+				field{"tx", tx},
+			}
+			// the raw feed is too long to be read in a terminal
+			if *common.asJSON {
+				fr = append(fr, field{"raw", f.Raw})
+			}
+			r = append(r, field{fmt.Sprintf("#%d", f.ID), fr})
+		}
+	}
+	printReport(*common.asJSON, r)
+}
+
+func export(args []string) {
+	flags, common := newFlagSet("export")
+	what := flags.String("what", "report", "what to export: feeds, txs or report (one line per day)")
+	format := flags.String("format", "csv", "csv or json")
+	since := flags.String("since", "", "export from this time on, RFC3339 or YYYY-MM-DD")
+	until := flags.String("until", "", "export until this time excluded, RFC3339 or YYYY-MM-DD")
+	out := flags.String("out", "-", "file to write to, - for stdout")
+	flags.Parse(args)
+	if *format != "csv" && *format != "json" {
+		log.Fatalf("invalid format %s, expected csv or json", *format)
+	}
+
+	configLog(os.Stderr, common.log)
+
+	store := newHistory(common)
+	if store == nil {
+		log.Fatalf("-history is not set")
+	}
+	filter, err := history.ParseFilter(*since, *until, "", "", "")
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	var data interface{}
+	var writeCSV func(w io.Writer) error
+	switch *what {
+	case "feeds":
+		feeds, err := store.Feeds(filter)
+		if err != nil {
+			log.Fatalf("Reading history failed: %s", err)
+		}
+		data = feeds
+		writeCSV = func(w io.Writer) error { return history.WriteFeedsCSV(w, feeds) }
+	case "txs":
+		txs, err := store.Txs(filter)
+		if err != nil {
+			log.Fatalf("Reading history failed: %s", err)
+		}
+		data = txs
+		writeCSV = func(w io.Writer) error { return history.WriteTxsCSV(w, txs) }
+	case "report":
+		bc := newBaseBlockchain()
+		waitForEthRate(bc)
+		days, err := history.Report(store, filter.Since, filter.Until, bc)
+		if err != nil {
+			log.Fatalf("Reading history failed: %s", err)
+		}
+		data = days
+		writeCSV = func(w io.Writer) error { return history.WriteReportCSV(w, days) }
+	default:
+		log.Fatalf("invalid -what %s, expected feeds, txs or report", *what)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(data)
+	} else {
+		err = writeCSV(w)
+	}
+	if err != nil {
+		log.Fatalf("Exporting failed: %s", err)
+	}
+}
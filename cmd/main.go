@@ -1,75 +1,52 @@
 package main
 
 import (
-	"io"
-	"io/ioutil"
-	"log"
+	"fmt"
 	"os"
-	"strings"
-	"time"
-
-	"github.com/KyberNetwork/dgx-price-feeder"
-	rsblockchain "github.com/KyberNetwork/dgx-price-feeder/blockchain"
-	"github.com/KyberNetwork/dgx-price-feeder/feed"
-	"github.com/KyberNetwork/dgx-price-feeder/runner"
-	"github.com/KyberNetwork/reserve-data/common/blockchain"
-	ethereum "github.com/ethereum/go-ethereum/common"
-	"github.com/robfig/cron"
-	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 )
 
-//set config log
-func configLog() {
-	logger := &lumberjack.Logger{
-		Filename: "/go/src/github.com/KyberNetwork/dgx-price-feeder/log/log.log",
-		// MaxSize:  1, // megabytes
-		MaxBackups: 0,
-		MaxAge:     0, //days
-		// Compress:   true, // disabled by default
-	}
+type command struct {
+	name  string
+	usage string
+	run   func(args []string)
+}
 
-	mw := io.MultiWriter(os.Stdout, logger)
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
-	log.SetOutput(mw)
+var commands = []command{
+	{"run", "feed the price periodically (default)", run},
+	{"feed-once", "fetch, validate, submit and monitor one price feed, then exit", feedOnce},
+	{"submit", "submit a signed feed from a file or stdin, ie one Digix sent out of band", submit},
+	{"fetch", "print the current Digix feed and its verification result without sending it", fetch},
+	{"onchain", "print the feed and pricing settings stored in the reserve", onchain},
+	{"inspect", "print a snapshot of the reserve's configuration and balances, optionally at -block", inspect},
+	{"tx", "monitor an existing tx: tx <hash>", monitorTx},
+	{"cancel", "replace a pending tx of the pricing operator with a 0 ETH self transfer", cancel},
+	{"history", "list fetched feeds and the decisions made, or sent txs with -txs, filtered by time, nonce and outcome", showHistory},
+	{"export", "export feeds, txs or a daily report of feeding activity and cost as csv or json", export},
+	{"status", "print the age of the on-chain feed, journaled pending txs and the health of operators and endpoints, exits 1 once the feed expired", showStatus},
+	{"operators", "print nonces, balance and stuck state of every pricing operator", operators},
+	{"encrypt-secret", "encrypt the passphrase with a master key for -passphrase encrypted:<file>", encryptSecret},
+}
 
-	c := cron.New()
-	c.AddFunc("@daily", func() { logger.Rotate() })
-	c.Start()
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Commands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for flags of a command.\n", os.Args[0])
 }
 
 func main() {
-
-	configLog()
-
-	endpoints := []string{
-		"https://semi-node.kyber.network",
-		"https://mainnet.infura.io",
-		"https://api.mycryptoapi.com/eth",
-		"https://api.myetherapi.com/eth",
-		"https://mew.giveth.io/",
-	}
-	chainType := "byzantium"
-	operators := map[string]*blockchain.Operator{}
-	bc, err := blockchain.NewMinimalBaseBlockchain(
-		endpoints, operators, chainType,
-	)
-	if err != nil {
-		panic(err)
+	if len(os.Args) < 2 || len(os.Args[1]) > 0 && os.Args[1][0] == '-' {
+		run(os.Args[1:])
+		return
 	}
-	passphrase, err := ioutil.ReadFile(
-		"/go/src/github.com/KyberNetwork/dgx-price-feeder/cmd/passphrase",
-	)
-	if err != nil {
-		panic(err)
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			c.run(os.Args[2:])
+			return
+		}
 	}
-	reserve := rsblockchain.NewDGXReserve(
-		bc,
-		ethereum.HexToAddress("0xce076f8ab3f5af34ecf70b99995b11039190edc1"),
-		"/go/src/github.com/KyberNetwork/dgx-price-feeder/cmd/keystore",
-		strings.TrimSpace(string(passphrase)),
-	)
-	feedCorpus := feed.NewFeedCorpus()
-	runner := runner.NewTickerRunner(30 * time.Minute)
-	feeder := dgxpricing.NewPriceFeeder(runner, reserve, feedCorpus)
-	feeder.Run()
+	usage()
+	os.Exit(2)
 }
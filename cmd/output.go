@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+type field struct {
+	Name  string
+	Value interface{}
+}
+
+// report is an ordered list of fields which is printed either as
+// aligned "name: value" lines or as a json object
+type report []field
+
+func (self report) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, f := range self {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (self report) print(indent string, w *tabwriter.Writer) {
+	for _, f := range self {
+		switch v := f.Value.(type) {
+		case report:
+			fmt.Fprintf(w, "%s%s:\t\n", indent, f.Name)
+			v.print(indent+"  ", w)
+		default:
+			fmt.Fprintf(w, "%s%s:\t%v\n", indent, f.Name, v)
+		}
+	}
+}
+
+func printReport(asJSON bool, r report) {
+	if asJSON {
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	r.print("", w)
+	w.Flush()
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder"
+	"github.com/KyberNetwork/dgx-price-feeder/alert"
+	rsblockchain "github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/feed"
+	"github.com/KyberNetwork/dgx-price-feeder/history"
+	"github.com/KyberNetwork/dgx-price-feeder/journal"
+	"github.com/KyberNetwork/dgx-price-feeder/lease"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/KyberNetwork/dgx-price-feeder/secret"
+	"github.com/KyberNetwork/dgx-price-feeder/tracing"
+	"github.com/KyberNetwork/reserve-data/common/blockchain"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/robfig/cron"
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	// REMOTE_SIGNER_TIMEOUT leaves time for signing requests to be approved
+	REMOTE_SIGNER_TIMEOUT time.Duration = 30 * time.Second
+
+	// LEASE_TTL is the default lease duration of the file and redis
+	// backends
+	LEASE_TTL time.Duration = 2 * time.Minute
+
+	KEYSTORE_FILE   string = "/go/src/github.com/KyberNetwork/dgx-price-feeder/cmd/keystore"
+	PASSPHRASE_FILE string = "/go/src/github.com/KyberNetwork/dgx-price-feeder/cmd/passphrase"
+	HISTORY_FILE    string = "/go/src/github.com/KyberNetwork/dgx-price-feeder/data/history.db"
+	LOG_FILE        string = "/go/src/github.com/KyberNetwork/dgx-price-feeder/log/log.log"
+	REPORT_DIR      string = "/go/src/github.com/KyberNetwork/dgx-price-feeder/data/reports"
+
+	// API_ADDR only accepts local connections, the admin api has to be
+	// exposed explicitly
+	API_ADDR string = "127.0.0.1:7000"
+
+	TRACE_SERVICE string = "dgx-price-feeder"
+	// TRACE_INTERVAL is how often ended spans are exported
+	TRACE_INTERVAL time.Duration = 5 * time.Second
+)
+
+// logger is the structured logger set up by configLog
+var logger *logging.Logger
+
+// configLog makes the structured logger write to console and to the log
+// file, the standard logger is routed to it at info level
+func configLog(console io.Writer, flags logFlags) {
+	level, err := logging.ParseLevel(*flags.level)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	if *flags.format != logging.FORMAT_JSON && *flags.format != logging.FORMAT_LOGFMT {
+		log.Fatalf("unsupported -log-format %s, expected json or logfmt", *flags.format)
+	}
+	out := console
+	if *flags.file != "" {
+		file := &lumberjack.Logger{
+			Filename:   *flags.file,
+			MaxSize:    *flags.maxSize, // megabytes
+			MaxAge:     *flags.maxAge,  // days
+			MaxBackups: *flags.maxBackups,
+		}
+		out = io.MultiWriter(console, file)
+		if *flags.rotate != "" {
+			c := cron.New()
+			if err := c.AddFunc(*flags.rotate, func() { file.Rotate() }); err != nil {
+				log.Fatalf("invalid -log-rotate %s: %s", *flags.rotate, err)
+			}
+			c.Start()
+		}
+	}
+	logger = logging.New(out, level, *flags.format)
+	logging.SetStd(logger)
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(logging.INFO))
+}
+
+// tracer is the tracer set up by newTracer, nil when tracing is disabled
+var tracer *tracing.Tracer
+
+// newTracer returns a tracer exporting spans in the background to the
+// collector of -trace or to stdout, nil if -trace is empty
+func newTracer(flags traceFlags) *tracing.Tracer {
+	if tracer != nil || *flags.endpoint == "" {
+		return tracer
+	}
+	var exporter tracing.Exporter
+	if *flags.endpoint == "stdout" {
+		exporter = tracing.NewWriterExporter(os.Stdout)
+	} else {
+		otlp, err := tracing.NewOTLPExporter(*flags.endpoint)
+		if err != nil {
+			log.Fatalf("invalid -trace: %s", err)
+		}
+		exporter = otlp
+	}
+	tracer = tracing.NewTracer(*flags.service, exporter)
+	go tracer.Run(TRACE_INTERVAL)
+	return tracer
+}
+
+// flushTraces exports the spans which are not exported yet, commands
+// call it before exiting
+func flushTraces() {
+	if err := tracer.Flush(); err != nil {
+		logger.Warnf("Exporting spans failed: %s", err)
+	}
+}
+
+// newSigners returns a remote signer for every address of
+// -signer-address if -signer is set, a keystore signer for every file of
+// -keystore otherwise. The first one is the primary pricing operator.
+func newSigners(common commonFlags) []blockchain.Signer {
+	signers := []blockchain.Signer{}
+	if *common.signer != "" {
+		for _, addr := range strings.Split(*common.signerAddr, ",") {
+			if !ethereum.IsHexAddress(addr) {
+				log.Fatalf("-signer-address must be set to the pricing operator addresses with -signer")
+			}
+			signer, err := rsblockchain.NewRemoteSigner(*common.signer, ethereum.HexToAddress(addr), REMOTE_SIGNER_TIMEOUT)
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			signers = append(signers, signer)
+		}
+		return signers
+	}
+	passphrase, err := readSecret("Passphrase", *common.passphrase, *common.masterKey, *common.trim)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	defer secret.Zero(passphrase)
+	for _, keystore := range strings.Split(*common.keystores, ",") {
+		// the keystore takes a string, which can't be zeroed
+		signers = append(signers, blockchain.NewEthereumSigner(keystore, string(passphrase)))
+	}
+	return signers
+}
+
+// readSecret reads the secret described by spec, unlocked by the master
+// key described by masterKeySpec if it is encrypted
+func readSecret(name string, spec string, masterKeySpec string, trim string) ([]byte, error) {
+	var masterKey secret.Source
+	if masterKeySpec != "" {
+		source, err := secret.Parse("Master key", masterKeySpec, nil)
+		if err != nil {
+			return nil, err
+		}
+		masterKey = secret.Trimmed(source, trim)
+	}
+	source, err := secret.Parse(name, spec, masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return secret.ReadTrimmed(source, trim)
+}
+
+var endpoints = []string{
+	"https://semi-node.kyber.network",
+	"https://mainnet.infura.io",
+	"https://api.mycryptoapi.com/eth",
+	"https://api.myetherapi.com/eth",
+	"https://mew.giveth.io/",
+}
+
+func newBaseBlockchain() *blockchain.BaseBlockchain {
+	chainType := "byzantium"
+	operators := map[string]*blockchain.Operator{}
+	bc, err := blockchain.NewMinimalBaseBlockchain(
+		endpoints, operators, chainType,
+	)
+	if err != nil {
+		panic(err)
+	}
+	return bc
+}
+
+// waitForEthRate gives the ETH rate fetcher of bc time to get its first
+// rate, until then every rate is 0
+func waitForEthRate(bc *blockchain.BaseBlockchain) {
+	for i := 0; i < 20 && bc.GetEthRate(uint64(time.Now().UnixNano()/int64(time.Millisecond))) == 0; i++ {
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func newReserve(common commonFlags) *rsblockchain.DGXReserve {
+	bc := newBaseBlockchain()
+	pool, err := rsblockchain.NewClientPool(endpoints)
+	if err != nil {
+		panic(err)
+	}
+	pool.Start(rsblockchain.HEALTH_CHECK_INTERVAL)
+	reserve := rsblockchain.NewDGXReserve(
+		bc,
+		pool,
+		ethereum.HexToAddress("0xce076f8ab3f5af34ecf70b99995b11039190edc1"),
+		newSigners(common)...,
+	)
+	reserve.SetLogger(logger)
+	return reserve
+}
+
+func newJournal() *journal.FileJournal {
+	return journal.NewFileJournal(
+		"/go/src/github.com/KyberNetwork/dgx-price-feeder/data/txs.journal",
+		clock.NewRealClock(),
+	)
+}
+
+// scheduleDailyReport writes the report of the previous day to dir every
+// day after midnight UTC and sends its summary to notifier
+func scheduleDailyReport(store *history.Store, dir string, rate history.EthRate, notifier alert.Alerter) {
+	c := cron.NewWithLocation(time.UTC)
+	// a few minutes after midnight so txs of the day are final
+	c.AddFunc("0 5 0 * * *", func() {
+		report, path, err := history.WriteDailyReport(store, dir, rate, time.Now())
+		if err != nil {
+			notifier.Alert(alert.WARNING, fmt.Sprintf("Writing the daily report failed: %s", err))
+			return
+		}
+		logger.Infof("Daily report written to %s", path)
+		notifier.Alert(alert.INFO, report.Summary())
+	})
+	c.Start()
+}
+
+// toWei converts amount of unit, ie 1e9 for gwei, to wei. 0 means no
+// cap and returns nil.
+func toWei(amount float64, unit float64) *big.Int {
+	if amount <= 0 {
+		return nil
+	}
+	wei, _ := new(big.Float).Mul(big.NewFloat(amount), big.NewFloat(unit)).Int(nil)
+	return wei
+}
+
+func newGasBudget(flags budgetFlags, clk clock.Clock) *dgxpricing.GasBudget {
+	budget := dgxpricing.NewGasBudget(
+		toWei(*flags.maxGasPrice, 1e9),
+		*flags.maxReplacements,
+		toWei(*flags.daily, 1e18),
+		clk,
+	)
+	if *flags.emergencyBlocks > 0 {
+		budget.EnableEmergency(*flags.emergencyBlocks, toWei(*flags.emergencyGasPrice, 1e9))
+	}
+	return budget
+}
+
+// historyStore is the store of -history, shared by the whole process as
+// it keeps the database open
+var historyStore *history.Store
+
+// newHistory returns the history store of -history, nil if it is disabled
+func newHistory(common commonFlags) *history.Store {
+	if *common.history == "" {
+		return nil
+	}
+	if historyStore == nil {
+		historyStore = history.NewStore(*common.history, clock.NewRealClock())
+	}
+	return historyStore
+}
+
+// digixSigner returns the address feeds must be signed by, it fails the
+// command when it is not set
+func digixSigner(signer string) ethereum.Address {
+	if signer == "" {
+		log.Fatalf("the Digix signer is not set, set -digix-signer or DIGIX_SIGNER")
+	}
+	if !ethereum.IsHexAddress(signer) {
+		log.Fatalf("invalid digix signer address: %s", signer)
+	}
+	addr := ethereum.HexToAddress(signer)
+	if addr == (ethereum.Address{}) {
+		log.Fatalf("the Digix signer can't be the zero address")
+	}
+	return addr
+}
+
+func newFeedCorpus(signer string) *feed.FeedCorpus {
+	corpus := feed.NewFeedCorpus(feed.ENDPOINT, digixSigner(signer))
+	corpus.SetLogger(logger)
+	return corpus
+}
+
+// noFeeds is the price corpus of commands which never fetch a feed, they
+// run without the Digix signer
+type noFeeds struct{}
+
+func (self noFeeds) GetFeed(ctx context.Context) (*big.Int, *big.Int, *big.Int, *big.Int, uint8, [32]byte, [32]byte, error) {
+	return nil, nil, nil, nil, 0, [32]byte{}, [32]byte{}, errors.New("this command doesn't fetch feeds")
+}
+
+func defaultHAID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "feeder"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// newElector starts competing for the lease described by spec
+func newElector(reserve *rsblockchain.DGXReserve, spec string, ttl time.Duration, id string) *lease.Elector {
+	clk := clock.NewRealClock()
+	var backend lease.Backend
+	if spec == "chain" {
+		chain := lease.NewChainLease(reserve, clk)
+		chain.SetLogger(logger)
+		backend = chain
+	} else {
+		var err error
+		if backend, err = lease.Parse(spec, clk); err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+	elector := lease.NewElector(backend, id, ttl, clk)
+	elector.SetLogger(logger)
+	elector.Start()
+	return elector
+}
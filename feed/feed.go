@@ -1,15 +1,18 @@
 package feed
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"math/big"
 	"net/http"
 	// "strings"
-	"log"
+	"sync"
 	"time"
 
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/KyberNetwork/dgx-price-feeder/tracing"
 	ethereum "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 )
@@ -87,20 +90,86 @@ func (self *PriceFeed) Feed() (*Price, error) {
 }
 
 type FeedCorpus struct {
-	client *http.Client
+	client   *http.Client
+	endpoint string
+	// signer is the Digix address feeds must be signed by
+	signer ethereum.Address
+	// logger is the one of lines which are not about a feed cycle, lines
+	// about a cycle go to the logger its context carries and fetching is
+	// traced under the span it carries
+	mu     sync.Mutex
+	logger *logging.Logger
 }
 
-func (self *FeedCorpus) GetFeed() (blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte, err error) {
-	f, err := self.GetFeedFromEndpoint()
+func (self *FeedCorpus) GetFeed(ctx context.Context) (blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte, err error) {
+	_, f, err := self.GetRawFeed(ctx)
 	if err != nil {
 		return nil, nil, nil, nil, 0, [32]byte{}, [32]byte{}, err
 	}
 	return f.Block, f.Nonce, f.Ask, f.Bid, f.V, f.R, f.S, nil
 }
 
-func (self *FeedCorpus) GetFeedFromEndpoint() (*Price, error) {
-	result := PriceFeed{}
-	r, err := self.client.Get(ENDPOINT)
+// GetRawFeed fetches a feed and verifies it. It returns the feed as it
+// was received, the parsed feed unless it couldn't be parsed, and why
+// the feed is invalid.
+func (self *FeedCorpus) GetRawFeed(ctx context.Context) (raw []byte, price *Price, err error) {
+	span := tracing.FromContext(ctx).Start("feed.fetch", "endpoint", self.endpoint)
+	span.SetClient()
+	raw, err = self.fetch(ctx)
+	span.SetAttributes("bytes", len(raw))
+	span.SetError(err)
+	span.End()
+	if err != nil {
+		self.logFor(ctx).Warnf("Fetching the feed from %s failed: %s", self.endpoint, err)
+		return nil, nil, err
+	}
+	span = tracing.FromContext(ctx).Start("feed.validate")
+	defer span.End()
+	price, err = ParseFeed(raw)
+	if err != nil {
+		self.logFor(ctx).Warnf("Parsing the feed failed: %s", err)
+		span.SetError(err)
+		return raw, nil, err
+	}
+	span.SetAttributes("feed_nonce", price.Nonce, "feed_block", price.Block)
+	logger := self.logFor(ctx).With("feed_nonce", price.Nonce, "feed_block", price.Block)
+	if err := self.Verify(price); err != nil {
+		logger.Warnf("Feed is invalid: %s", err)
+		span.SetError(err)
+		return raw, price, err
+	}
+	logger.Infof("Fetched feed: block(%s), nonce(%s), ask(%s), bid(%s)", price.Block, price.Nonce, price.Ask, price.Bid)
+	return raw, price, nil
+}
+
+// SetLogger makes the corpus log through logger outside of feed cycles
+func (self *FeedCorpus) SetLogger(logger *logging.Logger) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.logger = logger
+}
+
+func (self *FeedCorpus) log() *logging.Logger {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.logger
+}
+
+// logFor returns the logger of the cycle ctx belongs to
+func (self *FeedCorpus) logFor(ctx context.Context) *logging.Logger {
+	return logging.FromContext(ctx, self.log())
+}
+
+func (self *FeedCorpus) Endpoint() string {
+	return self.endpoint
+}
+
+func (self *FeedCorpus) Verify(price *Price) error {
+	return price.Verify(self.signer)
+}
+
+func (self *FeedCorpus) fetch(ctx context.Context) ([]byte, error) {
+	r, err := self.client.Get(self.endpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -109,8 +178,23 @@ func (self *FeedCorpus) GetFeedFromEndpoint() (*Price, error) {
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("data: %s", data)
-	err = json.Unmarshal(data, &result)
+	self.logFor(ctx).Debugf("data: %s", data)
+	return data, nil
+}
+
+func (self *FeedCorpus) GetFeedFromEndpoint() (*Price, error) {
+	data, err := self.fetch(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return ParseFeed(data)
+}
+
+// ParseFeed parses a feed in the format of the Digix endpoint:
+// {"data": {"block_number": ..., "nonce": ..., ...}, "status": "success"}
+func ParseFeed(data []byte) (*Price, error) {
+	result := PriceFeed{}
+	err := json.Unmarshal(data, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -121,8 +205,16 @@ func (self *FeedCorpus) GetFeedFromEndpoint() (*Price, error) {
 	}
 }
 
-func NewFeedCorpus() *FeedCorpus {
+// NewFeedCorpus returns a corpus fetching feeds from endpoint which must
+// be signed by signer, it panics if signer is the zero address
+func NewFeedCorpus(endpoint string, signer ethereum.Address) *FeedCorpus {
+	if signer == (ethereum.Address{}) {
+		panic("the Digix signer of the feed corpus is not set")
+	}
 	return &FeedCorpus{
-		client: &http.Client{Timeout: 10 * time.Second},
+		client:   &http.Client{Timeout: 10 * time.Second},
+		endpoint: endpoint,
+		signer:   signer,
+		logger:   logging.Std(),
 	}
 }
@@ -0,0 +1,75 @@
+package feed
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// ManualCorpus serves a single feed which Digix sent out of band,
+// ie when their endpoint is down. The feed has the same format as
+// the endpoint's and goes through the same verification.
+type ManualCorpus struct {
+	raw   []byte
+	price *Price
+}
+
+func (self *ManualCorpus) GetFeed(ctx context.Context) (blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte, err error) {
+	f := self.price
+	self.logFeed(ctx)
+	return f.Block, f.Nonce, f.Ask, f.Bid, f.V, f.R, f.S, nil
+}
+
+// GetRawFeed returns the feed as it was sent, see FeedCorpus
+func (self *ManualCorpus) GetRawFeed(ctx context.Context) (raw []byte, price *Price, err error) {
+	self.logFeed(ctx)
+	return self.raw, self.price, nil
+}
+
+// logFeed logs the feed through the logger of the cycle ctx belongs to
+func (self *ManualCorpus) logFeed(ctx context.Context) {
+	f := self.price
+	logging.FromContext(ctx, logging.Std()).Infof("manual feed: block(%s), nonce(%s), ask(%s), bid(%s)", f.Block, f.Nonce, f.Ask, f.Bid)
+}
+
+func (self *ManualCorpus) Price() *Price {
+	return self.price
+}
+
+// NewManualCorpus parses and verifies a feed, see FeedCorpus for signer
+func NewManualCorpus(data []byte, signer ethereum.Address) (*ManualCorpus, error) {
+	price, err := ParseFeed(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := price.Verify(signer); err != nil {
+		return nil, err
+	}
+	return &ManualCorpus{data, price}, nil
+}
+
+func NewManualCorpusFromReader(reader io.Reader, signer ethereum.Address) (*ManualCorpus, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewManualCorpus(data, signer)
+}
+
+// NewManualCorpusFromFile reads the feed from path, "-" means stdin
+func NewManualCorpusFromFile(path string, signer ethereum.Address) (*ManualCorpus, error) {
+	if path == "-" {
+		return NewManualCorpusFromReader(os.Stdin, signer)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewManualCorpusFromReader(f, signer)
+}
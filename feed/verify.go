@@ -0,0 +1,83 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignatureError is returned when a feed is not signed by the Digix
+// signer, a fresh feed from the same source won't be signed by it either
+type SignatureError struct {
+	Reason string
+}
+
+func (self *SignatureError) Error() string {
+	return "invalid Digix signature: " + self.Reason
+}
+
+// IsSignatureError tells if err is a *SignatureError
+func IsSignatureError(err error) bool {
+	_, ok := err.(*SignatureError)
+	return ok
+}
+
+// SignedMessage returns the message Digix signs:
+// block number, nonce, ask and bid, each as a 32 bytes big endian
+func (self *Price) SignedMessage() []byte {
+	msg := []byte{}
+	for _, n := range []*big.Int{self.Block, self.Nonce, self.Ask, self.Bid} {
+		msg = append(msg, math.PaddedBigBytes(n, 32)...)
+	}
+	return msg
+}
+
+// RecoverSigner returns the address which signed the feed
+func (self *Price) RecoverSigner() (ethereum.Address, error) {
+	if self.V != 27 && self.V != 28 {
+		return ethereum.Address{}, errors.New(fmt.Sprintf("invalid signature v: %d", self.V))
+	}
+	hash := crypto.Keccak256(self.SignedMessage())
+	sig := make([]byte, 65)
+	copy(sig[0:32], self.R[:])
+	copy(sig[32:64], self.S[:])
+	sig[64] = self.V - 27
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return ethereum.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// Verify checks the feed's signature and its values. The feed must be
+// signed by signer, the signer the feed claims is never trusted.
+func (self *Price) Verify(signer ethereum.Address) error {
+	if signer == (ethereum.Address{}) {
+		return errors.New("no Digix signer to verify the feed against")
+	}
+	if self.Block == nil || self.Nonce == nil || self.Ask == nil || self.Bid == nil {
+		return errors.New("feed is missing block number, nonce, ask or bid")
+	}
+	if self.Ask.Sign() <= 0 || self.Bid.Sign() <= 0 {
+		return errors.New(fmt.Sprintf("ask(%s) and bid(%s) must be positive", self.Ask, self.Bid))
+	}
+	if self.Ask.Cmp(self.Bid) < 0 {
+		return errors.New(fmt.Sprintf("ask(%s) is lower than bid(%s)", self.Ask, self.Bid))
+	}
+	hash := ethereum.BytesToHash(crypto.Keccak256(self.SignedMessage()))
+	if self.Hash != (ethereum.Hash{}) && self.Hash != hash {
+		return errors.New(fmt.Sprintf("feed hash %s doesn't match its values, expected %s", self.Hash.Hex(), hash.Hex()))
+	}
+	recovered, err := self.RecoverSigner()
+	if err != nil {
+		return &SignatureError{err.Error()}
+	}
+	if recovered != signer {
+		return &SignatureError{fmt.Sprintf("feed is signed by %s, expected %s", recovered.Hex(), signer.Hex())}
+	}
+	return nil
+}
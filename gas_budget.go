@@ -0,0 +1,222 @@
+package dgxpricing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/alert"
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/history"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// FEED_GAS_ESTIMATE is the gas a setPriceFeed is assumed to use when
+	// it is checked against the budget, before it is built
+	FEED_GAS_ESTIMATE uint64 = 200000
+	// CANCEL_GAS_ESTIMATE is the gas a 0 ETH self transfer is assumed to use
+	CANCEL_GAS_ESTIMATE uint64 = 21000
+	// BUDGET_WINDOW is how long spent ETH counts against the daily budget
+	BUDGET_WINDOW time.Duration = 24 * time.Hour
+)
+
+// BudgetError tells why a tx was not sent, the attempt is abandoned
+type BudgetError struct {
+	Reason string
+}
+
+func (self *BudgetError) Error() string {
+	return "gas budget exceeded: " + self.Reason
+}
+
+// IsBudgetError tells if err is a *BudgetError
+func IsBudgetError(err error) bool {
+	_, ok := err.(*BudgetError)
+	return ok
+}
+
+// FeedExpiry tells how close the on-chain feed is to be too old for
+// trades, ie when getConversionRate starts returning 0
+type FeedExpiry interface {
+	// BlocksToExpiry is negative once the feed expired
+	BlocksToExpiry() (int64, error)
+}
+
+type spending struct {
+	time time.Time
+	wei  *big.Int
+}
+
+// GasBudget caps the gas price of every tx, the number of replacements
+// of a tx and the ETH spent by the pricing operators over the last 24
+// hours. When the on-chain feed is about to expire, the emergency max gas
+// price applies instead and replacements and the daily budget are not
+// capped.
+// GasBudget is thread safe.
+type GasBudget struct {
+	// nil and negative values mean no cap
+	maxGasPrice     *big.Int
+	maxReplacements int
+	daily           *big.Int
+	// emergencyBlocks is 0 when there is no emergency override
+	emergencyBlocks      uint64
+	emergencyMaxGasPrice *big.Int
+	clock                clock.Clock
+
+	mu        sync.Mutex
+	spendings map[common.Hash]spending
+}
+
+// EnableEmergency lifts the budget when the on-chain feed expires in
+// blocks or less, txs are still capped at maxGasPrice if it is not nil
+func (self *GasBudget) EnableEmergency(blocks uint64, maxGasPrice *big.Int) {
+	self.emergencyBlocks = blocks
+	self.emergencyMaxGasPrice = maxGasPrice
+}
+
+// Spend records that the tx with hash spent wei at time at, recording a
+// tx again doesn't count it twice
+func (self *GasBudget) Spend(hash common.Hash, wei *big.Int, at time.Time) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.spendings[hash] = spending{at, wei}
+}
+
+// Seed records the cost of final txs of the history, so the daily budget
+// survives restarts
+func (self *GasBudget) Seed(txs []history.Tx) {
+	for _, tx := range txs {
+		if tx.Status == "mined" || tx.Status == "failed" {
+			wei := new(big.Int).Mul(new(big.Int).SetUint64(tx.GasUsed), tx.GasPrice)
+			self.Spend(tx.Hash, wei, tx.Updated)
+		}
+	}
+}
+
+// Spent returns the ETH spent in the last 24 hours, in wei
+func (self *GasBudget) Spent() *big.Int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	since := self.clock.Now().Add(-BUDGET_WINDOW)
+	result := big.NewInt(0)
+	for hash, s := range self.spendings {
+		if s.time.Before(since) {
+			delete(self.spendings, hash)
+			continue
+		}
+		result.Add(result, s.wei)
+	}
+	return result
+}
+
+// Check tells if a tx with gasPrice and gas can be sent as the
+// replacements-th replacement, 0 for the first tx
+func (self *GasBudget) Check(gasPrice *big.Int, gas uint64, replacements int, emergency bool) error {
+	if emergency {
+		if self.emergencyMaxGasPrice != nil && gasPrice.Cmp(self.emergencyMaxGasPrice) > 0 {
+			return &BudgetError{fmt.Sprintf("gas price %s is above the emergency max %s", gasPrice, self.emergencyMaxGasPrice)}
+		}
+		return nil
+	}
+	if self.maxGasPrice != nil && gasPrice.Cmp(self.maxGasPrice) > 0 {
+		return &BudgetError{fmt.Sprintf("gas price %s is above the max %s", gasPrice, self.maxGasPrice)}
+	}
+	if self.maxReplacements >= 0 && replacements > self.maxReplacements {
+		return &BudgetError{fmt.Sprintf("the tx was already replaced %d times", self.maxReplacements)}
+	}
+	if self.daily != nil {
+		cost := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gas))
+		spent := self.Spent()
+		if new(big.Int).Add(spent, cost).Cmp(self.daily) > 0 {
+			return &BudgetError{fmt.Sprintf("spent %s wei in 24 hours, a tx costing up to %s wei is above the daily budget of %s wei", spent, cost, self.daily)}
+		}
+	}
+	return nil
+}
+
+// NewGasBudget returns a budget, nil maxGasPrice or daily and negative
+// maxReplacements are not capped
+func NewGasBudget(maxGasPrice *big.Int, maxReplacements int, daily *big.Int, clock clock.Clock) *GasBudget {
+	return &GasBudget{
+		maxGasPrice:     maxGasPrice,
+		maxReplacements: maxReplacements,
+		daily:           daily,
+		clock:           clock,
+		spendings:       map[common.Hash]spending{},
+	}
+}
+
+// SetGasBudget makes the feeder abandon txs which go over budget
+func (self *PriceFeeder) SetGasBudget(budget *GasBudget) {
+	self.budget = budget
+}
+
+// SetAlerter sets where the feeder raises alerts, they are only logged
+// by default
+func (self *PriceFeeder) SetAlerter(alerter alert.Alerter) {
+	self.alerter = alerter
+}
+
+// emergency tells if the on-chain feed is about to expire so the budget
+// is lifted
+func (self *PriceFeeder) emergency(ctx context.Context) bool {
+	expiry, ok := self.reserve.(FeedExpiry)
+	if self.budget.emergencyBlocks == 0 || !ok {
+		return false
+	}
+	left, err := expiry.BlocksToExpiry()
+	if err != nil {
+		self.log(ctx).Warnf("Checking the on-chain feed expiry failed: %s", err)
+		return false
+	}
+	if left > int64(self.budget.emergencyBlocks) {
+		return false
+	}
+	self.log(ctx).Infof("The on-chain feed expires in %d blocks, lifting the gas budget", left)
+	budgetEmergencies.Inc(1)
+	return true
+}
+
+// checkBudget tells if a tx can be sent, and alerts when it can't
+func (self *PriceFeeder) checkBudget(ctx context.Context, gasPrice *big.Int, gas uint64, replacements int) error {
+	if self.budget == nil {
+		return nil
+	}
+	err := self.budget.Check(gasPrice, gas, replacements, self.emergency(ctx))
+	if err != nil {
+		budgetExceeded.Inc(1)
+		self.alerter.Alert(alert.CRITICAL, fmt.Sprintf("Abandoning a tx of the pricing operator: %s", err))
+	}
+	return err
+}
+
+// recordCost keeps the receipt of a final tx in the history and its cost
+// in the gas budget. Without a receipt the cost is taken at the gas limit.
+func (self *PriceFeeder) recordCost(ctx context.Context, tx *types.Transaction) {
+	if self.history == nil && self.budget == nil {
+		return
+	}
+	gasUsed := tx.Gas().Uint64()
+	if receipts, ok := self.reserve.(ReceiptSource); ok {
+		blockNumber, used, err := receipts.Receipt(tx.Hash())
+		if err != nil {
+			self.log(ctx).Warnf("Getting receipt of tx %s failed: %s", tx.Hash().Hex(), err)
+		} else {
+			gasUsed = used
+			if self.history != nil {
+				if err := self.history.SetReceipt(tx.Hash(), blockNumber, gasUsed); err != nil {
+					self.log(ctx).Warnf("Recording receipt of tx %s in the history failed: %s", tx.Hash().Hex(), err)
+				}
+			}
+		}
+	}
+	if self.budget != nil {
+		cost := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(gasUsed))
+		self.budget.Spend(tx.Hash(), cost, self.clock.Now())
+		budgetSpent.Update(new(big.Int).Div(self.budget.Spent(), big.NewInt(1000000000)).Int64())
+	}
+}
@@ -0,0 +1,140 @@
+package dgxpricing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/alert"
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// runUntilDone advances clk by the poll interval every time the feeder
+// sleeps until the cycle is done
+func runUntilDone(t *testing.T, clk *clock.FakeClock, done chan cycleResult) cycleResult {
+	for i := 0; i < 1000; i++ {
+		sleeping := make(chan struct{})
+		go func() {
+			clk.BlockUntil(1)
+			close(sleeping)
+		}()
+		select {
+		case r := <-done:
+			return r
+		case <-sleeping:
+			clk.Advance(10 * time.Second)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Expected the feeder to sleep or finish")
+		}
+	}
+	t.Fatalf("Expected the cycle to finish")
+	return cycleResult{}
+}
+
+func TestMaxReplacementsAbandonsTx(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	h := newHarnessWithClock(t, STUCK_POLICY_REPORT, clk)
+	defer h.Close()
+	alerter := &recordingAlerter{}
+	h.feeder.SetAlerter(alerter)
+	h.feeder.SetGasBudget(NewGasBudget(nil, 1, nil, clk))
+	// nothing is ever mined
+	h.backend.Chain.SetMinGasPrice(big.NewInt(100 * GWEI))
+
+	done := make(chan cycleResult, 1)
+	go func() {
+		tx, status, err := h.feeder.FeedOnce()
+		done <- cycleResult{tx, status, err}
+	}()
+	r := runUntilDone(t, clk, done)
+	if !IsBudgetError(r.err) || r.status != "pending" {
+		t.Fatalf("Expected the feed to be abandoned over budget, got status %s, err %v", r.status, r.err)
+	}
+	if txs := h.journal.TxsAtNonce(h.backend.Operator(), 0); len(txs) != 2 {
+		t.Fatalf("Expected the feed and 1 replacement, got %d txs", len(txs))
+	}
+	if alerts := alerter.take(); len(alerts) != 1 || alerts[0].level != alert.CRITICAL {
+		t.Fatalf("Expected a critical alert, got %v", alerts)
+	}
+}
+
+func TestDailyBudget(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	alerter := &recordingAlerter{}
+	h.feeder.SetAlerter(alerter)
+	// enough for one feed
+	budget := NewGasBudget(nil, -1, big.NewInt(INIT_GASPRICE*int64(FEED_GAS_ESTIMATE)), clock.NewRealClock())
+	h.feeder.SetGasBudget(budget)
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	if _, status, err := wait(t, h.feeder.FeedOnce); err != nil || status != "mined" {
+		t.Fatalf("Expected the first feed to be mined, got status %s, err %v", status, err)
+	}
+	if budget.Spent().Sign() <= 0 {
+		t.Fatalf("Expected the cost of the feed to be recorded")
+	}
+	if _, _, err := wait(t, h.feeder.FeedOnce); !IsBudgetError(err) {
+		t.Fatalf("Expected the second feed to be over the daily budget, got err %v", err)
+	}
+	if alerts := alerter.take(); len(alerts) != 1 {
+		t.Fatalf("Expected an alert, got %v", alerts)
+	}
+
+	// the emergency override lifts the budget when the on-chain feed is
+	// about to expire, the stand-in reserve's drift is small enough
+	budget.EnableEmergency(1000000, nil)
+	if _, status, err := wait(t, h.feeder.FeedOnce); err != nil || status != "mined" {
+		t.Fatalf("Expected the emergency feed to be mined, got status %s, err %v", status, err)
+	}
+}
+
+func TestCancelOverBudgetIsRefused(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	alerter := &recordingAlerter{}
+	h.feeder.SetAlerter(alerter)
+	h.feeder.SetGasBudget(NewGasBudget(big.NewInt(INIT_GASPRICE), -1, nil, clock.NewRealClock()))
+	feedTx, err := h.feeder.TryFeedingPrice()
+	if err != nil {
+		t.Fatalf("Expected to send the feed but got error: %v", err)
+	}
+	// the cancel has to outbid the feed, above the max gas price
+	if _, err := h.feeder.Cancel(nil); !IsBudgetError(err) {
+		t.Fatalf("Expected the cancel to be over budget, got err %v", err)
+	}
+	if txs := h.journal.TxsAtNonce(h.backend.Operator(), feedTx.Nonce()); len(txs) != 1 {
+		t.Fatalf("Expected only the feed at nonce %d, got %d txs", feedTx.Nonce(), len(txs))
+	}
+	if alerts := alerter.take(); len(alerts) != 1 || alerts[0].level != alert.CRITICAL {
+		t.Fatalf("Expected a critical alert, got %v", alerts)
+	}
+}
+
+func TestStuckNonceReplacementOverBudgetIsRefused(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_CANCEL)
+	defer h.Close()
+	alerter := &recordingAlerter{}
+	h.feeder.SetAlerter(alerter)
+	h.feeder.SetGasBudget(NewGasBudget(big.NewInt(50*GWEI), -1, nil, clock.NewRealClock()))
+	// an unknown tx whose replacement is above the max gas price
+	stuck := types.NewTransaction(0, h.backend.Operator(), big.NewInt(0), big.NewInt(21000), big.NewInt(100*GWEI), nil)
+	stuck, err := types.SignTx(stuck, types.HomesteadSigner{}, h.backend.OperatorKey)
+	if err != nil {
+		t.Fatalf("Expected to sign the stuck tx but got error: %v", err)
+	}
+	h.backend.Chain.SetMinGasPrice(big.NewInt(200 * GWEI))
+	if err := h.backend.Chain.SendTransaction(stuck); err != nil {
+		t.Fatalf("Expected to send the stuck tx but got error: %v", err)
+	}
+
+	h.feeder.checkNonces(context.Background())
+	if txs := h.journal.TxsAtNonce(h.backend.Operator(), 0); len(txs) != 0 {
+		t.Fatalf("Expected the stuck tx not to be replaced, got %d txs", len(txs))
+	}
+	if alerts := alerter.take(); len(alerts) != 1 || alerts[0].level != alert.CRITICAL {
+		t.Fatalf("Expected a critical alert, got %v", alerts)
+	}
+}
@@ -0,0 +1,88 @@
+package dgxpricing
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/KyberNetwork/dgx-price-feeder/history"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// historyJournal keeps journaled txs and their statuses in the history
+// as well, so txs sent by the nonce checker and cancels are not missed.
+// The journal is the source of truth, history errors are only logged.
+type historyJournal struct {
+	TxJournal
+	history History
+	logger  *logging.Logger
+}
+
+func (self historyJournal) Record(kind string, tx *types.Transaction) error {
+	if err := self.TxJournal.Record(kind, tx); err != nil {
+		return err
+	}
+	if err := self.history.RecordTx(kind, tx); err != nil {
+		self.logger.Warnf("Recording tx %s in the history failed: %s", tx.Hash().Hex(), err)
+	}
+	return nil
+}
+
+func (self historyJournal) SetStatus(hash common.Hash, status string) error {
+	if err := self.TxJournal.SetStatus(hash, status); err != nil {
+		return err
+	}
+	if err := self.history.SetStatus(hash, status); err != nil {
+		self.logger.Warnf("Recording status of tx %s in the history failed: %s", hash.Hex(), err)
+	}
+	return nil
+}
+
+// SetHistory makes the feeder keep every feed it fetches, what it
+// decides about it and every tx it sends in history
+func (self *PriceFeeder) SetHistory(history History) {
+	self.history = history
+	self.journal = historyJournal{self.journal, history, self.logger}
+	self.nonces.journal = self.journal
+}
+
+// getFeed gets a feed from prices, filling in record with what the
+// history keeps of it
+func (self *PriceFeeder) getFeed(ctx context.Context, prices PriceCorpus, record *history.Feed) (blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte, err error) {
+	if raw, ok := prices.(RawPriceCorpus); ok {
+		data, price, err := raw.GetRawFeed(ctx)
+		record.Raw = string(data)
+		if price != nil {
+			record.BlockNumber, record.Nonce, record.Ask, record.Bid = price.Block, price.Nonce, price.Ask, price.Bid
+		}
+		if err != nil {
+			return nil, nil, nil, nil, 0, [32]byte{}, [32]byte{}, err
+		}
+		record.Verified = true
+		return price.Block, price.Nonce, price.Ask, price.Bid, price.V, price.R, price.S, nil
+	}
+	blockNumber, nonce, ask1KDigix, bid1KDigix, v, r, s, err = prices.GetFeed(ctx)
+	if err == nil {
+		record.BlockNumber, record.Nonce, record.Ask, record.Bid = blockNumber, nonce, ask1KDigix, bid1KDigix
+		record.Verified = true
+	}
+	return
+}
+
+// recordFeed keeps record with the decision made about it, tx is the tx
+// it was sent with if any
+func (self *PriceFeeder) recordFeed(ctx context.Context, record *history.Feed, decision string, reason string, tx *types.Transaction) {
+	if self.history == nil {
+		return
+	}
+	record.Decision = decision
+	record.Reason = reason
+	if tx != nil {
+		hash := tx.Hash()
+		record.Tx = &hash
+	}
+	if err := self.history.RecordFeed(record); err != nil {
+		self.log(ctx).Warnf("Recording feed in the history failed: %s", err)
+	}
+}
@@ -0,0 +1,93 @@
+package history
+
+import (
+	"encoding/csv"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+func bigString(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return n.String()
+}
+
+// WriteFeedsCSV writes feeds as csv with a header line, the raw feeds
+// are left out
+func WriteFeedsCSV(w io.Writer, feeds []Feed) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "time", "block_number", "nonce", "ask_for_1000", "bid_for_1000", "verified", "decision", "reason", "tx"})
+	for _, f := range feeds {
+		tx := ""
+		if f.Tx != nil {
+			tx = f.Tx.Hex()
+		}
+		out.Write([]string{
+			strconv.FormatUint(f.ID, 10),
+			f.Time.UTC().Format(time.RFC3339),
+			bigString(f.BlockNumber),
+			bigString(f.Nonce),
+			bigString(f.Ask),
+			bigString(f.Bid),
+			strconv.FormatBool(f.Verified),
+			f.Decision,
+			f.Reason,
+			tx,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// WriteTxsCSV writes txs as csv with a header line, the raw txs are
+// left out
+func WriteTxsCSV(w io.Writer, txs []Tx) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"hash", "time", "kind", "from", "nonce", "gas_price", "status", "block", "gas_used", "updated"})
+	for _, tx := range txs {
+		out.Write([]string{
+			tx.Hash.Hex(),
+			tx.Time.UTC().Format(time.RFC3339),
+			tx.Kind,
+			tx.From.Hex(),
+			strconv.FormatUint(tx.Nonce, 10),
+			tx.GasPrice.String(),
+			tx.Status,
+			strconv.FormatUint(tx.Block, 10),
+			strconv.FormatUint(tx.GasUsed, 10),
+			tx.Updated.UTC().Format(time.RFC3339),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// WriteReportCSV writes one csv line per day with a header line
+func WriteReportCSV(w io.Writer, days []DayReport) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"day", "feeds", "rejected", "updates", "txs", "failed", "avg_staleness_blocks", "max_staleness_blocks", "gas_used", "eth_spent", "usd_cost"})
+	for _, d := range days {
+		out.Write([]string{
+			d.Day,
+			strconv.Itoa(d.Feeds),
+			strconv.Itoa(d.Rejected),
+			strconv.Itoa(d.Updates),
+			strconv.Itoa(d.Txs),
+			strconv.Itoa(d.Failed),
+			formatFloat(d.AvgStaleness),
+			strconv.FormatUint(d.MaxStaleness, 10),
+			strconv.FormatUint(d.GasUsed, 10),
+			formatFloat(d.EthSpent),
+			formatFloat(d.UsdCost),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,472 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// decisions made about a fetched feed
+	DECISION_SUBMITTED string = "submitted"
+	DECISION_SKIPPED   string = "skipped"
+	DECISION_REJECTED  string = "rejected"
+
+	// status of txs which are not final yet
+	STATUS_PENDING string = "pending"
+
+	// OPEN_TIMEOUT is how long to wait for another process to close the
+	// database, ie the history command while the feeder is writing
+	OPEN_TIMEOUT time.Duration = 5 * time.Second
+)
+
+var (
+	feedsBucket       = []byte("feeds")
+	txsBucket         = []byte("txs")
+	feedsByTimeBucket = []byte("feeds_by_time")
+	txsByTimeBucket   = []byte("txs_by_time")
+)
+
+// Feed is a feed the feeder fetched, whether it is valid and what the
+// feeder did with it
+type Feed struct {
+	ID   uint64    `json:"id"`
+	Time time.Time `json:"time"`
+	// Raw is the feed as it was received, empty if the corpus doesn't
+	// keep it
+	Raw         string   `json:"raw,omitempty"`
+	BlockNumber *big.Int `json:"block_number,omitempty"`
+	Nonce       *big.Int `json:"nonce,omitempty"`
+	Ask         *big.Int `json:"ask_for_1000,omitempty"`
+	Bid         *big.Int `json:"bid_for_1000,omitempty"`
+	Verified    bool     `json:"verified"`
+	Decision    string   `json:"decision"`
+	// Reason tells why the feed was skipped or rejected
+	Reason string         `json:"reason,omitempty"`
+	Tx     *ethereum.Hash `json:"tx,omitempty"`
+}
+
+// Tx is a tx the feeder signed, ie a feed, a replacement or a cancel,
+// and its outcome
+type Tx struct {
+	Hash     ethereum.Hash    `json:"hash"`
+	Time     time.Time        `json:"time"`
+	Kind     string           `json:"kind"`
+	From     ethereum.Address `json:"from"`
+	Nonce    uint64           `json:"nonce"`
+	GasPrice *big.Int         `json:"gas_price"`
+	RawTx    string           `json:"raw_tx"`
+	Status   string           `json:"status"`
+	// Block and GasUsed are set from the receipt of mined or failed txs
+	Block   uint64 `json:"block,omitempty"`
+	GasUsed uint64 `json:"gas_used,omitempty"`
+	// Updated is when the status last changed
+	Updated time.Time `json:"updated"`
+}
+
+// Filter selects feeds and txs, zero fields select everything
+type Filter struct {
+	// Since is inclusive, Until is exclusive
+	Since time.Time
+	Until time.Time
+	// Nonce is the Digix nonce of feeds and the nonce of txs
+	Nonce *uint64
+	// Outcome is the decision of feeds and the status of txs
+	Outcome string
+	// Limit keeps only the latest ones
+	Limit int
+}
+
+// parseTime accepts RFC3339 times and dates, dates are in UTC
+func parseTime(str string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", str)
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("invalid time %s, expected RFC3339 or YYYY-MM-DD", str))
+	}
+	return t, nil
+}
+
+// ParseFilter parses a filter from the query parameters of the api or
+// the flags of the history command, empty strings select everything
+func ParseFilter(since string, until string, nonce string, outcome string, limit string) (Filter, error) {
+	result := Filter{Outcome: outcome}
+	var err error
+	if since != "" {
+		if result.Since, err = parseTime(since); err != nil {
+			return result, err
+		}
+	}
+	if until != "" {
+		if result.Until, err = parseTime(until); err != nil {
+			return result, err
+		}
+	}
+	if nonce != "" {
+		n, err := strconv.ParseUint(nonce, 0, 64)
+		if err != nil {
+			return result, errors.New(fmt.Sprintf("invalid nonce %s", nonce))
+		}
+		result.Nonce = &n
+	}
+	if limit != "" {
+		if result.Limit, err = strconv.Atoi(limit); err != nil || result.Limit < 0 {
+			return result, errors.New(fmt.Sprintf("invalid limit %s", limit))
+		}
+	}
+	return result, nil
+}
+
+func (self Filter) matchTime(t time.Time) bool {
+	return (self.Since.IsZero() || !t.Before(self.Since)) &&
+		(self.Until.IsZero() || t.Before(self.Until))
+}
+
+func (self Filter) matchFeed(feed *Feed) bool {
+	if self.Nonce != nil && (feed.Nonce == nil || !feed.Nonce.IsUint64() || feed.Nonce.Uint64() != *self.Nonce) {
+		return false
+	}
+	return self.matchTime(feed.Time) && (self.Outcome == "" || self.Outcome == feed.Decision)
+}
+
+func (self Filter) matchTx(tx *Tx) bool {
+	if self.Nonce != nil && tx.Nonce != *self.Nonce {
+		return false
+	}
+	return self.matchTime(tx.Time) && (self.Outcome == "" || self.Outcome == tx.Status)
+}
+
+// Store keeps the history in a bolt database. The database is opened on
+// first use and kept open until Close, so only one process can use it:
+// query a running feeder through its admin api. Feeds and txs are
+// indexed by time so queries only read the selected range.
+// Store is thread safe.
+type Store struct {
+	path  string
+	clock clock.Clock
+
+	mu sync.Mutex
+	db *bolt.DB
+}
+
+// open returns the database, opening it on first use. When create is
+// false and nothing was recorded yet it returns nil.
+func (self *Store) open(create bool) (*bolt.DB, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.db != nil {
+		return self.db, nil
+	}
+	if _, err := os.Stat(self.path); os.IsNotExist(err) && !create {
+		return nil, nil
+	}
+	db, err := bolt.Open(self.path, 0600, &bolt.Options{Timeout: OPEN_TIMEOUT})
+	if err == bolt.ErrTimeout {
+		return nil, errors.New(fmt.Sprintf("history %s is used by another process, query a running feeder through its admin api", self.path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Update(initBuckets); err != nil {
+		db.Close()
+		return nil, err
+	}
+	self.db = db
+	return db, nil
+}
+
+// Close closes the database, it is opened again on the next use
+func (self *Store) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.db == nil {
+		return nil
+	}
+	err := self.db.Close()
+	self.db = nil
+	return err
+}
+
+// initBuckets creates the buckets, and the time indexes of feeds and
+// txs recorded before they existed
+func initBuckets(btx *bolt.Tx) error {
+	feeds, err := btx.CreateBucketIfNotExists(feedsBucket)
+	if err != nil {
+		return err
+	}
+	txs, err := btx.CreateBucketIfNotExists(txsBucket)
+	if err != nil {
+		return err
+	}
+	if btx.Bucket(feedsByTimeBucket) == nil {
+		index, err := btx.CreateBucket(feedsByTimeBucket)
+		if err != nil {
+			return err
+		}
+		err = feeds.ForEach(func(k []byte, v []byte) error {
+			feed := Feed{}
+			if err := json.Unmarshal(v, &feed); err != nil {
+				return err
+			}
+			return index.Put(timeKey(feed.Time, k), nil)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if btx.Bucket(txsByTimeBucket) == nil {
+		index, err := btx.CreateBucket(txsByTimeBucket)
+		if err != nil {
+			return err
+		}
+		return txs.ForEach(func(k []byte, v []byte) error {
+			tx := Tx{}
+			if err := json.Unmarshal(v, &tx); err != nil {
+				return err
+			}
+			return index.Put(timeKey(tx.Time, k), nil)
+		})
+	}
+	return nil
+}
+
+// buckets of a read write transaction
+type buckets struct {
+	feeds, feedsByTime, txs, txsByTime *bolt.Bucket
+}
+
+func (self *Store) update(f func(b buckets) error) error {
+	db, err := self.open(true)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(btx *bolt.Tx) error {
+		return f(buckets{
+			btx.Bucket(feedsBucket), btx.Bucket(feedsByTimeBucket),
+			btx.Bucket(txsBucket), btx.Bucket(txsByTimeBucket),
+		})
+	})
+}
+
+// view calls f with the values of bucket indexed by index between the
+// times of filter, from the latest one, until f returns false. f is not
+// called if nothing was recorded yet.
+func (self *Store) view(bucket []byte, index []byte, filter Filter, f func(v []byte) (bool, error)) error {
+	db, err := self.open(false)
+	if err != nil || db == nil {
+		return err
+	}
+	return db.View(func(btx *bolt.Tx) error {
+		values := btx.Bucket(bucket)
+		c := btx.Bucket(index).Cursor()
+		var k []byte
+		if filter.Until.IsZero() {
+			k, _ = c.Last()
+		} else if k, _ = c.Seek(itob(timeNanos(filter.Until))); k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+		since := itob(timeNanos(filter.Since))
+		for ; k != nil && bytes.Compare(k[:8], since) >= 0; k, _ = c.Prev() {
+			v := values.Get(k[8:])
+			if v == nil {
+				continue
+			}
+			more, err := f(v)
+			if err != nil || !more {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// timeNanos orders times in keys, times before 1970 come first
+func timeNanos(t time.Time) uint64 {
+	if t.Before(time.Unix(0, 0)) {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+// timeKey is the key of the record with key in a time index
+func timeKey(t time.Time, key []byte) []byte {
+	return append(itob(timeNanos(t)), key...)
+}
+
+func itob(n uint64) []byte {
+	result := make([]byte, 8)
+	binary.BigEndian.PutUint64(result, n)
+	return result
+}
+
+// RecordFeed stores feed with a new ID
+func (self *Store) RecordFeed(feed *Feed) error {
+	return self.update(func(b buckets) error {
+		id, err := b.feeds.NextSequence()
+		if err != nil {
+			return err
+		}
+		feed.ID = id
+		data, err := json.Marshal(feed)
+		if err != nil {
+			return err
+		}
+		if err := b.feedsByTime.Put(timeKey(feed.Time, itob(id)), nil); err != nil {
+			return err
+		}
+		return b.feeds.Put(itob(id), data)
+	})
+}
+
+func sender(tx *types.Transaction) (ethereum.Address, error) {
+	if tx.Protected() {
+		return types.Sender(types.NewEIP155Signer(tx.ChainId()), tx)
+	}
+	return types.Sender(types.HomesteadSigner{}, tx)
+}
+
+// RecordTx stores a pending signed tx, recording it again keeps its
+// status
+func (self *Store) RecordTx(kind string, tx *types.Transaction) error {
+	from, err := sender(tx)
+	if err != nil {
+		return err
+	}
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+	now := self.clock.Now()
+	return self.update(func(b buckets) error {
+		if b.txs.Get(tx.Hash().Bytes()) != nil {
+			return nil
+		}
+		data, err := json.Marshal(Tx{
+			Hash:     tx.Hash(),
+			Time:     now,
+			Kind:     kind,
+			From:     from,
+			Nonce:    tx.Nonce(),
+			GasPrice: tx.GasPrice(),
+			RawTx:    hexutil.Encode(raw),
+			Status:   STATUS_PENDING,
+			Updated:  now,
+		})
+		if err != nil {
+			return err
+		}
+		if err := b.txsByTime.Put(timeKey(now, tx.Hash().Bytes()), nil); err != nil {
+			return err
+		}
+		return b.txs.Put(tx.Hash().Bytes(), data)
+	})
+}
+
+// updateTx applies f to the tx with hash, txs which were not recorded
+// are ignored
+func (self *Store) updateTx(hash ethereum.Hash, f func(tx *Tx)) error {
+	return self.update(func(b buckets) error {
+		data := b.txs.Get(hash.Bytes())
+		if data == nil {
+			return nil
+		}
+		tx := Tx{}
+		if err := json.Unmarshal(data, &tx); err != nil {
+			return err
+		}
+		f(&tx)
+		tx.Updated = self.clock.Now()
+		data, err := json.Marshal(tx)
+		if err != nil {
+			return err
+		}
+		return b.txs.Put(hash.Bytes(), data)
+	})
+}
+
+func (self *Store) SetStatus(hash ethereum.Hash, status string) error {
+	return self.updateTx(hash, func(tx *Tx) {
+		tx.Status = status
+	})
+}
+
+// SetReceipt records the block and the gas used of a final tx
+func (self *Store) SetReceipt(hash ethereum.Hash, blockNumber uint64, gasUsed uint64) error {
+	return self.updateTx(hash, func(tx *Tx) {
+		tx.Block = blockNumber
+		tx.GasUsed = gasUsed
+	})
+}
+
+// full tells if n results reach the limit of filter
+func (self Filter) full(n int) bool {
+	return self.Limit > 0 && n >= self.Limit
+}
+
+// Feeds returns feeds selected by filter, oldest first
+func (self *Store) Feeds(filter Filter) ([]Feed, error) {
+	result := []Feed{}
+	err := self.view(feedsBucket, feedsByTimeBucket, filter, func(v []byte) (bool, error) {
+		feed := Feed{}
+		if err := json.Unmarshal(v, &feed); err != nil {
+			return false, err
+		}
+		if filter.matchFeed(&feed) {
+			result = append(result, feed)
+		}
+		return !filter.full(len(result)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, nil
+}
+
+// Txs returns txs selected by filter, oldest first
+func (self *Store) Txs(filter Filter) ([]Tx, error) {
+	result := []Tx{}
+	err := self.view(txsBucket, txsByTimeBucket, filter, func(v []byte) (bool, error) {
+		tx := Tx{}
+		if err := json.Unmarshal(v, &tx); err != nil {
+			return false, err
+		}
+		if filter.matchTx(&tx) {
+			result = append(result, tx)
+		}
+		return !filter.full(len(result)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, nil
+}
+
+// NewStore returns the history kept in the bolt database at path, it is
+// created on the first write. The store must be closed once used.
+func NewStore(path string, clock clock.Clock) *Store {
+	return &Store{path: path, clock: clock}
+}
@@ -0,0 +1,201 @@
+package history_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/history"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestFilters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	start := time.Date(2018, 4, 6, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFakeClock(start)
+	store := history.NewStore(filepath.Join(dir, "history.db"), clk)
+	defer store.Close()
+
+	// nothing recorded yet
+	if feeds, err := store.Feeds(history.Filter{}); err != nil || len(feeds) != 0 {
+		t.Fatalf("Expected no feed, got %v, err: %v", feeds, err)
+	}
+	key, _ := crypto.GenerateKey()
+	for i := 0; i < 3; i++ {
+		tx, err := types.SignTx(
+			types.NewTransaction(uint64(i), ethereum.Address{}, big.NewInt(0), big.NewInt(21000), big.NewInt(4000000000), nil),
+			types.HomesteadSigner{}, key,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decision := history.DECISION_SUBMITTED
+		if i == 1 {
+			decision = history.DECISION_REJECTED
+		}
+		hash := tx.Hash()
+		feed := &history.Feed{Time: clk.Now(), Nonce: big.NewInt(int64(100 + i)), Decision: decision, Tx: &hash}
+		if err := store.RecordFeed(feed); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.RecordTx("feed", tx); err != nil {
+			t.Fatal(err)
+		}
+		clk.Advance(24 * time.Hour)
+		if i != 1 {
+			store.SetStatus(tx.Hash(), "mined")
+			store.SetReceipt(tx.Hash(), uint64(10+i), 50000)
+		}
+	}
+
+	filter, err := history.ParseFilter("2018-04-07", "2018-04-09T00:00:00Z", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feeds, err := store.Feeds(filter)
+	if err != nil || len(feeds) != 2 || feeds[0].Nonce.Int64() != 101 || feeds[1].ID != 3 {
+		t.Fatalf("Expected feeds 2 and 3 in the time range, got %v, err: %v", feeds, err)
+	}
+	feeds, _ = store.Feeds(history.Filter{Outcome: history.DECISION_SUBMITTED, Limit: 1})
+	if len(feeds) != 1 || feeds[0].ID != 3 {
+		t.Fatalf("Expected the latest submitted feed to be 3, got %v", feeds)
+	}
+	nonce := uint64(102)
+	if feeds, _ = store.Feeds(history.Filter{Nonce: &nonce}); len(feeds) != 1 || feeds[0].ID != 3 {
+		t.Fatalf("Expected feed 3 to have nonce 102, got %v", feeds)
+	}
+
+	txs, err := store.Txs(history.Filter{Outcome: "mined"})
+	if err != nil || len(txs) != 2 || txs[0].Nonce != 0 || txs[1].Block != 12 || txs[1].GasUsed != 50000 {
+		t.Fatalf("Expected txs 0 and 2 to be mined with their receipt, got %v, err: %v", txs, err)
+	}
+	if txs[0].From != crypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("Expected the sender to be recorded, got %s", txs[0].From.Hex())
+	}
+	nonce = 1
+	txs, _ = store.Txs(history.Filter{Nonce: &nonce})
+	if len(txs) != 1 || txs[0].Status != history.STATUS_PENDING {
+		t.Fatalf("Expected tx 1 to be pending, got %v", txs)
+	}
+}
+
+func TestFeedsRecordedBeforeTheTimeIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history.db")
+	// a database written before feeds were indexed by time
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2018, 4, 6, 0, 0, 0, 0, time.UTC)
+	err = db.Update(func(btx *bolt.Tx) error {
+		feeds, err := btx.CreateBucket([]byte("feeds"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 3; i++ {
+			id, _ := feeds.NextSequence()
+			data, _ := json.Marshal(history.Feed{ID: id, Time: start.Add(time.Duration(i) * time.Hour), Decision: history.DECISION_SUBMITTED})
+			if err := feeds.Put([]byte{0, 0, 0, 0, 0, 0, 0, byte(id)}, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clk := clock.NewFakeClock(start.Add(3 * time.Hour))
+	store := history.NewStore(path, clk)
+	defer store.Close()
+	store.RecordFeed(&history.Feed{Time: clk.Now(), Decision: history.DECISION_REJECTED})
+	feeds, err := store.Feeds(history.Filter{Since: start.Add(time.Hour), Limit: 2})
+	if err != nil || len(feeds) != 2 || feeds[0].ID != 3 || feeds[1].ID != 4 {
+		t.Fatalf("Expected the latest 2 feeds to be 3 and 4, got %v, err: %v", feeds, err)
+	}
+	feeds, _ = store.Feeds(history.Filter{Until: start.Add(2 * time.Hour)})
+	if len(feeds) != 2 || feeds[0].ID != 1 || feeds[1].ID != 2 {
+		t.Fatalf("Expected feeds 1 and 2 before the third hour, got %v", feeds)
+	}
+}
+
+type fixedRate float64
+
+func (self fixedRate) GetEthRate(timepoint uint64) float64 {
+	return float64(self)
+}
+
+func TestDailyReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clk := clock.NewFakeClock(time.Date(2018, 4, 6, 23, 0, 0, 0, time.UTC))
+	store := history.NewStore(filepath.Join(dir, "history.db"), clk)
+	defer store.Close()
+	key, _ := crypto.GenerateKey()
+	// a feed mined at block 100 the day before, then on the reported day
+	// feeds mined at blocks 400 and 500 and a failed cancel
+	sends := []struct {
+		data    []byte
+		status  string
+		block   uint64
+		advance time.Duration
+	}{
+		{[]byte{1}, "mined", 100, 2 * time.Hour},
+		{[]byte{1}, "mined", 400, 3 * time.Hour},
+		{nil, "failed", 450, time.Hour},
+		{[]byte{1}, "mined", 500, 23 * time.Hour},
+	}
+	for i, send := range sends {
+		tx, err := types.SignTx(
+			types.NewTransaction(uint64(i), ethereum.Address{}, big.NewInt(0), big.NewInt(100000), big.NewInt(10000000000), send.data),
+			types.HomesteadSigner{}, key,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.RecordFeed(&history.Feed{Time: clk.Now(), Decision: history.DECISION_SUBMITTED})
+		store.RecordTx("feed", tx)
+		store.SetStatus(tx.Hash(), send.status)
+		store.SetReceipt(tx.Hash(), send.block, 50000)
+		clk.Advance(send.advance)
+	}
+
+	report, path, err := history.WriteDailyReport(store, dir, fixedRate(500), clk.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Day != "2018-04-07" || report.Feeds != 3 || report.Updates != 2 || report.Txs != 3 || report.Failed != 1 {
+		t.Fatalf("Expected 3 feeds, 2 updates and a failed tx on 2018-04-07, got %+v", report)
+	}
+	if report.MaxStaleness != 300 || report.AvgStaleness != 200 {
+		t.Fatalf("Expected staleness of 300 and 100 blocks, got max %d avg %f", report.MaxStaleness, report.AvgStaleness)
+	}
+	// 3 txs using 50000 gas at 10 gwei
+	if report.GasUsed != 150000 || report.EthSpent != 0.0015 || report.UsdCost != 0.75 {
+		t.Fatalf("Expected 150000 gas, 0.0015 ETH and 0.75 USD, got %+v", report)
+	}
+	if filepath.Base(path) != "report-2018-04-07.csv" {
+		t.Fatalf("Expected the report to be written to report-2018-04-07.csv, got %s", path)
+	}
+}
@@ -0,0 +1,196 @@
+package history
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// EthRate gives the USD price of 1 ETH at a timepoint in milliseconds,
+// ie BaseBlockchain.GetEthRate. 0 means the rate is unknown.
+type EthRate interface {
+	GetEthRate(timepoint uint64) float64
+}
+
+// DayReport sums up the feeding activity of one UTC day
+type DayReport struct {
+	Day string `json:"day"`
+	// Feeds are the fetched feeds, Rejected the ones which failed
+	// fetching, verification or submission
+	Feeds    int `json:"feeds"`
+	Rejected int `json:"rejected"`
+	// Updates are the setPriceFeed txs mined that day
+	Updates int `json:"updates"`
+	// Txs are all txs mined or failed that day, including cancels and
+	// reverted feeds
+	Txs    int `json:"txs"`
+	Failed int `json:"failed"`
+	// staleness is the number of blocks an on-chain feed stayed before
+	// being updated, counted for the updates of the day
+	AvgStaleness float64 `json:"avg_staleness_blocks"`
+	MaxStaleness uint64  `json:"max_staleness_blocks"`
+	GasUsed      uint64  `json:"gas_used"`
+	EthSpent     float64 `json:"eth_spent"`
+	// UsdCost is 0 when the ETH rate of some txs is unknown
+	UsdCost float64 `json:"usd_cost"`
+}
+
+// Summary is a one line description of the report for notifiers
+func (self DayReport) Summary() string {
+	return fmt.Sprintf(
+		"DGX feeder report %s: %d updates (%d feeds fetched, %d rejected), %d txs (%d failed), staleness avg %.1f max %d blocks, gas used %d, %.6f ETH, %.2f USD",
+		self.Day, self.Updates, self.Feeds, self.Rejected, self.Txs, self.Failed,
+		self.AvgStaleness, self.MaxStaleness, self.GasUsed, self.EthSpent, self.UsdCost,
+	)
+}
+
+func dayOf(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// isUpdate tells if tx is a setPriceFeed, cancels carry no data
+func isUpdate(tx *Tx) bool {
+	raw, err := hexutil.Decode(tx.RawTx)
+	if err != nil {
+		return false
+	}
+	decoded := &types.Transaction{}
+	if err := rlp.DecodeBytes(raw, decoded); err != nil {
+		return false
+	}
+	return len(decoded.Data()) > 0
+}
+
+func weiToEth(wei *big.Int) float64 {
+	result, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e18)).Float64()
+	return result
+}
+
+// Report returns a report for every day from since to until, a zero
+// since starts at the first recorded feed or tx and a zero until ends
+// now. Txs count on the day their final status was recorded.
+func Report(store *Store, since time.Time, until time.Time, rate EthRate) ([]DayReport, error) {
+	feeds, err := store.Feeds(Filter{Since: since, Until: until})
+	if err != nil {
+		return nil, err
+	}
+	// staleness of the first updates needs the ones before since
+	txs, err := store.Txs(Filter{Until: until})
+	if err != nil {
+		return nil, err
+	}
+	if since.IsZero() {
+		if len(feeds) > 0 {
+			since = feeds[0].Time
+		}
+		if len(txs) > 0 && (since.IsZero() || txs[0].Time.Before(since)) {
+			since = txs[0].Time
+		}
+	}
+	if until.IsZero() {
+		until = store.clock.Now()
+	}
+	days := map[time.Time]*DayReport{}
+	result := []DayReport{}
+	if since.IsZero() {
+		return result, nil
+	}
+	for day := dayOf(since); day.Before(until); day = day.AddDate(0, 0, 1) {
+		days[day] = &DayReport{Day: day.Format("2006-01-02")}
+	}
+	for _, feed := range feeds {
+		if r, found := days[dayOf(feed.Time)]; found {
+			r.Feeds++
+			if feed.Decision == DECISION_REJECTED {
+				r.Rejected++
+			}
+		}
+	}
+	final := []Tx{}
+	for _, tx := range txs {
+		if tx.Status == "mined" || tx.Status == "failed" {
+			final = append(final, tx)
+		}
+	}
+	sort.SliceStable(final, func(i, j int) bool {
+		return final[i].Block < final[j].Block
+	})
+	// days with a tx whose ETH rate is unknown get no USD cost
+	unknownRate := map[*DayReport]bool{}
+	totalStaleness := map[*DayReport]uint64{}
+	samples := map[*DayReport]int{}
+	var lastUpdate uint64
+	for _, tx := range final {
+		update := tx.Status == "mined" && isUpdate(&tx)
+		if r, found := days[dayOf(tx.Updated)]; found && !tx.Updated.Before(since) {
+			r.Txs++
+			if tx.Status == "failed" {
+				r.Failed++
+			}
+			r.GasUsed += tx.GasUsed
+			eth := weiToEth(new(big.Int).Mul(new(big.Int).SetUint64(tx.GasUsed), tx.GasPrice))
+			r.EthSpent += eth
+			if usd := rate.GetEthRate(uint64(tx.Updated.UnixNano() / int64(time.Millisecond))); usd > 0 {
+				r.UsdCost += eth * usd
+			} else {
+				unknownRate[r] = true
+			}
+			if update {
+				r.Updates++
+				if lastUpdate > 0 {
+					staleness := tx.Block - lastUpdate
+					totalStaleness[r] += staleness
+					samples[r]++
+					if staleness > r.MaxStaleness {
+						r.MaxStaleness = staleness
+					}
+				}
+			}
+		}
+		if update {
+			lastUpdate = tx.Block
+		}
+	}
+	for day := dayOf(since); day.Before(until); day = day.AddDate(0, 0, 1) {
+		r := days[day]
+		if samples[r] > 0 {
+			r.AvgStaleness = float64(totalStaleness[r]) / float64(samples[r])
+		}
+		if unknownRate[r] {
+			r.UsdCost = 0
+		}
+		result = append(result, *r)
+	}
+	return result, nil
+}
+
+// WriteDailyReport writes the report of the UTC day before now to dir as
+// report-<day>.csv
+func WriteDailyReport(store *Store, dir string, rate EthRate, now time.Time) (*DayReport, string, error) {
+	until := dayOf(now)
+	days, err := Report(store, until.AddDate(0, 0, -1), until, rate)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("report-%s.csv", days[0].Day))
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	if err := WriteReportCSV(f, days); err != nil {
+		return nil, "", err
+	}
+	return &days[0], path, nil
+}
@@ -1,9 +1,13 @@
 package dgxpricing
 
 import (
+	"context"
 	"math/big"
 	"time"
 
+	"github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/KyberNetwork/dgx-price-feeder/feed"
+	"github.com/KyberNetwork/dgx-price-feeder/history"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
@@ -14,12 +18,98 @@ type Runner interface {
 	Stop() error
 }
 
+// PriceCorpus gives the feed to send, ctx carries the logger of the cycle
+// the feed is fetched for
 type PriceCorpus interface {
-	GetFeed() (blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte, err error)
+	GetFeed(ctx context.Context) (blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte, err error)
 }
 
+// RawPriceCorpus also gives feeds as they were received so they can be
+// kept in the history
+type RawPriceCorpus interface {
+	// GetRawFeed returns the raw feed, the parsed feed unless it couldn't
+	// be parsed, and why the feed is invalid
+	GetRawFeed(ctx context.Context) (raw []byte, price *feed.Price, err error)
+}
+
+// Reserve sends and looks up txs of the pricing operator, ctx carries the
+// logger of the cycle the txs are sent for
 type Reserve interface {
-	SetPriceFeed(gasPrice *big.Int, blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte) (*types.Transaction, error)
+	SetPriceFeed(ctx context.Context, gasPrice *big.Int, blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte) (*types.Transaction, error)
+	// SetPriceFeedAt is SetPriceFeed with a specific tx nonce, it is used
+	// to replace a tx which is stuck at that nonce
+	SetPriceFeedAt(ctx context.Context, txNonce *big.Int, gasPrice *big.Int, blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, v uint8, r [32]byte, s [32]byte) (*types.Transaction, error)
+	// SelfTransfer sends 0 ETH from the pricing operator to itself
+	// at txNonce, it is used to cancel a tx at that nonce
+	SelfTransfer(ctx context.Context, txNonce *big.Int, gasPrice *big.Int) (*types.Transaction, error)
 	TxStatus(common.Hash) (status string, blockno uint64, err error)
-	Rebroadcast(tx *types.Transaction) (*types.Transaction, error)
+	Rebroadcast(ctx context.Context, tx *types.Transaction) (*types.Transaction, error)
+	// Replace sends a copy of tx with gasPrice, signed by the sender of tx
+	Replace(ctx context.Context, tx *types.Transaction, gasPrice *big.Int) (*types.Transaction, error)
+	// PricingAddress is the pricing operator txs are sent from
+	PricingAddress() common.Address
+	// MinedNonce and PendingNonce are nonces of the pricing operator
+	MinedNonce() (uint64, error)
+	PendingNonce() (uint64, error)
+	// PendingTxs returns txs of the pricing operator in the node's tx pool,
+	// pending are executable, queued are waiting for a lower nonce
+	PendingTxs() (pending []*types.Transaction, queued []*types.Transaction, err error)
+	// CurrentFeed returns the feed stored in the reserve at the latest block
+	CurrentFeed() (blockNumber *big.Int, nonce *big.Int, ask1KDigix *big.Int, bid1KDigix *big.Int, err error)
+}
+
+// HeadSource notifies new blocks so txs are only checked when their
+// status can have changed
+type HeadSource interface {
+	// SubscribeHeads sends the number of new blocks to heads until
+	// unsubscribe is called
+	SubscribeHeads() (heads <-chan uint64, unsubscribe func())
+}
+
+// TxJournal persists every tx the feeder sends so txs from previous
+// runs can be told apart from txs sent by someone else
+type TxJournal interface {
+	Record(kind string, tx *types.Transaction) error
+	SetStatus(hash common.Hash, status string) error
+	Known(hash common.Hash) bool
+	// SentAt returns the timepoint in ms the tx was journaled at
+	SentAt(hash common.Hash) (uint64, bool)
+	// PendingNonces returns sorted nonces of journaled txs from from
+	// which are not final
+	PendingNonces(from common.Address) []uint64
+	TxsAtNonce(from common.Address, nonce uint64) []*types.Transaction
+}
+
+// ReceiptSource gives the block and the gas used of final txs so they
+// can be kept in the history
+type ReceiptSource interface {
+	Receipt(hash common.Hash) (blockNumber uint64, gasUsed uint64, err error)
+}
+
+// History keeps every fetched feed with the decision made about it, and
+// every tx sent with its outcome
+type History interface {
+	RecordFeed(feed *history.Feed) error
+	RecordTx(kind string, tx *types.Transaction) error
+	SetStatus(hash common.Hash, status string) error
+	SetReceipt(hash common.Hash, blockNumber uint64, gasUsed uint64) error
+}
+
+// OperatorRegistry tells which addresses are operators of the reserve
+// and how the operator set changed
+type OperatorRegistry interface {
+	PricingAddresses() []common.Address
+	GetOperators(atBlock uint64) ([]common.Address, error)
+	LatestBlock() (uint64, error)
+	// OperatorEvents returns operators added and removed from block
+	// fromBlock to block toBlock included
+	OperatorEvents(fromBlock uint64, toBlock uint64) ([]blockchain.OperatorEvent, error)
+}
+
+// Leadership tells if this instance is the one which feeds when several
+// run for high availability
+type Leadership interface {
+	IsLeader() bool
+	// Elected is notified when the instance becomes the leader
+	Elected() <-chan struct{}
 }
@@ -0,0 +1,246 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// kind of the line which only updates status of a journaled tx
+	STATUS_UPDATE string = "status"
+)
+
+// Entry is one line of the journal file. A tx is journaled once
+// when it is sent, its status is journaled later as a separated
+// line with kind STATUS_UPDATE.
+type Entry struct {
+	Time uint64        `json:"time"`
+	Kind string        `json:"kind"`
+	Hash ethereum.Hash `json:"hash"`
+	// From is empty for txs journaled before several pricing operators
+	// were supported, they belong to any of them
+	From     ethereum.Address `json:"from,omitempty"`
+	Nonce    uint64           `json:"nonce,omitempty"`
+	GasPrice *big.Int         `json:"gas_price,omitempty"`
+	Status   string           `json:"status,omitempty"`
+	RawTx    string           `json:"raw_tx,omitempty"`
+}
+
+// FileJournal keeps every tx sent by the feeder in an append only
+// file so txs sent by previous runs can be recognized after restart.
+// FileJournal is thread safe.
+type FileJournal struct {
+	mu      sync.Mutex
+	path    string
+	clock   clock.Clock
+	entries map[ethereum.Hash]*Entry
+}
+
+// timepoint returns the current time of the journal's clock in
+// milliseconds
+func (self *FileJournal) timepoint() uint64 {
+	return uint64(self.clock.Now().UnixNano() / int64(time.Millisecond))
+}
+
+func (self *FileJournal) append(entry Entry) error {
+	f, err := os.OpenFile(self.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (self *FileJournal) apply(entry Entry) {
+	if entry.Kind == STATUS_UPDATE {
+		if e, found := self.entries[entry.Hash]; found {
+			e.Status = entry.Status
+		}
+		return
+	}
+	e := entry
+	self.entries[entry.Hash] = &e
+}
+
+func sender(tx *types.Transaction) (ethereum.Address, error) {
+	if tx.Protected() {
+		return types.Sender(types.NewEIP155Signer(tx.ChainId()), tx)
+	}
+	return types.Sender(types.HomesteadSigner{}, tx)
+}
+
+func (self *Entry) sentBy(from ethereum.Address) bool {
+	return self.From == (ethereum.Address{}) || self.From == from
+}
+
+func (self *FileJournal) Record(kind string, tx *types.Transaction) error {
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+	from, err := sender(tx)
+	if err != nil {
+		return err
+	}
+	entry := Entry{
+		Time:     self.timepoint(),
+		Kind:     kind,
+		Hash:     tx.Hash(),
+		From:     from,
+		Nonce:    tx.Nonce(),
+		GasPrice: tx.GasPrice(),
+		RawTx:    hexutil.Encode(raw),
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if err := self.append(entry); err != nil {
+		return err
+	}
+	self.apply(entry)
+	return nil
+}
+
+func (self *FileJournal) SetStatus(hash ethereum.Hash, status string) error {
+	entry := Entry{
+		Time:   self.timepoint(),
+		Kind:   STATUS_UPDATE,
+		Hash:   hash,
+		Status: status,
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if err := self.append(entry); err != nil {
+		return err
+	}
+	self.apply(entry)
+	return nil
+}
+
+func (self *FileJournal) Known(hash ethereum.Hash) bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	_, found := self.entries[hash]
+	return found
+}
+
+// SentAt returns the timepoint in ms hash was journaled at
+func (self *FileJournal) SentAt(hash ethereum.Hash) (uint64, bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	e, found := self.entries[hash]
+	if !found {
+		return 0, false
+	}
+	return e.Time, true
+}
+
+// PendingNonces returns sorted nonces of journaled txs from from that
+// are not known to be final yet
+func (self *FileJournal) PendingNonces(from ethereum.Address) []uint64 {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	final := map[uint64]bool{}
+	for _, e := range self.entries {
+		if e.Status != "" && e.sentBy(from) {
+			final[e.Nonce] = true
+		}
+	}
+	set := map[uint64]bool{}
+	for _, e := range self.entries {
+		if !final[e.Nonce] && e.sentBy(from) {
+			set[e.Nonce] = true
+		}
+	}
+	result := []uint64{}
+	for n := range set {
+		result = append(result, n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// TxsAtNonce returns all journaled txs from from at nonce
+func (self *FileJournal) TxsAtNonce(from ethereum.Address, nonce uint64) []*types.Transaction {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []*types.Transaction{}
+	for _, e := range self.entries {
+		if e.Nonce != nonce || e.RawTx == "" || !e.sentBy(from) {
+			continue
+		}
+		tx, err := decodeTx(e.RawTx)
+		if err != nil {
+			logging.Std().Warnf("Ignore undecodable journaled tx %s: %s", e.Hash.Hex(), err)
+			continue
+		}
+		result = append(result, tx)
+	}
+	return result
+}
+
+func decodeTx(raw string) (*types.Transaction, error) {
+	data, err := hexutil.Decode(raw)
+	if err != nil {
+		return nil, err
+	}
+	tx := &types.Transaction{}
+	return tx, rlp.DecodeBytes(data, tx)
+}
+
+func (self *FileJournal) load() error {
+	f, err := os.Open(self.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a partially written line, the tx was not sent
+			logging.Std().Warnf("Ignore broken journal line: %s", err)
+			continue
+		}
+		self.apply(entry)
+	}
+	return scanner.Err()
+}
+
+// NewFileJournal loads the journal at path, entries are timed by clock
+func NewFileJournal(path string, clock clock.Clock) *FileJournal {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		panic(err)
+	}
+	j := &FileJournal{
+		path:    path,
+		clock:   clock,
+		entries: map[ethereum.Hash]*Entry{},
+	}
+	if err := j.load(); err != nil {
+		panic(err)
+	}
+	logging.Std().Infof("loaded %d txs from journal %s", len(j.entries), path)
+	return j
+}
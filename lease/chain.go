@@ -0,0 +1,126 @@
+package lease
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+// NonceSource gives the nonces of the pricing operators
+type NonceSource interface {
+	PricingAddresses() []ethereum.Address
+	NoncesOf(addr ethereum.Address) (mined uint64, pending uint64, err error)
+}
+
+// ChainLease needs no shared storage: the pricing operators' nonces are
+// the heartbeat of the leader. A standby takes the lease when their
+// mined and pending nonces haven't moved for the ttl, which must be
+// longer than the feed interval, plus a delay of up to a quarter of the
+// ttl derived from its name so instances which start together don't
+// take it at the same time. The holder keeps the lease by renewing it
+// before it expires, a holder which stalls past the expiry loses it and
+// has to see the nonces idle again to take it back.
+// ChainLease is thread safe.
+type ChainLease struct {
+	nonces NonceSource
+	clock  clock.Clock
+	logger *logging.Logger
+
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+	// last nonces seen and when they last changed
+	last    map[ethereum.Address][2]uint64
+	changed time.Time
+}
+
+// moved tells if any nonce changed since the last call
+func (self *ChainLease) moved() (bool, error) {
+	moved := false
+	for _, addr := range self.nonces.PricingAddresses() {
+		mined, pending, err := self.nonces.NoncesOf(addr)
+		if err != nil {
+			return false, err
+		}
+		if last, found := self.last[addr]; !found || last != [2]uint64{mined, pending} {
+			moved = true
+			self.last[addr] = [2]uint64{mined, pending}
+		}
+	}
+	return moved, nil
+}
+
+// takeoverDelay spreads the takeovers of instances between 0 and a
+// quarter of ttl
+func takeoverDelay(holder string, ttl time.Duration) time.Duration {
+	window := int64(ttl / 4)
+	if window <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(holder))
+	return time.Duration(h.Sum64() % uint64(window))
+}
+
+func (self *ChainLease) TryAcquire(holder string, ttl time.Duration) (bool, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	now := self.clock.Now()
+	if self.holder == holder {
+		if now.Before(self.expires) {
+			self.expires = now.Add(ttl)
+			return true, nil
+		}
+		self.logger.Warnf("The lease of %s expired at %s before it was renewed, standing by", holder, self.expires)
+		self.holder = ""
+		self.changed = time.Time{}
+	}
+	moved, err := self.moved()
+	if err != nil {
+		return false, err
+	}
+	if moved || self.changed.IsZero() {
+		self.changed = now
+		return false, nil
+	}
+	if now.Sub(self.changed) < ttl+takeoverDelay(holder, ttl) {
+		return false, nil
+	}
+	self.logger.Infof("Pricing operators have been idle since %s, taking over", self.changed)
+	self.holder = holder
+	self.expires = now.Add(ttl)
+	return true, nil
+}
+
+func (self *ChainLease) Release(holder string) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.holder == holder {
+		self.holder = ""
+		self.expires = time.Time{}
+		self.changed = time.Time{}
+	}
+	return nil
+}
+
+// SetLogger sets the logger of the lease, the standard logger by default
+func (self *ChainLease) SetLogger(logger *logging.Logger) {
+	self.logger = logger
+}
+
+func (self *ChainLease) String() string {
+	return "on-chain nonce activity"
+}
+
+func NewChainLease(nonces NonceSource, clock clock.Clock) *ChainLease {
+	return &ChainLease{
+		nonces: nonces,
+		clock:  clock,
+		logger: logging.Std(),
+		last:   map[ethereum.Address][2]uint64{},
+	}
+}
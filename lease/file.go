@@ -0,0 +1,106 @@
+package lease
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+)
+
+// fileRecord is the content of a lease file
+type fileRecord struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// FileLease keeps the lease in a json file on storage shared by the
+// instances, ie NFS. Updates are serialized with flock on a lock file
+// next to it. Expiries are wall clock times so the clocks of the hosts
+// must be synchronized well within the ttl.
+type FileLease struct {
+	path  string
+	clock clock.Clock
+}
+
+// locked runs f while holding an exclusive lock on the lease
+func (self *FileLease) locked(f func() error) error {
+	lock, err := os.OpenFile(self.path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+	return f()
+}
+
+func (self *FileLease) read() (fileRecord, error) {
+	record := fileRecord{}
+	data, err := ioutil.ReadFile(self.path)
+	if os.IsNotExist(err) {
+		return record, nil
+	}
+	if err != nil {
+		return record, err
+	}
+	if len(data) == 0 {
+		return record, nil
+	}
+	return record, json.Unmarshal(data, &record)
+}
+
+// write replaces the lease file atomically
+func (self *FileLease) write(record fileRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	tmp := self.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, self.path)
+}
+
+func (self *FileLease) TryAcquire(holder string, ttl time.Duration) (bool, error) {
+	acquired := false
+	err := self.locked(func() error {
+		record, err := self.read()
+		if err != nil {
+			return err
+		}
+		now := self.clock.Now()
+		if record.Holder != "" && record.Holder != holder && now.Before(record.Expires) {
+			return nil
+		}
+		if err := self.write(fileRecord{holder, now.Add(ttl)}); err != nil {
+			return err
+		}
+		acquired = true
+		return nil
+	})
+	return acquired, err
+}
+
+func (self *FileLease) Release(holder string) error {
+	return self.locked(func() error {
+		record, err := self.read()
+		if err != nil || record.Holder != holder {
+			return err
+		}
+		return self.write(fileRecord{})
+	})
+}
+
+func (self *FileLease) String() string {
+	return "file " + self.path
+}
+
+func NewFileLease(path string, clock clock.Clock) *FileLease {
+	return &FileLease{path, clock}
+}
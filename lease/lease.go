@@ -0,0 +1,162 @@
+// Package lease elects one leader among feeder instances so only one of
+// them feeds. Instances compete for a lease in a shared backend, the
+// holder renews it and the others take over once it expires.
+package lease
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+var isLeader = metrics.GetOrRegisterGauge("feeder.lease.leader", nil)
+
+// Backend stores the lease
+type Backend interface {
+	// TryAcquire takes the lease for ttl if it is free or expired, or
+	// renews it if holder already has it. It returns false if another
+	// holder has the lease.
+	TryAcquire(holder string, ttl time.Duration) (bool, error)
+	// Release frees the lease if holder has it
+	Release(holder string) error
+	String() string
+}
+
+// Elector keeps trying to get the lease and renews it while it is the
+// leader.
+// Elector is thread safe.
+type Elector struct {
+	backend Backend
+	holder  string
+	ttl     time.Duration
+	clock   clock.Clock
+	logger  *logging.Logger
+
+	mu     sync.Mutex
+	leader bool
+	// expires is when the lease got at the last attempt expires, counted
+	// from before the attempt
+	expires time.Time
+	// elected is notified when the elector becomes the leader
+	elected chan struct{}
+	quit    chan struct{}
+}
+
+// IsLeader tells if the elector had the lease at the last attempt and
+// it hasn't expired since, so a leader which stalled doesn't feed before
+// it renews the lease
+func (self *Elector) IsLeader() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.leader && self.clock.Now().Before(self.expires)
+}
+
+// SetLogger sets the logger of the elector, the standard logger by default
+func (self *Elector) SetLogger(logger *logging.Logger) {
+	self.logger = logger
+}
+
+// Elected is notified every time the elector becomes the leader
+func (self *Elector) Elected() <-chan struct{} {
+	return self.elected
+}
+
+// Try tries to get or renew the lease once. An error loses the
+// leadership as the lease might expire before it can be renewed.
+func (self *Elector) Try() bool {
+	start := self.clock.Now()
+	acquired, err := self.backend.TryAcquire(self.holder, self.ttl)
+	if err != nil {
+		self.logger.Warnf("Getting the lease from %s failed: %s", self.backend, err)
+		acquired = false
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if acquired && !self.leader {
+		self.logger.Infof("%s is the leader, lease from %s", self.holder, self.backend)
+		select {
+		case self.elected <- struct{}{}:
+		default:
+		}
+	} else if !acquired && self.leader {
+		self.logger.Warnf("%s lost the lease, standing by", self.holder)
+	}
+	self.leader = acquired
+	if acquired {
+		self.expires = start.Add(self.ttl)
+		isLeader.Update(1)
+	} else {
+		isLeader.Update(0)
+	}
+	return acquired
+}
+
+// Start tries to get the lease right away, then every third of the ttl
+// so the leader renews it well before it expires and a standby takes
+// over at most 4/3 ttl after the leader stopped renewing
+func (self *Elector) Start() {
+	self.Try()
+	go func() {
+		ticker := self.clock.NewTicker(self.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				self.Try()
+			case <-self.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops renewing the lease and releases it
+func (self *Elector) Stop() {
+	close(self.quit)
+	self.mu.Lock()
+	self.leader = false
+	self.mu.Unlock()
+	if err := self.backend.Release(self.holder); err != nil {
+		self.logger.Warnf("Releasing the lease failed: %s", err)
+	}
+}
+
+func NewElector(backend Backend, holder string, ttl time.Duration, clock clock.Clock) *Elector {
+	return &Elector{
+		backend: backend,
+		holder:  holder,
+		ttl:     ttl,
+		clock:   clock,
+		logger:  logging.Std(),
+		elected: make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+	}
+}
+
+// Parse returns the backend described by spec:
+//
+//	file:<path>               a lease file on shared storage
+//	redis://<host:port>/<key> a key in redis
+//
+// The on-chain backend needs the reserve, see NewChainLease.
+func Parse(spec string, clock clock.Clock) (Backend, error) {
+	switch {
+	case strings.HasPrefix(spec, "file:"):
+		return NewFileLease(strings.TrimPrefix(spec, "file:"), clock), nil
+	case strings.HasPrefix(spec, "redis://"):
+		rest := strings.TrimPrefix(spec, "redis://")
+		i := strings.Index(rest, "/")
+		if i < 0 || i == len(rest)-1 {
+			return nil, errors.New(fmt.Sprintf("missing key in %s, expected redis://<host:port>/<key>", spec))
+		}
+		return NewRedisLease(rest[:i], rest[i+1:]), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown lease backend %s", spec))
+	}
+}
@@ -0,0 +1,138 @@
+package lease_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/lease"
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+func assertLeader(t *testing.T, e *lease.Elector, name string, expected bool) {
+	if leader := e.Try(); leader != expected {
+		t.Fatalf("Expected %s to be leader: %t, got %t", name, expected, leader)
+	}
+}
+
+func TestFileLeaseFailover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lease")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	path := filepath.Join(dir, "feeder.lease")
+	a := lease.NewElector(lease.NewFileLease(path, clk), "a", 30*time.Second, clk)
+	b := lease.NewElector(lease.NewFileLease(path, clk), "b", 30*time.Second, clk)
+
+	assertLeader(t, a, "a", true)
+	assertLeader(t, b, "b", false)
+	clk.Advance(20 * time.Second)
+	assertLeader(t, a, "a", true)
+	clk.Advance(20 * time.Second)
+	// a renewed the lease 20s ago
+	assertLeader(t, b, "b", false)
+	// a stops renewing
+	clk.Advance(20 * time.Second)
+	assertLeader(t, b, "b", true)
+	select {
+	case <-b.Elected():
+	default:
+		t.Fatalf("Expected b to be notified it is elected")
+	}
+	assertLeader(t, a, "a", false)
+}
+
+func TestRedisLeaseFailover(t *testing.T) {
+	redis := simulation.NewRedis()
+	defer redis.Close()
+	backend, err := lease.Parse("redis://"+redis.Addr()+"/dgx-feeder", clock.NewRealClock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttl := 200 * time.Millisecond
+	a := lease.NewElector(backend, "a", ttl, clock.NewRealClock())
+	b := lease.NewElector(backend, "b", ttl, clock.NewRealClock())
+
+	assertLeader(t, a, "a", true)
+	assertLeader(t, b, "b", false)
+	time.Sleep(ttl + 50*time.Millisecond)
+	assertLeader(t, b, "b", true)
+	assertLeader(t, a, "a", false)
+
+	b.Stop()
+	assertLeader(t, a, "a", true)
+}
+
+type nonces struct {
+	mined, pending uint64
+}
+
+func (self *nonces) PricingAddresses() []ethereum.Address {
+	return []ethereum.Address{ethereum.HexToAddress("0x0000000000000000000000000000000000000001")}
+}
+
+func (self *nonces) NoncesOf(addr ethereum.Address) (uint64, uint64, error) {
+	return self.mined, self.pending, nil
+}
+
+func TestChainLeaseTakesOverWhenIdle(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	operator := &nonces{3, 3}
+	standby := lease.NewElector(lease.NewChainLease(operator, clk), "standby", 45*time.Minute, clk)
+
+	assertLeader(t, standby, "standby", false)
+	clk.Advance(30 * time.Minute)
+	// the leader feeds
+	operator.pending = 4
+	assertLeader(t, standby, "standby", false)
+	operator.mined = 4
+	clk.Advance(30 * time.Minute)
+	assertLeader(t, standby, "standby", false)
+	clk.Advance(30 * time.Minute)
+	assertLeader(t, standby, "standby", false)
+	clk.Advance(30 * time.Minute)
+	// idle for an hour, longer than the ttl
+	assertLeader(t, standby, "standby", true)
+}
+
+func TestStalledLeaderIsTakenOver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lease")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	path := filepath.Join(dir, "feeder.lease")
+	a := lease.NewElector(lease.NewFileLease(path, clk), "a", 30*time.Second, clk)
+	b := lease.NewElector(lease.NewFileLease(path, clk), "b", 30*time.Second, clk)
+
+	assertLeader(t, a, "a", true)
+	// a stalls past its lease without renewing it
+	clk.Advance(40 * time.Second)
+	if a.IsLeader() {
+		t.Fatalf("Expected a not to be the leader once its lease expired")
+	}
+	assertLeader(t, b, "b", true)
+	assertLeader(t, a, "a", false)
+
+	// the on-chain lease is lost the same way
+	operator := &nonces{3, 3}
+	chain := lease.NewChainLease(operator, clk)
+	c := lease.NewElector(chain, "c", 45*time.Minute, clk)
+	assertLeader(t, c, "c", false)
+	clk.Advance(time.Hour)
+	assertLeader(t, c, "c", true)
+	clk.Advance(time.Hour)
+	if c.IsLeader() {
+		t.Fatalf("Expected c not to be the leader once its lease expired")
+	}
+	// another instance fed in the meantime
+	operator.mined, operator.pending = 4, 4
+	assertLeader(t, c, "c", false)
+}
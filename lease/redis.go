@@ -0,0 +1,108 @@
+package lease
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	REDIS_TIMEOUT time.Duration = 3 * time.Second
+
+	// ACQUIRE_SCRIPT sets the key to the holder with the ttl if it is
+	// free or already held by the holder, atomically
+	ACQUIRE_SCRIPT string = `local v = redis.call('GET', KEYS[1])
+if v == false or v == ARGV[1] then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+  return 1
+end
+return 0`
+	// RELEASE_SCRIPT deletes the key if it is held by the holder
+	RELEASE_SCRIPT string = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0`
+)
+
+// RedisLease keeps the lease in a redis key which expires with the
+// lease, so expiry doesn't depend on the clocks of the instances
+type RedisLease struct {
+	addr string
+	key  string
+}
+
+// readReply reads one RESP reply, integers are returned as int64 and
+// bulk strings as string
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, errors.New("invalid redis reply")
+	}
+	payload := line[1 : len(line)-2]
+	switch line[0] {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, errors.New(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported redis reply %q", line))
+	}
+}
+
+// do sends one command on a new connection and returns its reply
+func (self *RedisLease) do(args ...string) (interface{}, error) {
+	conn, err := net.DialTimeout("tcp", self.addr, REDIS_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(REDIS_TIMEOUT))
+	cmd := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		cmd += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(cmd)); err != nil {
+		return nil, err
+	}
+	return readReply(bufio.NewReader(conn))
+}
+
+func (self *RedisLease) TryAcquire(holder string, ttl time.Duration) (bool, error) {
+	reply, err := self.do("EVAL", ACQUIRE_SCRIPT, "1", self.key, holder, strconv.FormatInt(int64(ttl/time.Millisecond), 10))
+	if err != nil {
+		return false, err
+	}
+	return reply == int64(1), nil
+}
+
+func (self *RedisLease) Release(holder string) error {
+	_, err := self.do("EVAL", RELEASE_SCRIPT, "1", self.key, holder)
+	return err
+}
+
+func (self *RedisLease) String() string {
+	return fmt.Sprintf("redis %s key %s", self.addr, self.key)
+}
+
+func NewRedisLease(addr string, key string) *RedisLease {
+	return &RedisLease{addr, key}
+}
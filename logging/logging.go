@@ -0,0 +1,257 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	DEBUG Level = iota
+	INFO
+	WARN
+	ERROR
+)
+
+const (
+	FORMAT_JSON   string = "json"
+	FORMAT_LOGFMT string = "logfmt"
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (self Level) String() string {
+	if self < DEBUG || self > ERROR {
+		return fmt.Sprintf("level(%d)", int(self))
+	}
+	return levelNames[self]
+}
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(str string) (Level, error) {
+	for i, name := range levelNames {
+		if strings.ToLower(str) == name {
+			return Level(i), nil
+		}
+	}
+	return INFO, errors.New(fmt.Sprintf("unsupported log level %s, expected debug, info, warn or error", str))
+}
+
+// sink is where all loggers derived from the same one write, lines are
+// written whole so concurrent loggers don't interleave
+type sink struct {
+	mu     sync.Mutex
+	out    io.Writer
+	level  Level
+	format string
+	// withTime is false when out adds the time itself, ie the standard
+	// logger
+	withTime bool
+}
+
+// Logger writes leveled lines with a message and key value fields, as
+// json objects or logfmt. Loggers returned by With share the output of
+// the logger they derive from.
+// Logger is thread safe.
+type Logger struct {
+	sink *sink
+	// fields are key value pairs added to every line
+	fields []interface{}
+}
+
+// With returns a logger adding the key value pairs kv to every line
+func (self *Logger) With(kv ...interface{}) *Logger {
+	if len(kv)%2 != 0 {
+		kv = append(kv, "")
+	}
+	fields := make([]interface{}, 0, len(self.fields)+len(kv))
+	fields = append(fields, self.fields...)
+	fields = append(fields, kv...)
+	return &Logger{self.sink, fields}
+}
+
+// Enabled tells if lines at level are written
+func (self *Logger) Enabled(level Level) bool {
+	return level >= self.sink.level
+}
+
+func (self *Logger) Debugf(format string, args ...interface{}) {
+	self.Log(DEBUG, fmt.Sprintf(format, args...))
+}
+
+func (self *Logger) Infof(format string, args ...interface{}) {
+	self.Log(INFO, fmt.Sprintf(format, args...))
+}
+
+func (self *Logger) Warnf(format string, args ...interface{}) {
+	self.Log(WARN, fmt.Sprintf(format, args...))
+}
+
+func (self *Logger) Errorf(format string, args ...interface{}) {
+	self.Log(ERROR, fmt.Sprintf(format, args...))
+}
+
+// Log writes msg at level with the fields of the logger
+func (self *Logger) Log(level Level, msg string) {
+	if !self.Enabled(level) {
+		return
+	}
+	kv := []interface{}{}
+	if self.sink.withTime {
+		kv = append(kv, "time", time.Now().UTC().Format("2006-01-02T15:04:05.000000Z07:00"))
+	}
+	kv = append(kv, "level", level.String(), "msg", msg)
+	kv = append(kv, self.fields...)
+	var line []byte
+	if self.sink.format == FORMAT_JSON {
+		line = formatJSON(kv)
+	} else {
+		line = formatLogfmt(kv)
+	}
+	self.sink.mu.Lock()
+	defer self.sink.mu.Unlock()
+	self.sink.out.Write(line)
+}
+
+func valueString(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	}
+	return fmt.Sprint(v)
+}
+
+func formatLogfmt(kv []interface{}) []byte {
+	buf := &bytes.Buffer{}
+	for i := 0; i < len(kv); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(valueString(kv[i]))
+		buf.WriteByte('=')
+		value := valueString(kv[i+1])
+		if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+			value = fmt.Sprintf("%q", value)
+		}
+		buf.WriteString(value)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func formatJSON(kv []interface{}) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i := 0; i < len(kv); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(valueString(kv[i]))
+		buf.Write(key)
+		buf.WriteByte(':')
+		var value []byte
+		var err error
+		switch v := kv[i+1].(type) {
+		case error:
+			value, err = json.Marshal(v.Error())
+		default:
+			value, err = json.Marshal(v)
+		}
+		if err != nil {
+			value, _ = json.Marshal(valueString(kv[i+1]))
+		}
+		buf.Write(value)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+// Writer returns a writer logging every line written to it at level, it
+// is used to route the standard logger to the structured one
+func (self *Logger) Writer(level Level) io.Writer {
+	return levelWriter{self, level}
+}
+
+type levelWriter struct {
+	logger *Logger
+	level  Level
+}
+
+func (self levelWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		self.logger.Log(self.level, line)
+	}
+	return len(p), nil
+}
+
+// New returns a logger writing lines at level and above to out in format,
+// json or logfmt
+func New(out io.Writer, level Level, format string) *Logger {
+	switch format {
+	case FORMAT_JSON, FORMAT_LOGFMT:
+	default:
+		panic(fmt.Sprintf("unsupported log format: %s", format))
+	}
+	return &Logger{&sink{out: out, level: level, format: format, withTime: true}, nil}
+}
+
+type stdWriter struct{}
+
+func (self stdWriter) Write(p []byte) (int, error) {
+	log.Print(string(p))
+	return len(p), nil
+}
+
+var (
+	stdMu sync.Mutex
+	std   *Logger
+)
+
+// SetStd makes Std return logger, it is called once the logger of the
+// process is configured
+func SetStd(logger *Logger) {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	std = logger
+}
+
+// Std returns the logger given to SetStd, or a logger writing logfmt lines
+// at info level and above to the standard logger until it is called. It
+// is the default of components which are not given a logger.
+func Std() *Logger {
+	stdMu.Lock()
+	defer stdMu.Unlock()
+	if std != nil {
+		return std
+	}
+	return &Logger{&sink{out: stdWriter{}, level: INFO, format: FORMAT_LOGFMT}, nil}
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying logger, ie the logger of a
+// feed cycle which components log through while they work for it
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger ctx carries, fallback if it carries none
+func FromContext(ctx context.Context, fallback *Logger) *Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return logger
+	}
+	return fallback
+}
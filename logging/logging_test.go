@@ -0,0 +1,74 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+)
+
+func TestLogfmt(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := logging.New(out, logging.INFO, logging.FORMAT_LOGFMT).With("cycle", "abc")
+	logger.Debugf("hidden")
+	logger.With("nonce", big.NewInt(7), "err", errors.New("not found")).Warnf("Tx %s is lost", "0x01")
+
+	line := out.String()
+	if strings.Count(line, "\n") != 1 {
+		t.Fatalf("Expected debug lines to be filtered out, got %q", line)
+	}
+	for _, field := range []string{"level=warn", `msg="Tx 0x01 is lost"`, "cycle=abc", "nonce=7", `err="not found"`} {
+		if !strings.Contains(line, field) {
+			t.Fatalf("Expected %s in %q", field, line)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := logging.New(out, logging.DEBUG, logging.FORMAT_JSON).With("cycle", "abc")
+	logger.Writer(logging.INFO).Write([]byte("first\nsecond\n"))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a line per line written, got %q", out.String())
+	}
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("Expected a json line, got %q: %s", lines[1], err)
+	}
+	if entry["level"] != "info" || entry["msg"] != "second" || entry["cycle"] != "abc" || entry["time"] == nil {
+		t.Fatalf("Expected time, level, msg and cycle, got %v", entry)
+	}
+}
+
+func TestStdIsTheConfiguredLogger(t *testing.T) {
+	out := &bytes.Buffer{}
+	logging.SetStd(logging.New(out, logging.INFO, logging.FORMAT_JSON))
+	defer logging.SetStd(nil)
+	logging.Std().Warnf("once")
+
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a single json line, got %q: %s", out.String(), err)
+	}
+	if entry["level"] != "warn" || entry["msg"] != "once" {
+		t.Fatalf("Expected the line to keep its level, got %v", entry)
+	}
+}
+
+func TestContextLogger(t *testing.T) {
+	fallback := logging.New(&bytes.Buffer{}, logging.INFO, logging.FORMAT_JSON)
+	if logging.FromContext(context.Background(), fallback) != fallback {
+		t.Fatalf("Expected the fallback without a logger in the context")
+	}
+	cycle := fallback.With("cycle", "abc")
+	if logging.FromContext(logging.NewContext(context.Background(), cycle), fallback) != cycle {
+		t.Fatalf("Expected the logger of the context")
+	}
+}
@@ -0,0 +1,189 @@
+package dgxpricing
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+)
+
+func TestCycleLinesCarryCycleID(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	out := &bytes.Buffer{}
+	h.feeder.SetLogger(logging.New(out, logging.DEBUG, logging.FORMAT_JSON))
+	// the first tx is only mined once it is replaced
+	h.backend.Chain.SetMinGasPrice(big.NewInt(10 * GWEI))
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		if _, status, err := wait(t, h.feeder.FeedOnce); err != nil || status != "mined" {
+			t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+		}
+	}
+
+	// cycle IDs of the lines about the fetch, the txs and the replacement
+	cycles := map[string][]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected json lines, got %q: %s", line, err)
+		}
+		msg, _ := entry["msg"].(string)
+		for _, prefix := range []string{"Fetched feed", "Broadcasted tx", "Replacing old tx", "Tx status"} {
+			if strings.HasPrefix(msg, prefix) {
+				cycle, _ := entry["cycle"].(string)
+				cycles[prefix] = append(cycles[prefix], cycle)
+			}
+		}
+	}
+	fetches := cycles["Fetched feed"]
+	if len(fetches) != 2 || fetches[0] == "" || fetches[1] == "" || fetches[0] == fetches[1] {
+		t.Fatalf("Expected every cycle to fetch a feed with its own ID, got %v", fetches)
+	}
+	for prefix, ids := range cycles {
+		for _, id := range ids {
+			if id != fetches[0] && id != fetches[1] {
+				t.Fatalf("Expected %s lines to carry the ID of their cycle, got %q", prefix, id)
+			}
+		}
+	}
+	if len(cycles["Replacing old tx"]) == 0 || len(cycles["Tx status"]) == 0 {
+		t.Fatalf("Expected replacement and status lines, got %v", cycles)
+	}
+}
+
+func TestOperatorSelectionAndCancelLinesCarryCycleID(t *testing.T) {
+	h := newOperatorsHarness(t, 2, OPERATOR_POLICY_NOT_STUCK)
+	defer h.Close()
+	out := &bytes.Buffer{}
+	h.feeder.SetLogger(logging.New(out, logging.DEBUG, logging.FORMAT_JSON))
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	if _, status, err := wait(t, h.feeder.FeedOnce); err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if _, err := h.feeder.Cancel(big.NewInt(1)); err != nil {
+		t.Fatalf("Expected to cancel but got error: %v", err)
+	}
+
+	found := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected json lines, got %q: %s", line, err)
+		}
+		msg, _ := entry["msg"].(string)
+		for _, prefix := range []string{"Feeding from pricing operator", "Cancelling nonce"} {
+			if strings.HasPrefix(msg, prefix) {
+				found[prefix] = true
+				if cycle, _ := entry["cycle"].(string); cycle == "" {
+					t.Fatalf("Expected %q to carry the ID of its cycle", msg)
+				}
+			}
+		}
+	}
+	if len(found) != 2 {
+		t.Fatalf("Expected lines about the operator selection and the cancel, got %v", found)
+	}
+}
+
+func TestLinesOutsideCyclesCarryNoCycleID(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	out := &bytes.Buffer{}
+	h.feeder.SetLogger(logging.New(&lockedWriter{w: out}, logging.DEBUG, logging.FORMAT_JSON))
+	// txs are only mined once they are replaced
+	h.backend.Chain.SetMinGasPrice(big.NewInt(10 * GWEI))
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	// a tx monitored for the admin api while a cycle runs
+	tx, err := h.feeder.TryFeedingPrice()
+	if err != nil {
+		t.Fatalf("Expected to send the feed but got error: %v", err)
+	}
+	monitored := make(chan cycleResult, 1)
+	go func() {
+		final, status, err := h.feeder.MonitorAndRetry(tx)
+		monitored <- cycleResult{final, status, err}
+	}()
+	if _, status, err := wait(t, h.feeder.FeedOnce); err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if r := <-monitored; r.err != nil || r.status != "mined" {
+		t.Fatalf("Expected the monitored tx to be mined, got status %s, err %v", r.status, r.err)
+	}
+
+	lines := 0
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected json lines, got %q: %s", line, err)
+		}
+		msg, _ := entry["msg"].(string)
+		if strings.Contains(msg, "old tx "+tx.Hash().Hex()) || msg == "Monitoring tx "+tx.Hash().Hex() {
+			lines++
+			if cycle, found := entry["cycle"]; found {
+				t.Fatalf("Expected %q not to carry the ID of cycle %v", msg, cycle)
+			}
+		}
+	}
+	if lines < 2 {
+		t.Fatalf("Expected lines about monitoring and replacing tx %s, got %d", tx.Hash().Hex(), lines)
+	}
+}
+
+// messageWriter sends the message of every json line written to it
+type messageWriter chan string
+
+func (self messageWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimSpace(string(p)), "\n") {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err == nil {
+			msg, _ := entry["msg"].(string)
+			self <- msg
+		}
+	}
+	return len(p), nil
+}
+
+// logLines makes the feeder log to the returned channel, one message per
+// line, so tests can wait for what the feeder does without a clock
+func (self *harness) logLines() <-chan string {
+	lines := make(chan string, 1000)
+	self.feeder.SetLogger(logging.New(messageWriter(lines), logging.DEBUG, logging.FORMAT_JSON))
+	return lines
+}
+
+// waitForLog fails the test unless a message containing substr is logged
+// in time
+func waitForLog(t *testing.T, lines <-chan string, substr string) {
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case msg := <-lines:
+			if strings.Contains(msg, substr) {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("Expected a line containing %q to be logged", substr)
+		}
+	}
+}
+
+// lockedWriter lets tests read what concurrent loggers wrote
+type lockedWriter struct {
+	mu sync.Mutex
+	w  *bytes.Buffer
+}
+
+func (self *lockedWriter) Write(p []byte) (int, error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.w.Write(p)
+}
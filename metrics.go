@@ -0,0 +1,24 @@
+package dgxpricing
+
+import (
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// metrics are registered in the default registry which is exposed by the api
+var (
+	feedsSubmitted    = metrics.GetOrRegisterCounter("feeder.feeds.submitted", nil)
+	feedsFailed       = metrics.GetOrRegisterCounter("feeder.feeds.failed", nil)
+	feedsGaveUp       = metrics.GetOrRegisterCounter("feeder.feeds.gave_up", nil)
+	txsMined          = metrics.GetOrRegisterCounter("feeder.txs.mined", nil)
+	txsReverted       = metrics.GetOrRegisterCounter("feeder.txs.failed", nil)
+	txsReplaced       = metrics.GetOrRegisterCounter("feeder.txs.replacements", nil)
+	txsSuperseded     = metrics.GetOrRegisterCounter("feeder.txs.superseded", nil)
+	ticksMissed       = metrics.GetOrRegisterCounter("feeder.ticks.missed", nil)
+	shadowFeeds       = metrics.GetOrRegisterCounter("feeder.shadow.feeds", nil)
+	shadowRejected    = metrics.GetOrRegisterCounter("feeder.shadow.rejected", nil)
+	shadowDivergences = metrics.GetOrRegisterCounter("feeder.shadow.divergences", nil)
+	lastGasPrice      = metrics.GetOrRegisterGauge("feeder.txs.last_gas_price_gwei", nil)
+	budgetExceeded    = metrics.GetOrRegisterCounter("feeder.budget.exceeded", nil)
+	budgetEmergencies = metrics.GetOrRegisterCounter("feeder.budget.emergencies", nil)
+	budgetSpent       = metrics.GetOrRegisterGauge("feeder.budget.spent_gwei", nil)
+)
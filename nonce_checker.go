@@ -0,0 +1,214 @@
+package dgxpricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// what to do with pending txs of the pricing operator that are
+	// not in the journal, and with nonce gaps in front of queued txs
+	STUCK_POLICY_REPORT string = "report" // only log them
+	STUCK_POLICY_CANCEL string = "cancel" // replace them with 0 ETH self transfers
+	STUCK_POLICY_REFEED string = "refeed" // replace the lowest one with a fresh setPriceFeed, cancel the rest
+)
+
+type NonceReport struct {
+	MinedNonce   uint64
+	PendingNonce uint64
+	// Unknown are txs in the node's pool which are not in the journal
+	Unknown []*types.Transaction
+	// Stale are journaled txs in the node's pool which have been waiting
+	// longer than the threshold given to Check
+	Stale []*types.Transaction
+	// Gaps are nonces which have no tx while a higher nonce is queued
+	Gaps []uint64
+}
+
+func (self NonceReport) Stuck() bool {
+	return len(self.Unknown) > 0 || len(self.Stale) > 0 || len(self.Gaps) > 0
+}
+
+// StuckNonces returns sorted nonces which need to be replaced
+func (self NonceReport) StuckNonces() []uint64 {
+	set := map[uint64]bool{}
+	for _, tx := range self.Unknown {
+		set[tx.Nonce()] = true
+	}
+	for _, tx := range self.Stale {
+		set[tx.Nonce()] = true
+	}
+	for _, n := range self.Gaps {
+		set[n] = true
+	}
+	result := []uint64{}
+	for n := range set {
+		result = append(result, n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// NonceChecker compares the pricing operator's mined nonce with its
+// pending nonce to find txs which would block the next price feeds
+type NonceChecker struct {
+	reserve Reserve
+	prices  PriceCorpus
+	journal TxJournal
+	policy  string
+	// clock must be the one the journal times txs with
+	clock clock.Clock
+	// logger is the one of checks which are not part of a feed cycle
+	logger *logging.Logger
+}
+
+func findGaps(from uint64, queued []*types.Transaction) []uint64 {
+	nonces := []uint64{}
+	for _, tx := range queued {
+		nonces = append(nonces, tx.Nonce())
+	}
+	sort.Slice(nonces, func(i, j int) bool { return nonces[i] < nonces[j] })
+	gaps := []uint64{}
+	expected := from
+	for _, n := range nonces {
+		for ; expected < n; expected++ {
+			gaps = append(gaps, expected)
+		}
+		if n >= expected {
+			expected = n + 1
+		}
+	}
+	return gaps
+}
+
+// Check reports the pricing operator's txs which block its next txs.
+// Journaled txs are reported once every pool tx at their nonce has been
+// sent longer than stuckAfter ago. ctx carries the logger of the cycle.
+func (self *NonceChecker) Check(ctx context.Context, stuckAfter time.Duration) (*NonceReport, error) {
+	mined, err := self.reserve.MinedNonce()
+	if err != nil {
+		return nil, err
+	}
+	pendingNonce, err := self.reserve.PendingNonce()
+	if err != nil {
+		return nil, err
+	}
+	report := &NonceReport{
+		MinedNonce:   mined,
+		PendingNonce: pendingNonce,
+	}
+	pending, queued, err := self.reserve.PendingTxs()
+	if err != nil {
+		// not every node exposes its tx pool, we still have the nonces
+		logging.FromContext(ctx, self.logger).Warnf("Couldn't get pending txs of the pricing operator: %s", err)
+		return report, nil
+	}
+	known := []*types.Transaction{}
+	// time the latest journaled tx at each nonce was sent
+	latest := map[uint64]uint64{}
+	for _, tx := range append(pending, queued...) {
+		sentAt, found := self.journal.SentAt(tx.Hash())
+		if !found {
+			report.Unknown = append(report.Unknown, tx)
+			continue
+		}
+		known = append(known, tx)
+		if sentAt > latest[tx.Nonce()] {
+			latest[tx.Nonce()] = sentAt
+		}
+	}
+	now := uint64(self.clock.Now().UnixNano() / int64(time.Millisecond))
+	threshold := uint64(stuckAfter / time.Millisecond)
+	for _, tx := range known {
+		if tx.Nonce() >= mined && latest[tx.Nonce()]+threshold < now {
+			report.Stale = append(report.Stale, tx)
+		}
+	}
+	report.Gaps = findGaps(pendingNonce, queued)
+	return report, nil
+}
+
+func (self *NonceChecker) replacementGasPrice(report *NonceReport, txNonce uint64) *big.Int {
+	gasPrice := big.NewInt(INIT_GASPRICE)
+	for _, tx := range append(report.Unknown, report.Stale...) {
+		if tx.Nonce() == txNonce {
+			bumped := bumpGasPrice(tx.GasPrice())
+			if bumped.Cmp(gasPrice) > 0 {
+				gasPrice = bumped
+			}
+		}
+	}
+	return gasPrice
+}
+
+func (self *NonceChecker) refeed(ctx context.Context, txNonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+	blockno, nonce, ask, bid, v, r, s, err := self.prices.GetFeed(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return self.reserve.SetPriceFeedAt(ctx, big.NewInt(int64(txNonce)), gasPrice, blockno, nonce, ask, bid, v, r, s)
+}
+
+// Resolve replaces the stuck nonces in report according to the policy,
+// every replacement is checked with checkBudget before it is sent. When
+// refeed is false stuck nonces are only cancelled.
+// It returns the replacement txs which were broadcasted, in nonce order.
+func (self *NonceChecker) Resolve(ctx context.Context, report *NonceReport, refeed bool, checkBudget func(gasPrice *big.Int, gas uint64) error) ([]*types.Transaction, error) {
+	logger := logging.FromContext(ctx, self.logger)
+	result := []*types.Transaction{}
+	if self.policy == STUCK_POLICY_REPORT {
+		return result, nil
+	}
+	for i, txNonce := range report.StuckNonces() {
+		gasPrice := self.replacementGasPrice(report, txNonce)
+		var tx *types.Transaction
+		var err error
+		kind := "cancel"
+		gas := CANCEL_GAS_ESTIMATE
+		if i == 0 && refeed && self.policy == STUCK_POLICY_REFEED {
+			kind = "replacement"
+			gas = FEED_GAS_ESTIMATE
+		}
+		if err := checkBudget(gasPrice, gas); err != nil {
+			return result, errors.New(fmt.Sprintf("Replacing stuck nonce %d abandoned: %s", txNonce, err))
+		}
+		if kind == "replacement" {
+			tx, err = self.refeed(ctx, txNonce, gasPrice)
+		} else {
+			tx, err = self.reserve.SelfTransfer(ctx, big.NewInt(int64(txNonce)), gasPrice)
+		}
+		if err != nil {
+			return result, errors.New(fmt.Sprintf("Replacing stuck nonce %d failed: %s", txNonce, err))
+		}
+		logger.Infof("Replaced stuck nonce %d with %s tx %s, gas price: %s", txNonce, kind, tx.Hash().Hex(), gasPrice)
+		if err := self.journal.Record(kind, tx); err != nil {
+			logger.Warnf("Journaling tx %s failed: %s", tx.Hash().Hex(), err)
+		}
+		result = append(result, tx)
+	}
+	return result, nil
+}
+
+func NewNonceChecker(reserve Reserve, prices PriceCorpus, journal TxJournal, policy string, clock clock.Clock) *NonceChecker {
+	switch policy {
+	case STUCK_POLICY_REPORT, STUCK_POLICY_CANCEL, STUCK_POLICY_REFEED:
+	default:
+		panic(fmt.Sprintf("unsupported stuck tx policy: %s", policy))
+	}
+	return &NonceChecker{
+		reserve: reserve,
+		prices:  prices,
+		journal: journal,
+		policy:  policy,
+		clock:   clock,
+		logger:  logging.Std(),
+	}
+}
@@ -0,0 +1,201 @@
+package dgxpricing
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/alert"
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/ethereum/go-ethereum/common"
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+const (
+	OPERATOR_CHECK_INTERVAL time.Duration = 5 * time.Minute
+	// MAX_EVENT_BLOCKS bounds the block range of one eth_getLogs call,
+	// nodes refuse too large ranges
+	MAX_EVENT_BLOCKS uint64 = 5000
+)
+
+var operatorAuthorized = metrics.GetOrRegisterGauge("feeder.operator.authorized", nil)
+
+// OperatorGuard checks the pricing operators are operators of the
+// reserve, setPriceFeed reverts otherwise, and reports every change of
+// the reserve's operator set.
+// OperatorGuard is thread safe.
+type OperatorGuard struct {
+	reserve OperatorRegistry
+	alerter alert.Alerter
+	clock   clock.Clock
+	logger  *logging.Logger
+
+	mu sync.Mutex
+	// authorized tells if each pricing operator was an operator of the
+	// reserve at the last check
+	authorized map[common.Address]bool
+	// last block whose operator events were reported
+	lastBlock uint64
+}
+
+// OperatorError is returned when pricing operators are not operators of
+// the reserve, their setPriceFeed txs revert until they are added back
+type OperatorError struct {
+	Operators []common.Address
+}
+
+func (self *OperatorError) Error() string {
+	addrs := []string{}
+	for _, addr := range self.Operators {
+		addrs = append(addrs, addr.Hex())
+	}
+	return fmt.Sprintf("pricing operators %s are not operators of the reserve", strings.Join(addrs, ", "))
+}
+
+// IsOperatorError tells if err is an *OperatorError
+func IsOperatorError(err error) bool {
+	_, ok := err.(*OperatorError)
+	return ok
+}
+
+// IsOperator tells if addr is in operators
+func IsOperator(operators []common.Address, addr common.Address) bool {
+	for _, op := range operators {
+		if op == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an *OperatorError if pricing operators are not operators of
+// the reserve at the latest block. It alerts when a pricing operator
+// loses or gets back its role.
+func (self *OperatorGuard) Check() error {
+	operators, err := self.reserve.GetOperators(0)
+	if err != nil {
+		self.logger.Warnf("Getting operators of the reserve failed: %s", err)
+		return err
+	}
+	unauthorized := []common.Address{}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, addr := range self.reserve.PricingAddresses() {
+		authorized := IsOperator(operators, addr)
+		was, checked := self.authorized[addr]
+		self.authorized[addr] = authorized
+		if authorized {
+			if checked && !was {
+				self.alerter.Alert(alert.INFO, fmt.Sprintf("pricing operator %s is an operator of the reserve again", addr.Hex()))
+			}
+			continue
+		}
+		unauthorized = append(unauthorized, addr)
+		if !checked || was {
+			self.alerter.Alert(alert.CRITICAL, fmt.Sprintf(
+				"pricing operator %s is not an operator of the reserve, its setPriceFeed txs will revert", addr.Hex(),
+			))
+		}
+	}
+	if len(unauthorized) == 0 {
+		operatorAuthorized.Update(1)
+		return nil
+	}
+	operatorAuthorized.Update(0)
+	return &OperatorError{unauthorized}
+}
+
+// Authorized tells if every pricing operator was an operator of the
+// reserve at the last check
+func (self *OperatorGuard) Authorized() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, authorized := range self.authorized {
+		if !authorized {
+			return false
+		}
+	}
+	return len(self.authorized) > 0
+}
+
+// WatchEvents reports OperatorAdded events mined since the last call.
+// The first call only records the latest block.
+func (self *OperatorGuard) WatchEvents() error {
+	latest, err := self.reserve.LatestBlock()
+	if err != nil {
+		return err
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.lastBlock == 0 {
+		self.lastBlock = latest
+		return nil
+	}
+	for self.lastBlock < latest {
+		toBlock := latest
+		if toBlock-self.lastBlock > MAX_EVENT_BLOCKS {
+			toBlock = self.lastBlock + MAX_EVENT_BLOCKS
+		}
+		events, err := self.reserve.OperatorEvents(self.lastBlock+1, toBlock)
+		if err != nil {
+			self.logger.Warnf("Getting operator events failed: %s", err)
+			return err
+		}
+		ours := self.reserve.PricingAddresses()
+		for _, e := range events {
+			action, level := "added to", alert.WARNING
+			if !e.Added {
+				action = "removed from"
+			}
+			if IsOperator(ours, e.Operator) {
+				level = alert.INFO
+				if !e.Added {
+					level = alert.CRITICAL
+				}
+			}
+			self.alerter.Alert(level, fmt.Sprintf(
+				"operator %s was %s the reserve at block %d (tx %s)",
+				e.Operator.Hex(), action, e.Block, e.TxHash.Hex(),
+			))
+		}
+		self.lastBlock = toBlock
+	}
+	return nil
+}
+
+// Start checks the pricing operator and reports operator events every
+// interval in background
+func (self *OperatorGuard) Start(interval time.Duration) {
+	self.WatchEvents()
+	go func() {
+		ticker := self.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C() {
+			self.Check()
+			self.WatchEvents()
+		}
+	}()
+}
+
+// SetLogger sets the logger of the guard, the standard logger by default
+func (self *OperatorGuard) SetLogger(logger *logging.Logger) {
+	self.logger = logger
+}
+
+// SetOperatorGuard makes the feeder give up at once when its feeds fail
+// because the pricing operator is not an operator of the reserve
+func (self *PriceFeeder) SetOperatorGuard(guard *OperatorGuard) {
+	self.guard = guard
+}
+
+func NewOperatorGuard(reserve OperatorRegistry, alerter alert.Alerter, clock clock.Clock) *OperatorGuard {
+	return &OperatorGuard{
+		reserve:    reserve,
+		alerter:    alerter,
+		clock:      clock,
+		logger:     logging.Std(),
+		authorized: map[common.Address]bool{},
+	}
+}
@@ -0,0 +1,107 @@
+package dgxpricing
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/KyberNetwork/dgx-price-feeder/alert"
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	ethereum "github.com/ethereum/go-ethereum/common"
+)
+
+type recordedAlert struct {
+	level   string
+	message string
+}
+
+// recordingAlerter keeps alerts so tests can check them
+type recordingAlerter struct {
+	mu     sync.Mutex
+	alerts []recordedAlert
+}
+
+func (self *recordingAlerter) Alert(level string, message string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.alerts = append(self.alerts, recordedAlert{level, message})
+}
+
+func (self *recordingAlerter) take() []recordedAlert {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	alerts := self.alerts
+	self.alerts = nil
+	return alerts
+}
+
+func TestOperatorGuardAlertsWhenKeyIsRemoved(t *testing.T) {
+	backend := simulation.NewBackend()
+	defer backend.Close()
+	alerter := &recordingAlerter{}
+	guard := NewOperatorGuard(backend.Reserve, alerter, clock.NewRealClock())
+
+	if err := guard.Check(); err != nil {
+		t.Fatalf("Expected the pricing operator to be authorised but got error: %v", err)
+	}
+	if err := guard.WatchEvents(); err != nil {
+		t.Fatal(err)
+	}
+
+	backend.Chain.WithReserve(func(reserve *simulation.Reserve) { reserve.RemoveOperator(backend.Operator()) })
+	backend.Chain.Mine()
+
+	if err := guard.Check(); err == nil || guard.Authorized() {
+		t.Fatalf("Expected the check to fail once the pricing operator is removed")
+	}
+	// the removal is only alerted once
+	guard.Check()
+	alerts := alerter.take()
+	if len(alerts) != 1 || alerts[0].level != alert.CRITICAL {
+		t.Fatalf("Expected one critical alert, got %v", alerts)
+	}
+
+	if err := guard.WatchEvents(); err != nil {
+		t.Fatal(err)
+	}
+	alerts = alerter.take()
+	if len(alerts) != 1 || alerts[0].level != alert.CRITICAL || !strings.Contains(alerts[0].message, "removed") {
+		t.Fatalf("Expected the removal event to be reported, got %v", alerts)
+	}
+
+	backend.Chain.WithReserve(func(reserve *simulation.Reserve) { reserve.AddOperator(backend.Operator()) })
+	backend.Chain.Mine()
+	if err := guard.Check(); err != nil {
+		t.Fatalf("Expected the pricing operator to be authorised again but got error: %v", err)
+	}
+	if alerts := alerter.take(); len(alerts) != 1 || alerts[0].level != alert.INFO {
+		t.Fatalf("Expected the pricing operator getting back its role to be reported, got %v", alerts)
+	}
+}
+
+func TestOperatorGuardReportsOperatorSetChanges(t *testing.T) {
+	backend := simulation.NewBackend()
+	defer backend.Close()
+	alerter := &recordingAlerter{}
+	guard := NewOperatorGuard(backend.Reserve, alerter, clock.NewRealClock())
+	guard.WatchEvents()
+
+	other := ethereum.HexToAddress("0x0000000000000000000000000000000000000042")
+	backend.Chain.WithReserve(func(reserve *simulation.Reserve) { reserve.AddOperator(other) })
+	backend.Chain.Mine()
+	backend.Chain.Mine()
+
+	if err := guard.WatchEvents(); err != nil {
+		t.Fatal(err)
+	}
+	alerts := alerter.take()
+	if len(alerts) != 1 || !strings.Contains(alerts[0].message, other.Hex()+" was added") {
+		t.Fatalf("Expected the new operator to be reported, got %v", alerts)
+	}
+	// events are reported once
+	guard.WatchEvents()
+	if alerts := alerter.take(); len(alerts) != 0 {
+		t.Fatalf("Expected no new report, got %v", alerts)
+	}
+}
@@ -0,0 +1,199 @@
+package dgxpricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// how the pricing operator of each feed is chosen among the ones
+	// which are not stuck
+	OPERATOR_POLICY_NOT_STUCK   string = "not-stuck"   // the primary, or the next one if it is stuck
+	OPERATOR_POLICY_ROUND_ROBIN string = "round-robin" // each operator in turn
+	OPERATOR_POLICY_BALANCE     string = "balance"     // the one with the highest balance
+)
+
+// OperatorPool is a reserve with several pricing operators
+type OperatorPool interface {
+	// PricingAddresses returns all pricing operators, the primary first
+	PricingAddresses() []common.Address
+	// UseOperator makes addr the pricing operator txs are sent from
+	UseOperator(addr common.Address) error
+	NoncesOf(addr common.Address) (mined uint64, pending uint64, err error)
+	BalanceOf(addr common.Address) (*big.Int, error)
+}
+
+// OperatorStatus is a snapshot of one pricing operator
+type OperatorStatus struct {
+	Address      common.Address `json:"address"`
+	Primary      bool           `json:"primary"`
+	Active       bool           `json:"active"`
+	MinedNonce   uint64         `json:"mined_nonce"`
+	PendingNonce uint64         `json:"pending_nonce"`
+	Balance      *big.Int       `json:"balance"`
+	// Stuck is true when the operator has txs which are not mined,
+	// a new feed from it would wait for them
+	Stuck bool   `json:"stuck"`
+	Error string `json:"error,omitempty"`
+}
+
+// usable tells if the operator can send the next feed right away
+func (self OperatorStatus) usable() bool {
+	return self.Error == "" && !self.Stuck && self.Balance.Sign() > 0
+}
+
+// OperatorSelector chooses the pricing operator of the next feed
+type OperatorSelector struct {
+	pool    OperatorPool
+	journal TxJournal
+	policy  string
+
+	mu     sync.Mutex
+	active common.Address
+	// next is the index of the next operator for round robin
+	next int
+}
+
+func (self *OperatorSelector) status(addr common.Address) OperatorStatus {
+	status := OperatorStatus{Address: addr, Balance: big.NewInt(0)}
+	mined, pending, err := self.pool.NoncesOf(addr)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.MinedNonce, status.PendingNonce = mined, pending
+	status.Stuck = pending > mined
+	for _, n := range self.journal.PendingNonces(addr) {
+		if n >= mined {
+			status.Stuck = true
+		}
+	}
+	balance, err := self.pool.BalanceOf(addr)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	status.Balance = balance
+	return status
+}
+
+// Statuses returns the status of every pricing operator, the primary first
+func (self *OperatorSelector) Statuses() []OperatorStatus {
+	self.mu.Lock()
+	active := self.active
+	self.mu.Unlock()
+	result := []OperatorStatus{}
+	for i, addr := range self.pool.PricingAddresses() {
+		status := self.status(addr)
+		status.Primary = i == 0
+		status.Active = addr == active
+		result = append(result, status)
+	}
+	return result
+}
+
+// choose returns the index of the operator to use among statuses
+func (self *OperatorSelector) choose(statuses []OperatorStatus) (int, error) {
+	switch self.policy {
+	case OPERATOR_POLICY_NOT_STUCK:
+		for i, s := range statuses {
+			if s.usable() {
+				return i, nil
+			}
+		}
+	case OPERATOR_POLICY_ROUND_ROBIN:
+		for k := 0; k < len(statuses); k++ {
+			i := (self.next + k) % len(statuses)
+			if statuses[i].usable() {
+				self.next = i + 1
+				return i, nil
+			}
+		}
+	case OPERATOR_POLICY_BALANCE:
+		best := -1
+		for i, s := range statuses {
+			if s.usable() && (best < 0 || s.Balance.Cmp(statuses[best].Balance) > 0) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			return best, nil
+		}
+	}
+	return 0, errors.New("every pricing operator is stuck or can't pay for a feed")
+}
+
+// Select makes the operator chosen by the policy the active one and
+// returns it. If none can feed right away the primary is used. It logs
+// through the logger of the cycle ctx belongs to.
+func (self *OperatorSelector) Select(ctx context.Context) common.Address {
+	logger := logging.FromContext(ctx, logging.Std())
+	statuses := self.Statuses()
+	for _, s := range statuses {
+		if s.Stuck {
+			logger.Warnf("Pricing operator %s is stuck: mined nonce(%d), pending nonce(%d)", s.Address.Hex(), s.MinedNonce, s.PendingNonce)
+		}
+	}
+	self.mu.Lock()
+	i, err := self.choose(statuses)
+	self.mu.Unlock()
+	if err != nil {
+		logger.Warnf("%s, using the primary one", err)
+	}
+	addr := statuses[i].Address
+	if err := self.pool.UseOperator(addr); err != nil {
+		logger.Warnf("Switching pricing operator failed: %s", err)
+		return addr
+	}
+	self.mu.Lock()
+	self.active = addr
+	self.mu.Unlock()
+	logger.Infof("Feeding from pricing operator %s (%s policy)", addr.Hex(), self.policy)
+	return addr
+}
+
+// EachInactive calls f with each pricing operator but the active one
+// as the operator txs are sent from, then switches back to the active one
+func (self *OperatorSelector) EachInactive(ctx context.Context, f func()) {
+	logger := logging.FromContext(ctx, logging.Std())
+	self.mu.Lock()
+	active := self.active
+	self.mu.Unlock()
+	for _, addr := range self.pool.PricingAddresses() {
+		if addr == active {
+			continue
+		}
+		if err := self.pool.UseOperator(addr); err != nil {
+			logger.Warnf("Switching to pricing operator %s failed: %s", addr.Hex(), err)
+			continue
+		}
+		f()
+	}
+	if err := self.pool.UseOperator(active); err != nil {
+		logger.Warnf("Switching back to pricing operator %s failed: %s", active.Hex(), err)
+	}
+}
+
+func NewOperatorSelector(pool OperatorPool, journal TxJournal, policy string) *OperatorSelector {
+	switch policy {
+	case OPERATOR_POLICY_NOT_STUCK, OPERATOR_POLICY_ROUND_ROBIN, OPERATOR_POLICY_BALANCE:
+	default:
+		panic(fmt.Sprintf("unsupported operator policy: %s", policy))
+	}
+	addrs := pool.PricingAddresses()
+	if len(addrs) == 0 {
+		panic(errors.New("no pricing operator"))
+	}
+	return &OperatorSelector{
+		pool:    pool,
+		journal: journal,
+		policy:  policy,
+		active:  addrs[0],
+	}
+}
@@ -0,0 +1,181 @@
+package dgxpricing
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// newOperatorsHarness returns a feeder with n pricing operators chosen
+// by policy
+func newOperatorsHarness(t *testing.T, n int, policy string) *harness {
+	h := newHarnessFor(t, simulation.NewBackendWithOperators(n), STUCK_POLICY_REPORT, clock.NewRealClock())
+	h.feeder.pollInterval = 20 * time.Millisecond
+	h.feeder.txWaitTime = time.Hour
+	h.feeder.EnableOperatorSelection(h.backend.Reserve, policy)
+	return h
+}
+
+func (self *harness) operator(i int) ethereum.Address {
+	return crypto.PubkeyToAddress(self.backend.OperatorKeys[i].PublicKey)
+}
+
+func txSender(t *testing.T, tx *types.Transaction) ethereum.Address {
+	from, err := types.Sender(types.HomesteadSigner{}, tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return from
+}
+
+func TestStuckPrimaryFailsOver(t *testing.T) {
+	h := newOperatorsHarness(t, 2, OPERATOR_POLICY_NOT_STUCK)
+	defer h.Close()
+	// a tx of the primary stays in the pool as it pays less than the min
+	// gas price
+	h.backend.Chain.SetMinGasPrice(big.NewInt(2 * GWEI))
+	stuck, err := types.SignTx(
+		types.NewTransaction(0, h.operator(0), big.NewInt(0), big.NewInt(21000), big.NewInt(GWEI), nil),
+		types.HomesteadSigner{}, h.backend.OperatorKeys[0],
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.backend.Chain.SendTransaction(stuck); err != nil {
+		t.Fatal(err)
+	}
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if from := txSender(t, tx); from != h.operator(1) {
+		t.Fatalf("Expected the feed to be sent from %s, got %s", h.operator(1).Hex(), from.Hex())
+	}
+	statuses := h.feeder.OperatorStatuses()
+	if len(statuses) != 2 || !statuses[0].Stuck || statuses[0].Active || !statuses[1].Active || statuses[1].Stuck {
+		t.Fatalf("Expected the primary to be reported stuck and the other one active, got %+v", statuses)
+	}
+}
+
+func TestStuckNonceOfPrimaryIsCancelledWhileOtherOperatorFeeds(t *testing.T) {
+	h := newHarnessFor(t, simulation.NewBackendWithOperators(2), STUCK_POLICY_REFEED, clock.NewRealClock())
+	defer h.Close()
+	h.feeder.pollInterval = 20 * time.Millisecond
+	h.feeder.txWaitTime = time.Hour
+	h.feeder.EnableOperatorSelection(h.backend.Reserve, OPERATOR_POLICY_NOT_STUCK)
+	h.backend.Chain.SetMinGasPrice(big.NewInt(2 * GWEI))
+	stuck, err := types.SignTx(
+		types.NewTransaction(0, h.operator(0), big.NewInt(0), big.NewInt(21000), big.NewInt(GWEI), nil),
+		types.HomesteadSigner{}, h.backend.OperatorKeys[0],
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.backend.Chain.SendTransaction(stuck); err != nil {
+		t.Fatal(err)
+	}
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if from := txSender(t, tx); from != h.operator(1) {
+		t.Fatalf("Expected the feed to be sent from %s, got %s", h.operator(1).Hex(), from.Hex())
+	}
+	// the primary doesn't feed this cycle, its nonce is cancelled even
+	// though the policy refeeds the nonces of the feeding operator
+	txs := h.journal.TxsAtNonce(h.operator(0), 0)
+	if len(txs) != 1 || txs[0].To() == nil || *txs[0].To() != h.operator(0) {
+		t.Fatalf("Expected the stuck nonce of the primary to be cancelled, got %d txs", len(txs))
+	}
+	if h.feeder.reserve.PricingAddress() != h.operator(1) {
+		t.Fatalf("Expected %s to stay the pricing operator, got %s", h.operator(1).Hex(), h.feeder.reserve.PricingAddress().Hex())
+	}
+
+	// once the cancel is mined the primary feeds again
+	tx, status, err = wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if from := txSender(t, tx); from != h.operator(0) || tx.Nonce() != 1 {
+		t.Fatalf("Expected the feed to be sent from %s at nonce 1, got %s at nonce %d", h.operator(0).Hex(), from.Hex(), tx.Nonce())
+	}
+}
+
+func TestRoundRobinOperators(t *testing.T) {
+	h := newOperatorsHarness(t, 2, OPERATOR_POLICY_ROUND_ROBIN)
+	defer h.Close()
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		tx, status, err := wait(t, h.feeder.FeedOnce)
+		if err != nil || status != "mined" {
+			t.Fatalf("Expected feed %d to be mined, got status %s, err %v", i, status, err)
+		}
+		if from := txSender(t, tx); from != h.operator(i%2) {
+			t.Fatalf("Expected feed %d to be sent from %s, got %s", i, h.operator(i%2).Hex(), from.Hex())
+		}
+	}
+}
+
+func TestHighestBalanceOperator(t *testing.T) {
+	h := newOperatorsHarness(t, 3, OPERATOR_POLICY_BALANCE)
+	defer h.Close()
+	h.backend.Chain.Fund(h.operator(2), big.NewInt(GWEI))
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if from := txSender(t, tx); from != h.operator(2) {
+		t.Fatalf("Expected the feed to be sent from the richest operator %s, got %s", h.operator(2).Hex(), from.Hex())
+	}
+}
+
+func TestOnlyTxsOfPricingOperatorsAreResent(t *testing.T) {
+	h := newOperatorsHarness(t, 2, OPERATOR_POLICY_NOT_STUCK)
+	defer h.Close()
+	sign := func(signer types.Signer, key *ecdsa.PrivateKey) *types.Transaction {
+		tx, err := types.SignTx(
+			types.NewTransaction(0, simulation.RESERVE_ADDRESS, big.NewInt(0), big.NewInt(100000), big.NewInt(GWEI), []byte{1}),
+			signer, key,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	// an EIP-155 tx is resent by the operator which signed it
+	protected := sign(types.NewEIP155Signer(big.NewInt(1)), h.backend.OperatorKeys[1])
+	if from, err := h.backend.Reserve.OperatorOf(protected); err != nil || from != h.operator(1) {
+		t.Fatalf("Expected the EIP-155 tx to be sent by %s, got %s, err %v", h.operator(1).Hex(), from.Hex(), err)
+	}
+	// a tx of another account is never signed again with our keys
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign := sign(types.HomesteadSigner{}, key)
+	if _, err := h.backend.Reserve.OperatorOf(foreign); err == nil {
+		t.Fatalf("Expected tx %s not to be of a pricing operator", foreign.Hash().Hex())
+	}
+	if tx, err := h.backend.Reserve.Replace(context.Background(), foreign, big.NewInt(2*GWEI)); err == nil || tx != nil {
+		t.Fatalf("Expected the foreign tx not to be replaced, got tx %v, err %v", tx, err)
+	}
+	if tx, err := h.backend.Reserve.Rebroadcast(context.Background(), foreign); err == nil || tx != nil {
+		t.Fatalf("Expected the foreign tx not to be rebroadcasted, got tx %v, err %v", tx, err)
+	}
+}
@@ -1,10 +1,21 @@
 package dgxpricing
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
+	"github.com/KyberNetwork/dgx-price-feeder/alert"
+	"github.com/KyberNetwork/dgx-price-feeder/broadcast"
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/history"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+	"github.com/KyberNetwork/dgx-price-feeder/tracing"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -16,12 +27,148 @@ const (
 	NO_STEP       int   = 3
 	// TX_WAIT_TIME  uint64 = 10 // 10 seconds
 	TX_WAIT_TIME uint64 = 10 * 60 // 10 minutes
+	// HEAD_TIMEOUT is how long the monitor waits for a new block before
+	// checking the txs anyway, in case the head source is stalled
+	HEAD_TIMEOUT time.Duration = 2 * time.Minute
 )
 
 type PriceFeeder struct {
 	runner  Runner
 	reserve Reserve
 	prices  PriceCorpus
+	journal TxJournal
+	nonces  *NonceChecker
+	// in shadow mode txs are journaled but never broadcasted nor monitored
+	shadow     bool
+	lastShadow *shadowDecision
+	// cycle makes sure only one feeding cycle runs at a time
+	cycle sync.Mutex
+	clock clock.Clock
+	// heads triggers tx status lookups, without it statuses are polled
+	// every pollInterval. txWaitTime is how long a tx can be pending
+	// before it is replaced
+	heads        HeadSource
+	pollInterval time.Duration
+	txWaitTime   time.Duration
+	// operators chooses the pricing operator of each cycle, without it
+	// every feed is sent from the primary one
+	operators *OperatorSelector
+	// leadership is set when several instances run, only the leader
+	// feeds and the others only fetch and validate feeds
+	leadership Leadership
+	// history keeps fetched feeds and sent txs, it is optional
+	history History
+	// budget caps gas prices, replacements and daily spending, it is
+	// optional
+	budget  *GasBudget
+	alerter alert.Alerter
+	retry   RetryPolicy
+	// guard tells if failed feeds are caused by the pricing operator not
+	// being an operator of the reserve, it is optional
+	guard *OperatorGuard
+	// tickPolicy tells what to do with a tick arriving while a cycle is
+	// in flight. With the supersede policy, supersede asks the monitor of
+	// the feed tx to replace it while supersedable is 1.
+	tickPolicy   string
+	missedTicks  int64
+	supersede    chan struct{}
+	supersedable int32
+	// lastFeedNonce is the Digix nonce of the last feed sent
+	lastFeedNonce *big.Int
+	// logger is the one of lines which are not about a cycle, every cycle
+	// has a logger with its ID which is passed down in the cycle's context
+	logger *logging.Logger
+	// tracer is optional, the root span of every cycle is passed down in
+	// the cycle's context
+	tracer *tracing.Tracer
+}
+
+// EnableShadowMode makes the feeder go through the whole pipeline without
+// broadcasting, the reserve must be in shadow mode as well
+func (self *PriceFeeder) EnableShadowMode() {
+	self.shadow = true
+}
+
+// SetHeadSource makes the monitor look up tx statuses on new blocks
+// instead of polling them
+func (self *PriceFeeder) SetHeadSource(heads HeadSource) {
+	self.heads = heads
+}
+
+// EnableOperatorSelection makes each cycle choose its pricing operator
+// among the ones of pool according to policy
+func (self *PriceFeeder) EnableOperatorSelection(pool OperatorPool, policy string) {
+	self.operators = NewOperatorSelector(pool, self.journal, policy)
+}
+
+// OperatorStatuses returns the status of every pricing operator, nil if
+// operator selection is not enabled
+func (self *PriceFeeder) OperatorStatuses() []OperatorStatus {
+	if self.operators == nil {
+		return nil
+	}
+	return self.operators.Statuses()
+}
+
+// ErrNotLeader is returned when a tx is not sent because the instance
+// isn't the leader anymore, another instance takes care of the nonce
+var ErrNotLeader = errors.New("this instance is not the leader")
+
+// SetLeadership makes the feeder feed only while it is the leader. The
+// leadership is checked before every tx is sent.
+func (self *PriceFeeder) SetLeadership(leadership Leadership) {
+	self.leadership = leadership
+}
+
+// IsLeader tells if the feeder feeds, ie it is the leader or it runs
+// alone
+func (self *PriceFeeder) IsLeader() bool {
+	return self.leadership == nil || self.leadership.IsLeader()
+}
+
+// SetLogger sets the logger of the feeder, the standard logger by
+// default. Lines about a cycle carry its ID, including the ones of the
+// reserve and the corpus which log through the logger of the cycle's
+// context.
+func (self *PriceFeeder) SetLogger(logger *logging.Logger) {
+	self.logger = logger
+	self.nonces.logger = logger
+	if journal, ok := self.journal.(historyJournal); ok {
+		journal.logger = logger
+		self.journal = journal
+		self.nonces.journal = journal
+	}
+}
+
+// log returns the logger of the cycle ctx belongs to
+func (self *PriceFeeder) log(ctx context.Context) *logging.Logger {
+	return logging.FromContext(ctx, self.logger)
+}
+
+// SetTracer makes every cycle a trace, with spans for fetching the feed,
+// building, signing and broadcasting txs, status polls and replacements
+func (self *PriceFeeder) SetTracer(tracer *tracing.Tracer) {
+	self.tracer = tracer
+}
+
+func newCycleID() string {
+	id := make([]byte, 6)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// beginCycle gives a new ID to a cycle. It returns the context of the
+// cycle, carrying a logger with its ID and its root span, and a function
+// which ends it.
+func (self *PriceFeeder) beginCycle(name string) (context.Context, func()) {
+	id := newCycleID()
+	span := self.tracer.Start(name, "cycle", id)
+	logger := self.logger.With("cycle", id)
+	if span != nil {
+		logger = logger.With("trace_id", span.TraceID())
+	}
+	ctx := tracing.NewContext(logging.NewContext(context.Background(), logger), span)
+	return ctx, span.End
 }
 
 func (self *PriceFeeder) Run() {
@@ -33,100 +180,532 @@ func (self *PriceFeeder) Stop() {
 	self.runner.Stop()
 }
 
+func (self *PriceFeeder) record(ctx context.Context, kind string, tx *types.Transaction) {
+	if err := self.journal.Record(kind, tx); err != nil {
+		self.log(ctx).Warnf("Journaling tx %s failed: %s", tx.Hash().Hex(), err)
+	}
+}
+
+func (self *PriceFeeder) setStatus(ctx context.Context, tx *types.Transaction, status string) {
+	if err := self.journal.SetStatus(tx.Hash(), status); err != nil {
+		self.log(ctx).Warnf("Journaling status of tx %s failed: %s", tx.Hash().Hex(), err)
+	}
+	if status == "mined" || status == "failed" {
+		self.recordCost(ctx, tx)
+	}
+}
+
+func bumpGasPrice(gasPrice *big.Int) *big.Int {
+	return big.NewInt(0).Add(gasPrice, big.NewInt(GASPRICE_STEP))
+}
+
+// mightBeInPool tells if a tx sent with err might have reached a node,
+// it is false only when every node answered and rejected the tx
+func mightBeInPool(err error) bool {
+	result := broadcast.ResultOf(err)
+	return result == nil || result.Accepted() || result.Count(broadcast.UNREACHABLE) > 0
+}
+
 func (self *PriceFeeder) TryFeedingPrice() (*types.Transaction, error) {
-	blockno, nonce, ask, bid, v, r, s, err := self.prices.GetFeed()
+	return self.tryFeedingPrice(context.Background(), self.prices)
+}
+
+func (self *PriceFeeder) tryFeedingPrice(ctx context.Context, prices PriceCorpus) (*types.Transaction, error) {
+	record := &history.Feed{Time: self.clock.Now()}
+	blockno, nonce, ask, bid, v, r, s, err := self.getFeed(ctx, prices, record)
 	if err != nil {
+		self.recordFeed(ctx, record, history.DECISION_REJECTED, err.Error(), nil)
 		return nil, err
 	}
 	gasPrice := big.NewInt(INIT_GASPRICE)
-	return self.reserve.SetPriceFeed(gasPrice, blockno, nonce, ask, bid, v, r, s)
+	if err := self.checkBudget(ctx, gasPrice, FEED_GAS_ESTIMATE, 0); err != nil {
+		self.recordFeed(ctx, record, history.DECISION_REJECTED, err.Error(), nil)
+		return nil, err
+	}
+	if !self.shadow && !self.IsLeader() {
+		self.recordFeed(ctx, record, history.DECISION_SKIPPED, "not the leader", nil)
+		return nil, ErrNotLeader
+	}
+	tx, err := self.reserve.SetPriceFeed(ctx, gasPrice, blockno, nonce, ask, bid, v, r, s)
+	if self.shadow {
+		if err != nil {
+			shadowRejected.Inc(1)
+			self.recordFeed(ctx, record, history.DECISION_REJECTED, err.Error(), nil)
+			return nil, err
+		}
+		self.record(ctx, "shadow", tx)
+		// shadow txs will never be mined, mark them final right away
+		self.setStatus(ctx, tx, "shadow")
+		shadowFeeds.Inc(1)
+		self.lastShadow = &shadowDecision{tx, blockno, nonce, ask, bid}
+		self.recordFeed(ctx, record, history.DECISION_SKIPPED, "shadow mode", tx)
+		return tx, nil
+	}
+	if err != nil && tx == nil {
+		// the tx couldn't be built, ie its gas estimation reverted
+		if opErr := self.operatorError(); opErr != nil {
+			err = opErr
+		}
+	}
+	if tx != nil && mightBeInPool(err) {
+		self.record(ctx, "feed", tx)
+		self.lastFeedNonce = nonce
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		}
+		self.recordFeed(ctx, record, history.DECISION_SUBMITTED, reason, tx)
+	} else if err != nil {
+		self.recordFeed(ctx, record, history.DECISION_REJECTED, err.Error(), nil)
+	}
+	if err != nil {
+		feedsFailed.Inc(1)
+	} else {
+		feedsSubmitted.Inc(1)
+		lastGasPrice.Update(big.NewInt(0).Div(gasPrice, big.NewInt(1000000000)).Int64())
+	}
+	return tx, err
+}
+
+// checkNonces looks for txs of the pricing operator that would block
+// the next price feed and resolves them according to the stuck tx policy.
+// Replacements are monitored until they are final.
+func (self *PriceFeeder) checkNonces(ctx context.Context) {
+	for _, tx := range self.resolveNonces(ctx, true) {
+		self.monitorAndRetry(ctx, tx, nil)
+	}
+}
+
+// checkOtherOperators resolves the stuck nonces of the pricing operators
+// which don't feed this cycle, so a stuck operator becomes usable again.
+// Their nonces are cancelled rather than refed and the cancels are not
+// waited for, the next cycles check them again.
+func (self *PriceFeeder) checkOtherOperators(ctx context.Context) {
+	self.operators.EachInactive(ctx, func() {
+		self.resolveNonces(ctx, false)
+	})
 }
 
-func (self *PriceFeeder) MonitorAndRetry(tx *types.Transaction) error {
-	startTime := time.Now()
+// resolveNonces checks the nonces of the pricing operator and sends the
+// txs resolving its stuck ones, which it returns. Only the operator
+// feeding the cycle may refeed a stuck nonce.
+func (self *PriceFeeder) resolveNonces(ctx context.Context, refeed bool) []*types.Transaction {
+	operator := self.reserve.PricingAddress()
+	checkCtx, span := tracing.Start(ctx, "nonces.check", "operator", operator.Hex())
+	defer span.End()
+	report, err := self.nonces.Check(checkCtx, self.txWaitTime)
+	if err != nil {
+		self.log(ctx).Warnf("Checking nonces of pricing operator %s failed: %s", operator.Hex(), err)
+		span.SetError(err)
+		return nil
+	}
+	self.log(ctx).Infof("Pricing operator %s nonces: mined(%d), pending(%d)", operator.Hex(), report.MinedNonce, report.PendingNonce)
+	span.SetAttributes("mined_nonce", report.MinedNonce, "pending_nonce", report.PendingNonce, "stuck", report.Stuck())
+	if !report.Stuck() {
+		return nil
+	}
+	for _, tx := range report.Unknown {
+		self.log(ctx).Warnf("Unknown pending tx %s at nonce %d, gas price: %s", tx.Hash().Hex(), tx.Nonce(), tx.GasPrice())
+	}
+	for _, tx := range report.Stale {
+		self.log(ctx).Warnf("Journaled tx %s at nonce %d is pending for longer than %s, gas price: %s", tx.Hash().Hex(), tx.Nonce(), self.txWaitTime, tx.GasPrice())
+	}
+	for _, n := range report.Gaps {
+		self.log(ctx).Warnf("Nonce gap at %d", n)
+	}
+	if self.shadow {
+		self.log(ctx).Infof("Shadow mode: not resolving stuck nonces")
+		return nil
+	}
+	if !self.IsLeader() {
+		self.log(ctx).Infof("Not the leader, not resolving stuck nonces")
+		return nil
+	}
+	txs, err := self.nonces.Resolve(checkCtx, report, refeed, func(gasPrice *big.Int, gas uint64) error {
+		return self.checkBudget(ctx, gasPrice, gas, 0)
+	})
+	if err != nil {
+		self.log(ctx).Warnf("Resolving stuck nonces of pricing operator %s failed: %s", operator.Hex(), err)
+		span.SetError(err)
+	}
+	return txs
+}
+
+// MonitorAndRetry monitors tx and its replacements until one of them is final.
+// It returns the final tx and its status.
+func (self *PriceFeeder) MonitorAndRetry(tx *types.Transaction) (*types.Transaction, string, error) {
+	return self.monitorAndRetry(context.Background(), tx, nil)
+}
+
+// monitorAndRetry is MonitorAndRetry, the last tx is superseded by a
+// newer feed when supersede is notified
+func (self *PriceFeeder) monitorAndRetry(ctx context.Context, tx *types.Transaction, supersede <-chan struct{}) (*types.Transaction, string, error) {
+	ctx, span := tracing.Start(ctx, "tx.monitor", "tx", tx.Hash().Hex(), "nonce", tx.Nonce(), "gas_price", tx.GasPrice())
+	final, status, err := self.monitorTx(ctx, tx, supersede)
+	span.SetAttributes("final_tx", final.Hash().Hex(), "status", status)
+	span.SetError(err)
+	span.End()
+	return final, status, err
+}
+
+// monitorTx is monitorAndRetry with every status poll, replacement and
+// rebroadcast traced under the span of ctx
+func (self *PriceFeeder) monitorTx(ctx context.Context, tx *types.Transaction, supersede <-chan struct{}) (*types.Transaction, string, error) {
 	// this list should be sorted by gas price
-	monitor := NewStatusMonitor(tx)
+	monitor := NewStatusMonitor(tx, self.clock, self.log(ctx))
+	// gas price of the last replacement rejected as underpriced, the
+	// next one is bumped from it
+	var underpriced *big.Int
+	var heads <-chan uint64
+	if self.heads != nil {
+		var unsubscribe func()
+		heads, unsubscribe = self.heads.SubscribeHeads()
+		defer unsubscribe()
+	}
 	for {
-		// polling the tx status each 10s
-		status, tx, err := monitor.GetStatus(self.reserve)
+		poll := tracing.FromContext(ctx).Start("tx.status", "txs", monitor.Replacements()+1)
+		status, tx, err := monitor.GetStatus(ctx, self.reserve)
+		if err == nil {
+			poll.SetAttributes("status", status, "tx", tx.Hash().Hex(), "nonce", tx.Nonce(), "gas_price", tx.GasPrice())
+		}
+		poll.SetError(err)
+		poll.End()
 		if err != nil {
-			log.Printf("Getting tx status failed: %s", err.Error())
+			self.log(ctx).Warnf("Getting tx status failed: %s", err.Error())
 		} else {
 			switch status {
 			case "pending":
 				// it is still pending, if it is taking too long, replace it
 				// with a new tx with higher nonce
-				currentTime := time.Now()
-				if uint64(currentTime.Sub(startTime)) > uint64(TX_WAIT_TIME*uint64(time.Second)) {
-					newTx := types.NewTransaction(
-						tx.Nonce(),
-						*tx.To(),
-						tx.Value(),
-						tx.Gas(),
-						big.NewInt(0).Add(tx.GasPrice(), big.NewInt(GASPRICE_STEP)),
-						tx.Data(),
-					)
-					log.Printf("Replacing old tx with tx %s", newTx.Hash().Hex())
-					newSignedTx, err := self.reserve.Rebroadcast(newTx)
-					if err != nil {
-						log.Printf("Replacing old tx failed, err(%s). Ignore, will try next time", err.Error())
-					} else {
+				if monitor.WaitingTime() > self.txWaitTime {
+					if !self.IsLeader() {
+						self.log(ctx).Warnf("Not the leader anymore, not replacing tx %s. Finish monitoring.", tx.Hash().Hex())
+						return tx, "pending", ErrNotLeader
+					}
+					gasPrice := tx.GasPrice()
+					if underpriced != nil && underpriced.Cmp(gasPrice) > 0 {
+						gasPrice = underpriced
+					}
+					newGasPrice := bumpGasPrice(gasPrice)
+					if err := self.checkBudget(ctx, newGasPrice, tx.Gas().Uint64(), monitor.Replacements()+1); err != nil {
+						self.log(ctx).Warnf("Not replacing tx %s: %s. Finish monitoring.", tx.Hash().Hex(), err)
+						return tx, "pending", err
+					}
+					self.log(ctx).Infof("Replacing old tx %s with gas price %s", tx.Hash().Hex(), newGasPrice)
+					replaceCtx, replace := tracing.Start(ctx, "tx.replace", "tx", tx.Hash().Hex(), "nonce", tx.Nonce(), "gas_price", newGasPrice, "replacement", monitor.Replacements()+1)
+					newSignedTx, err := self.reserve.Replace(replaceCtx, tx, newGasPrice)
+					if newSignedTx != nil {
+						replace.SetAttributes("new_tx", newSignedTx.Hash().Hex())
+					}
+					replace.SetError(err)
+					replace.End()
+					result := broadcast.ResultOf(err)
+					switch {
+					case result != nil && result.Rejected(broadcast.UNDERPRICED):
+						self.log(ctx).Infof("Replacement %s is underpriced, bumping it again next time", newSignedTx.Hash().Hex())
+						underpriced = newGasPrice
+					case result != nil && result.Rejected(broadcast.INSUFFICIENT_FUNDS):
+						self.log(ctx).Errorf("The pricing operator can't pay for replacement %s, keep waiting for tx %s", newSignedTx.Hash().Hex(), tx.Hash().Hex())
+					case result != nil && result.Rejected(broadcast.NONCE_TOO_LOW):
+						self.log(ctx).Infof("Nonce %d is already used, checking the txs again", tx.Nonce())
+					case err != nil:
+						self.log(ctx).Warnf("Replacing old tx failed, err(%s). Ignore, will try next time", err.Error())
+					default:
+						self.record(ctx, "replacement", newSignedTx)
+						txsReplaced.Inc(1)
+						lastGasPrice.Update(big.NewInt(0).Div(newSignedTx.GasPrice(), big.NewInt(1000000000)).Int64())
+						// the replacement gets the same waiting time
 						monitor.PushTx(newSignedTx)
 					}
 				}
 			case "lost":
+				// the nonce might have been used by another tx, ie a cancel,
+				// in that case rebroadcasting will never succeed
+				if mined, err := self.reserve.MinedNonce(); err == nil && mined > tx.Nonce() {
+					self.log(ctx).Infof("Nonce %d of tx %s was used by another tx. Finish monitoring.", tx.Nonce(), tx.Hash().Hex())
+					self.setStatus(ctx, tx, "replaced")
+					return tx, "replaced", nil
+				}
+				if !self.IsLeader() {
+					self.log(ctx).Warnf("Not the leader anymore, not rebroadcasting tx %s. Finish monitoring.", tx.Hash().Hex())
+					return tx, "lost", ErrNotLeader
+				}
 				// retry
-				_, err := self.reserve.Rebroadcast(tx)
-				if err != nil {
-					log.Printf("Rebroadcasting the tx failed, err(%s). Ignore, will try next time", err.Error())
+				rebroadcastCtx, rebroadcast := tracing.Start(ctx, "tx.rebroadcast", "tx", tx.Hash().Hex(), "nonce", tx.Nonce(), "gas_price", tx.GasPrice())
+				_, err := self.reserve.Rebroadcast(rebroadcastCtx, tx)
+				rebroadcast.SetError(err)
+				rebroadcast.End()
+				result := broadcast.ResultOf(err)
+				switch {
+				case result != nil && result.Rejected(broadcast.NONCE_TOO_LOW):
+					self.log(ctx).Infof("Nonce %d of tx %s was used by another tx. Finish monitoring.", tx.Nonce(), tx.Hash().Hex())
+					self.setStatus(ctx, tx, "replaced")
+					return tx, "replaced", nil
+				case result != nil && result.Rejected(broadcast.INSUFFICIENT_FUNDS):
+					self.log(ctx).Errorf("The pricing operator can't pay for tx %s. Finish monitoring.", tx.Hash().Hex())
+					self.setStatus(ctx, tx, "lost")
+					return tx, "lost", err
+				case err != nil:
+					self.log(ctx).Warnf("Rebroadcasting the tx failed, err(%s). Ignore, will try next time", err.Error())
 				}
 			case "mined":
 				// the tx is successfully done
-				log.Printf("Tx %s is mined. Finish monitoring.", tx.Hash().Hex())
-				return nil
+				self.log(ctx).Infof("Tx %s is mined. Finish monitoring.", tx.Hash().Hex())
+				txsMined.Inc(1)
+				self.setStatus(ctx, tx, status)
+				return tx, status, nil
 			case "failed":
 				// we dont retry in this case, it will just fail
-				log.Printf("Tx %s is failed. Finish monitoring.", tx.Hash().Hex())
-				return nil
+				self.log(ctx).Warnf("Tx %s is failed. Finish monitoring.", tx.Hash().Hex())
+				txsReverted.Inc(1)
+				self.setStatus(ctx, tx, status)
+				return tx, status, nil
 			}
 		}
-		time.Sleep(10 * time.Second)
+		if self.waitForNextCheck(ctx, heads, supersede) {
+			self.supersedeTx(ctx, monitor)
+		}
 	}
 }
 
-func (self *PriceFeeder) EnsureFeedPrice() {
-	for i := 0; i < NO_RETRY; i++ {
-		log.Printf("Try feeding price")
-		tx, err := self.TryFeedingPrice()
-		if err != nil {
-			log.Printf("%d(th) Try failed: err(%s)", err.Error())
-		} else {
-			// monitor the status and increase the gas price by GASPRICE_STEP if needed
-			// gas price will be increased only NO_STEP times
+// waitForNextCheck blocks until a new block arrives, or for pollInterval
+// if there is no head source. It returns true if supersede was notified
+// in the meantime.
+func (self *PriceFeeder) waitForNextCheck(ctx context.Context, heads <-chan uint64, supersede <-chan struct{}) bool {
+	if heads == nil {
+		select {
+		case <-self.clock.After(self.pollInterval):
+		case <-supersede:
+			return true
+		}
+		return false
+	}
+	select {
+	case <-heads:
+	case <-self.clock.After(HEAD_TIMEOUT):
+		self.log(ctx).Warnf("No new block in %s, checking the txs anyway", HEAD_TIMEOUT)
+	case <-supersede:
+		return true
+	}
+	return false
+}
 
-			// err will be returned only when we giveup increasing nonce
-			err := self.MonitorAndRetry(tx)
+// EnsureFeedPrice feeds the price and monitors the tx until it is final.
+// It returns the final tx and its status.
+func (self *PriceFeeder) EnsureFeedPrice() (*types.Transaction, string, error) {
+	return self.ensureFeedPrice(context.Background(), self.prices)
+}
+
+func (self *PriceFeeder) ensureFeedPrice(ctx context.Context, prices PriceCorpus) (*types.Transaction, string, error) {
+	// a tick arriving while the feed tx is sent supersedes it once it is
+	// monitored
+	supersede, stop := self.startSuperseding()
+	defer stop()
+	var err error
+	for i := 1; i <= self.retry.Attempts; i++ {
+		self.log(ctx).Infof("Try feeding price")
+		var tx *types.Transaction
+		// every try fetches a fresh feed
+		attemptCtx, attempt := tracing.Start(ctx, "feed.attempt", "attempt", i)
+		tx, err = self.tryFeedingPrice(attemptCtx, prices)
+		if tx != nil {
+			attempt.SetAttributes("tx", tx.Hash().Hex(), "nonce", tx.Nonce(), "gas_price", tx.GasPrice())
+		}
+		attempt.SetError(err)
+		attempt.End()
+		switch {
+		case err == nil && self.shadow:
+			self.log(ctx).Infof("Shadow mode: would have sent tx %s, not monitoring it", tx.Hash().Hex())
+			return tx, "shadow", nil
+		case err == nil || (tx != nil && mightBeInPool(err)):
+			// monitor the status and replace the tx with a higher gas
+			// price if needed. A tx no node answered for might be in a
+			// pool, a fresh feed would only compete with it for the nonce.
+			if err != nil {
+				self.log(ctx).Warnf("Sending tx %s might have failed, monitoring it: %s", tx.Hash().Hex(), err)
+			}
+			final, status, err := self.monitorAndRetry(ctx, tx, supersede)
+			if err == nil && status == "failed" {
+				err = self.revertError(final)
+			}
 			if err != nil {
-				log.Printf("Gave up on setting the price feed after increased the nonce for %d times", NO_STEP)
+				self.giveUp(ctx, err)
 			}
-			return
+			return final, status, err
+		case !Retryable(err):
+			self.log(ctx).Warnf("%d(th) Try failed, not retrying: err(%s)", i, err.Error())
+			self.giveUp(ctx, err)
+			return tx, "", err
+		}
+		self.log(ctx).Warnf("%d(th) Try failed: err(%s)", i, err.Error())
+		if i < self.retry.Attempts {
+			delay := self.retry.Delay(i)
+			self.log(ctx).Infof("Retrying in %s", delay)
+			self.clock.Sleep(delay)
 		}
 	}
+	err = errors.New(fmt.Sprintf("Gave up feeding the price after %d tries, last error: %s", self.retry.Attempts, err))
+	self.giveUp(ctx, err)
+	return nil, "", err
 }
 
+// operatorError returns the guard's *OperatorError if the pricing
+// operator txs are sent from is not an operator of the reserve
+func (self *PriceFeeder) operatorError() error {
+	if self.guard == nil {
+		return nil
+	}
+	err := self.guard.Check()
+	if opErr, ok := err.(*OperatorError); ok && IsOperator(opErr.Operators, self.reserve.PricingAddress()) {
+		return err
+	}
+	return nil
+}
+
+// revertError tells why the feed tx reverted
+func (self *PriceFeeder) revertError(tx *types.Transaction) error {
+	if err := self.operatorError(); err != nil {
+		return err
+	}
+	return errors.New(fmt.Sprintf("Tx %s reverted", tx.Hash().Hex()))
+}
+
+// giveUp reports a feeding cycle which ends without the price being fed,
+// budget errors are alerted when they happen, operator errors are alerted
+// by the guard when the role is lost and losing the leadership isn't
+// alerted
+func (self *PriceFeeder) giveUp(ctx context.Context, err error) {
+	self.log(ctx).Errorf("Gave up on setting the price feed: %s", err)
+	feedsGaveUp.Inc(1)
+	if !IsBudgetError(err) && !IsOperatorError(err) && err != ErrNotLeader {
+		self.alerter.Alert(alert.CRITICAL, fmt.Sprintf("Gave up on setting the price feed: %s", err))
+	}
+}
+
+// FeedOnce runs one feeding cycle: resolves stuck nonces, then
+// feeds the price and monitors the tx until it is final.
+func (self *PriceFeeder) FeedOnce() (*types.Transaction, string, error) {
+	return self.feedOnce(self.prices)
+}
+
+// FeedManually runs one feeding cycle with a feed from prices instead
+// of the feeder's corpus, ie a feed Digix sent out of band
+func (self *PriceFeeder) FeedManually(prices PriceCorpus) (*types.Transaction, string, error) {
+	self.logger.Infof("Going to feed a manual price to the contract")
+	return self.feedOnce(prices)
+}
+
+func (self *PriceFeeder) feedOnce(prices PriceCorpus) (*types.Transaction, string, error) {
+	self.cycle.Lock()
+	defer self.cycle.Unlock()
+	ctx, end := self.beginCycle("feed.cycle")
+	defer end()
+	if self.shadow {
+		self.compareWithOnchain(ctx)
+	}
+	if self.operators != nil {
+		self.operators.Select(ctx)
+		self.checkOtherOperators(ctx)
+	}
+	tracing.FromContext(ctx).SetAttributes("operator", self.reserve.PricingAddress().Hex())
+	self.checkNonces(ctx)
+	return self.ensureFeedPrice(ctx, prices)
+}
+
+// standBy fetches and validates the feed without sending it, so a
+// standby instance is ready to take over
+func (self *PriceFeeder) standBy() {
+	self.cycle.Lock()
+	defer self.cycle.Unlock()
+	ctx, end := self.beginCycle("standby.cycle")
+	defer end()
+	record := &history.Feed{Time: self.clock.Now()}
+	blockno, nonce, ask, bid, _, _, _, err := self.getFeed(ctx, self.prices, record)
+	if err != nil {
+		self.log(ctx).Warnf("Standby: fetching the feed failed: %s", err)
+		self.recordFeed(ctx, record, history.DECISION_REJECTED, err.Error(), nil)
+		return
+	}
+	self.recordFeed(ctx, record, history.DECISION_SKIPPED, "standby", nil)
+	self.log(ctx).Infof("Standby: feed is valid, block(%s), nonce(%s), ask(%s), bid(%s). Not the leader, not feeding it.", blockno, nonce, ask, bid)
+}
+
+// feedPricePeriodically runs a cycle on every tick, in the background so
+// ticks arriving while a cycle is in flight are handled by the tick
+// policy
 func (self *PriceFeeder) feedPricePeriodically() {
+	var elected <-chan struct{}
+	if self.leadership != nil {
+		elected = self.leadership.Elected()
+	}
+	ticks := self.runner.GetPricingTicker()
+	done := make(chan struct{})
+	run := func() {
+		// the cycle below handles a pending election
+		select {
+		case <-elected:
+		default:
+		}
+		go func() {
+			if self.IsLeader() {
+				self.logger.Infof("Going to feed the price to the contract")
+				self.FeedOnce()
+			} else {
+				self.standBy()
+			}
+			done <- struct{}{}
+		}()
+	}
+	run()
+	inFlight, queued := true, false
 	for {
-		log.Printf("Going to feed the price to the contract")
-		self.EnsureFeedPrice()
-		log.Printf("Waiting for signal for the next interval...")
-		<-self.runner.GetPricingTicker()
+		select {
+		case <-done:
+			inFlight = false
+			if queued {
+				self.logger.Infof("Running the queued cycle")
+				queued, inFlight = false, true
+				run()
+			} else {
+				self.logger.Debugf("Waiting for signal for the next interval...")
+			}
+		case <-ticks:
+			if inFlight {
+				queued = self.overlappingTick(queued)
+			} else {
+				inFlight = true
+				run()
+			}
+		case <-elected:
+			if inFlight {
+				// the standby cycle is short, feed right after it
+				self.logger.Infof("Became the leader, feeding after the current cycle")
+				queued = true
+			} else {
+				self.logger.Infof("Became the leader, feeding right away")
+				inFlight = true
+				run()
+			}
+		}
 	}
 }
 
-func NewPriceFeeder(runner Runner, reserve Reserve, prices PriceCorpus) *PriceFeeder {
+func NewPriceFeeder(runner Runner, reserve Reserve, prices PriceCorpus, journal TxJournal, stuckPolicy string, clock clock.Clock) *PriceFeeder {
 	return &PriceFeeder{
 		runner:  runner,
 		reserve: reserve,
 		prices:  prices,
+		journal: journal,
+		nonces:  NewNonceChecker(reserve, prices, journal, stuckPolicy, clock),
+		clock:   clock,
+		alerter: alert.LogAlerter{},
+		retry:   DefaultRetryPolicy(),
+
+		logger:     logging.Std(),
+		tickPolicy: TICK_POLICY_SKIP,
+		supersede:  make(chan struct{}, 1),
+
+		pollInterval: 10 * time.Second,
+		txWaitTime:   time.Duration(TX_WAIT_TIME) * time.Second,
 	}
 }
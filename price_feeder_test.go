@@ -0,0 +1,657 @@
+package dgxpricing
+
+import (
+	"context"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/KyberNetwork/dgx-price-feeder/broadcast"
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/feed"
+	"github.com/KyberNetwork/dgx-price-feeder/history"
+	"github.com/KyberNetwork/dgx-price-feeder/journal"
+	"github.com/KyberNetwork/dgx-price-feeder/runner"
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+const GWEI int64 = 1000000000
+
+type harness struct {
+	backend *simulation.Backend
+	journal *journal.FileJournal
+	feeder  *PriceFeeder
+}
+
+// newHarness returns a feeder wired to a simulated chain and feed
+// server, with short polling and waiting times
+func newHarness(t *testing.T, stuckPolicy string) *harness {
+	h := newHarnessWithClock(t, stuckPolicy, clock.NewRealClock())
+	h.feeder.pollInterval = 20 * time.Millisecond
+	h.feeder.txWaitTime = 300 * time.Millisecond
+	h.feeder.retry = RetryPolicy{NO_RETRY, 10 * time.Millisecond, 40 * time.Millisecond, 0}
+	return h
+}
+
+// newHarnessWithClock returns a feeder with the production polling and
+// waiting times, driven by clk
+func newHarnessWithClock(t *testing.T, stuckPolicy string, clk clock.Clock) *harness {
+	return newHarnessFor(t, simulation.NewBackend(), stuckPolicy, clk)
+}
+
+func newHarnessFor(t *testing.T, backend *simulation.Backend, stuckPolicy string, clk clock.Clock) *harness {
+	txJournal := journal.NewFileJournal(filepath.Join(backend.Dir, "txs.journal"), clk)
+	corpus := feed.NewFeedCorpus(backend.Feeds.URL(), backend.Feeds.Signer())
+	feeder := NewPriceFeeder(runner.NewTickerRunner(time.Hour, clk), backend.Reserve, corpus, txJournal, stuckPolicy, clk)
+	return &harness{backend, txJournal, feeder}
+}
+
+func (self *harness) Close() {
+	self.backend.Close()
+}
+
+type cycleResult struct {
+	tx     *types.Transaction
+	status string
+	err    error
+}
+
+// wait runs f and fails the test if it doesn't return in time
+func wait(t *testing.T, f func() (*types.Transaction, string, error)) (*types.Transaction, string, error) {
+	done := make(chan cycleResult, 1)
+	go func() {
+		tx, status, err := f()
+		done <- cycleResult{tx, status, err}
+	}()
+	select {
+	case r := <-done:
+		return r.tx, r.status, r.err
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected the cycle to finish in 10s")
+	}
+	return nil, "", nil
+}
+
+// waitAdvancing runs f like wait, calling onPoll and moving clk by the
+// poll interval every time the feeder waits on clk
+func (self *harness) waitAdvancing(t *testing.T, clk *clock.FakeClock, onPoll func(), f func() (*types.Transaction, string, error)) (*types.Transaction, string, error) {
+	done := make(chan cycleResult, 1)
+	go func() {
+		tx, status, err := f()
+		done <- cycleResult{tx, status, err}
+	}()
+	deadline := time.After(10 * time.Second)
+	for {
+		// the last one is left blocked once f returns
+		waiting := make(chan struct{})
+		go func() {
+			clk.BlockUntil(1)
+			close(waiting)
+		}()
+		select {
+		case r := <-done:
+			return r.tx, r.status, r.err
+		case <-waiting:
+			onPoll()
+			clk.Advance(self.feeder.pollInterval)
+		case <-deadline:
+			t.Fatalf("Expected the cycle to finish in 10s")
+			return nil, "", nil
+		}
+	}
+}
+
+func (self *harness) assertOnchainNonce(t *testing.T, expected *big.Int) {
+	_, nonce, _, _, err := self.feeder.reserve.CurrentFeed()
+	if err != nil {
+		t.Fatalf("Expected to get the on-chain feed but got error: %v", err)
+	}
+	if nonce.Cmp(expected) != 0 {
+		t.Fatalf("Expected on-chain feed nonce %s, got %s", expected, nonce)
+	}
+}
+
+func TestFeedOnceMined(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if tx.GasPrice().Int64() != INIT_GASPRICE {
+		t.Fatalf("Expected gas price %d, got %s", INIT_GASPRICE, tx.GasPrice())
+	}
+	if !h.journal.Known(tx.Hash()) {
+		t.Fatalf("Expected tx %s to be journaled", tx.Hash().Hex())
+	}
+	h.assertOnchainNonce(t, big.NewInt(1523036544))
+}
+
+func TestFeedOnceWhileFirstNodeIsDown(t *testing.T) {
+	h := newHarnessFor(t, simulation.NewBackendWithNodes(2), STUCK_POLICY_REPORT, clock.NewRealClock())
+	defer h.Close()
+	h.feeder.pollInterval = 20 * time.Millisecond
+	h.backend.Nodes[0].SetDown(true)
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	// the nonce, the gas estimate and the statuses come from the second
+	// node
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined through the second node, got status %s, err %v", status, err)
+	}
+	if tx.Nonce() != 0 {
+		t.Fatalf("Expected the feed at nonce 0, got %d", tx.Nonce())
+	}
+	h.assertOnchainNonce(t, big.NewInt(1523036544))
+}
+
+func TestFeedOnceDelayedMining(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	h := newHarnessWithClock(t, STUCK_POLICY_REPORT, clk)
+	defer h.Close()
+	// mined at the third poll, long before the tx is replaced
+	polls := 0
+	mineAtThirdPoll := func() {
+		if polls++; polls == 3 {
+			h.backend.Chain.Mine()
+		}
+	}
+
+	tx, status, err := h.waitAdvancing(t, clk, mineAtThirdPoll, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if tx.GasPrice().Int64() != INIT_GASPRICE {
+		t.Fatalf("Expected the tx not to be replaced, got gas price %s", tx.GasPrice())
+	}
+}
+
+func TestFeedOnceDroppedTx(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	h.backend.Chain.DropIncoming(1)
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the dropped feed to be rebroadcasted and mined, got status %s, err %v", status, err)
+	}
+	if tx.GasPrice().Int64() != INIT_GASPRICE {
+		t.Fatalf("Expected the same tx to be rebroadcasted, got gas price %s", tx.GasPrice())
+	}
+}
+
+func TestFeedOnceReverted(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	// a feeder configured with the wrong Digix signer passes the feed
+	// verification, but the reserve rejects it
+	impostor := simulation.NewFeedServer(h.backend.AdminKey, h.backend.Chain)
+	defer impostor.Close()
+	h.feeder.prices = feed.NewFeedCorpus(impostor.URL(), impostor.Signer())
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	_, status, err := wait(t, h.feeder.FeedOnce)
+	if err == nil || !strings.Contains(err.Error(), "reverted") || status != "failed" {
+		t.Fatalf("Expected the feeder to give up on the reverted feed, got status %s, err %v", status, err)
+	}
+	h.assertOnchainNonce(t, big.NewInt(0))
+}
+
+func TestFeedOnceGasBump(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	h.backend.Chain.SetMinGasPrice(big.NewInt(10 * GWEI))
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the replacement to be mined, got status %s, err %v", status, err)
+	}
+	expected := INIT_GASPRICE + GASPRICE_STEP
+	if tx.GasPrice().Int64() != expected {
+		t.Fatalf("Expected the mined tx to have gas price %d, got %s", expected, tx.GasPrice())
+	}
+	h.assertOnchainNonce(t, big.NewInt(1523036544))
+}
+
+func TestReplacementAfterTenMinutes(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	h := newHarnessWithClock(t, STUCK_POLICY_REPORT, clk)
+	defer h.Close()
+	h.backend.Chain.SetMinGasPrice(big.NewInt(10 * GWEI))
+
+	done := make(chan cycleResult, 1)
+	go func() {
+		tx, status, err := h.feeder.FeedOnce()
+		done <- cycleResult{tx, status, err}
+	}()
+	// the monitor polls every 10s and replaces the tx once it has been
+	// pending for more than 10 minutes
+	for elapsed := time.Duration(0); elapsed <= time.Duration(TX_WAIT_TIME)*time.Second; elapsed += 10 * time.Second {
+		clk.BlockUntil(1)
+		if txs := h.journal.TxsAtNonce(h.backend.Operator(), 0); len(txs) != 1 {
+			t.Fatalf("Expected no replacement after %s, got %d txs", elapsed, len(txs))
+		}
+		clk.Advance(10 * time.Second)
+	}
+	clk.BlockUntil(1)
+	txs := h.journal.TxsAtNonce(h.backend.Operator(), 0)
+	if len(txs) != 2 {
+		t.Fatalf("Expected a replacement after %ds, got %d txs", TX_WAIT_TIME+10, len(txs))
+	}
+	// the journal doesn't keep txs in order
+	replacement := txs[0]
+	if txs[1].GasPrice().Cmp(replacement.GasPrice()) > 0 {
+		replacement = txs[1]
+	}
+	if replacement.GasPrice().Int64() != INIT_GASPRICE+GASPRICE_STEP {
+		t.Fatalf("Expected the replacement to have gas price %d, got %s", INIT_GASPRICE+GASPRICE_STEP, replacement.GasPrice())
+	}
+
+	h.backend.Chain.Mine()
+	clk.Advance(10 * time.Second)
+	select {
+	case r := <-done:
+		if r.err != nil || r.status != "mined" || r.tx.Hash() != replacement.Hash() {
+			t.Fatalf("Expected the replacement to be mined, got status %s, err %v", r.status, r.err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected the cycle to finish after the replacement is mined")
+	}
+}
+
+func TestReplacementWaitsBeforeItIsReplaced(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	h := newHarnessWithClock(t, STUCK_POLICY_REPORT, clk)
+	defer h.Close()
+	// only the second replacement pays enough
+	h.backend.Chain.SetMinGasPrice(big.NewInt(INIT_GASPRICE + 2*GASPRICE_STEP))
+
+	done := make(chan cycleResult, 1)
+	go func() {
+		tx, status, err := h.feeder.FeedOnce()
+		done <- cycleResult{tx, status, err}
+	}()
+	// every tx, replacements included, waits 10 minutes before it is
+	// replaced
+	for replacements := 1; replacements <= 2; replacements++ {
+		for elapsed := time.Duration(0); elapsed <= time.Duration(TX_WAIT_TIME)*time.Second; elapsed += 10 * time.Second {
+			clk.BlockUntil(1)
+			if txs := h.journal.TxsAtNonce(h.backend.Operator(), 0); len(txs) != replacements {
+				t.Fatalf("Expected %d txs after %s, got %d", replacements, elapsed, len(txs))
+			}
+			clk.Advance(10 * time.Second)
+		}
+	}
+	clk.BlockUntil(1)
+	if txs := h.journal.TxsAtNonce(h.backend.Operator(), 0); len(txs) != 3 {
+		t.Fatalf("Expected a second replacement, got %d txs", len(txs))
+	}
+
+	h.backend.Chain.Mine()
+	clk.Advance(10 * time.Second)
+	select {
+	case r := <-done:
+		if r.err != nil || r.status != "mined" || r.tx.GasPrice().Int64() != INIT_GASPRICE+2*GASPRICE_STEP {
+			t.Fatalf("Expected the second replacement to be mined, got status %s, err %v", r.status, r.err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Expected the cycle to finish after the replacement is mined")
+	}
+}
+
+func TestFeedOnceCancelsUnknownPendingTx(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_CANCEL)
+	defer h.Close()
+	// a tx left by a previous run which is not in the journal
+	operator := h.backend.Operator()
+	stuck := types.NewTransaction(0, operator, big.NewInt(0), big.NewInt(21000), big.NewInt(GWEI), nil)
+	stuck, err := types.SignTx(stuck, types.HomesteadSigner{}, h.backend.OperatorKey)
+	if err != nil {
+		t.Fatalf("Expected to sign the stuck tx but got error: %v", err)
+	}
+	if err := h.backend.Chain.SendTransaction(stuck); err != nil {
+		t.Fatalf("Expected to send the stuck tx but got error: %v", err)
+	}
+	h.backend.Chain.SetMinGasPrice(big.NewInt(2 * GWEI))
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if tx.Nonce() != 1 {
+		t.Fatalf("Expected the feed to be sent after the cancel at nonce 1, got %d", tx.Nonce())
+	}
+	if _, _, _, found := h.backend.Chain.Receipt(stuck.Hash()); found {
+		t.Fatalf("Expected the stuck tx to be replaced")
+	}
+}
+
+func TestFeedOnceCancelsStaleJournaledTx(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	h := newHarnessWithClock(t, STUCK_POLICY_CANCEL, clk)
+	defer h.Close()
+	// a feed sent by a previous run whose gas price is too low to be mined
+	h.backend.Chain.SetMinGasPrice(big.NewInt(10 * GWEI))
+	stuck, err := h.feeder.TryFeedingPrice()
+	if err != nil {
+		t.Fatalf("Expected to send the feed but got error: %v", err)
+	}
+	report, err := h.feeder.nonces.Check(context.Background(), h.feeder.txWaitTime)
+	if err != nil || report.Stuck() {
+		t.Fatalf("Expected a fresh journaled tx not to be stuck, got %+v, err %v", report, err)
+	}
+	clk.Advance(h.feeder.txWaitTime + time.Second)
+	report, err = h.feeder.nonces.Check(context.Background(), h.feeder.txWaitTime)
+	if err != nil {
+		t.Fatalf("Expected to check nonces but got error: %v", err)
+	}
+	if len(report.Stale) != 1 || report.Stale[0].Hash() != stuck.Hash() {
+		t.Fatalf("Expected the journaled tx to be stale, got %+v", report)
+	}
+
+	tx, status, err := h.waitAdvancing(t, clk, h.backend.Chain.Mine, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if tx.Nonce() != 1 {
+		t.Fatalf("Expected the feed to be sent after the cancel at nonce 1, got %d", tx.Nonce())
+	}
+	if _, _, _, found := h.backend.Chain.Receipt(stuck.Hash()); found {
+		t.Fatalf("Expected the stale tx to be replaced")
+	}
+}
+
+func TestCancelPendingFeed(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	feedTx, err := h.feeder.TryFeedingPrice()
+	if err != nil {
+		t.Fatalf("Expected to send the feed but got error: %v", err)
+	}
+	cancelTx, err := h.feeder.Cancel(nil)
+	if err != nil {
+		t.Fatalf("Expected to cancel but got error: %v", err)
+	}
+	if cancelTx.Nonce() != feedTx.Nonce() || cancelTx.GasPrice().Cmp(feedTx.GasPrice()) <= 0 {
+		t.Fatalf("Expected the cancel to replace the feed at nonce %d with higher gas price", feedTx.Nonce())
+	}
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	_, status, err := wait(t, func() (*types.Transaction, string, error) { return h.feeder.MonitorAndRetry(cancelTx) })
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the cancel to be mined, got status %s, err %v", status, err)
+	}
+	_, status, err = wait(t, func() (*types.Transaction, string, error) { return h.feeder.MonitorAndRetry(feedTx) })
+	if err != nil || status != "replaced" {
+		t.Fatalf("Expected the feed to be replaced, got status %s, err %v", status, err)
+	}
+	h.assertOnchainNonce(t, big.NewInt(0))
+}
+
+func TestCancelRefusedByFollower(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	feedTx, err := h.feeder.TryFeedingPrice()
+	if err != nil {
+		t.Fatalf("Expected to send the feed but got error: %v", err)
+	}
+	h.feeder.SetLeadership(&fakeLeadership{elected: make(chan struct{}, 1)})
+
+	if _, err := h.feeder.Cancel(nil); err != ErrNotLeader {
+		t.Fatalf("Expected the cancel to be refused by a follower, got %v", err)
+	}
+	if txs := h.journal.TxsAtNonce(h.backend.Operator(), feedTx.Nonce()); len(txs) != 1 {
+		t.Fatalf("Expected only the feed at nonce %d, got %d txs", feedTx.Nonce(), len(txs))
+	}
+}
+
+func TestCancelRejectedByEveryNodeIsNotJournaled(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	feedTx, err := h.feeder.TryFeedingPrice()
+	if err != nil {
+		t.Fatalf("Expected to send the feed but got error: %v", err)
+	}
+	operator := h.backend.Operator()
+	h.backend.Chain.Fund(operator, new(big.Int).Neg(h.backend.Chain.Balance(operator)))
+
+	cancelTx, err := h.feeder.Cancel(nil)
+	if result := broadcast.ResultOf(err); result == nil || !result.Rejected(broadcast.INSUFFICIENT_FUNDS) {
+		t.Fatalf("Expected the cancel to be rejected for insufficient funds, got %v", err)
+	}
+	if cancelTx != nil {
+		t.Fatalf("Expected no cancel to monitor, got %s", cancelTx.Hash().Hex())
+	}
+	if txs := h.journal.TxsAtNonce(operator, feedTx.Nonce()); len(txs) != 1 {
+		t.Fatalf("Expected only the feed at nonce %d to be journaled, got %d txs", feedTx.Nonce(), len(txs))
+	}
+}
+
+func TestFeedOnceFollowsNewHeads(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	// statuses are only looked up on new blocks
+	h.feeder.pollInterval = time.Hour
+	h.feeder.SetHeadSource(h.backend.Reserve.HeadWatcher(h.backend.Node.WSURL()))
+	h.backend.Chain.SetMinGasPrice(big.NewInt(10 * GWEI))
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the replacement to be mined, got status %s, err %v", status, err)
+	}
+	if tx.GasPrice().Int64() != INIT_GASPRICE+GASPRICE_STEP {
+		t.Fatalf("Expected the mined tx to have gas price %d, got %s", INIT_GASPRICE+GASPRICE_STEP, tx.GasPrice())
+	}
+}
+
+func TestFeedOncePollsNewBlocksWithoutWebsocket(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	client, err := rpc.Dial(h.backend.Node.URL())
+	if err != nil {
+		t.Fatalf("Expected to dial the node but got error: %v", err)
+	}
+	h.feeder.pollInterval = time.Hour
+	h.feeder.SetHeadSource(blockchain.NewHeadWatcher(client, "", 20*time.Millisecond))
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	_, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+}
+
+func TestFeedOnceGivesUpWithoutFunds(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	operator := h.backend.Operator()
+	h.backend.Chain.Fund(operator, new(big.Int).Neg(h.backend.Chain.Balance(operator)))
+
+	_, _, err := wait(t, h.feeder.FeedOnce)
+	result := broadcast.ResultOf(err)
+	if result == nil || !result.Rejected(broadcast.INSUFFICIENT_FUNDS) {
+		t.Fatalf("Expected the feed to be rejected for insufficient funds, got %v", err)
+	}
+	// every node rejected the tx so it is not journaled
+	if nonces := h.journal.PendingNonces(h.backend.Operator()); len(nonces) != 0 {
+		t.Fatalf("Expected no pending tx in the journal, got nonces %v", nonces)
+	}
+}
+
+func (self *harness) enablePrivateRelay(t *testing.T, blocks uint64) {
+	relay, err := broadcast.NewRelay(self.backend.Relay.URL())
+	if err != nil {
+		t.Fatalf("Expected to dial the relay but got error: %v", err)
+	}
+	self.backend.Reserve.EnablePrivateRelay(relay, blocks)
+}
+
+func TestFeedOnceThroughPrivateRelay(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	h.enablePrivateRelay(t, 3)
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+	public := metrics.GetOrRegisterCounter("feeder.broadcast.accepted", nil).Count()
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if received := h.backend.Relay.Received(); len(received) != 1 || received[0].Hash() != tx.Hash() {
+		t.Fatalf("Expected the relay to receive the feed only, got %d txs", len(received))
+	}
+	if count := metrics.GetOrRegisterCounter("feeder.broadcast.accepted", nil).Count(); count != public {
+		t.Fatalf("Expected the feed not to be broadcasted publicly")
+	}
+	if n := h.backend.Reserve.PrivateTxs(); n != 0 {
+		t.Fatalf("Expected the mined feed not to be tracked as private anymore, got %d txs", n)
+	}
+}
+
+func TestPrivateRelayFallsBackToPublic(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	h.enablePrivateRelay(t, 3)
+	h.backend.Relay.SetIncluding(false)
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+	public := metrics.GetOrRegisterCounter("feeder.broadcast.accepted", nil).Count()
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if received := h.backend.Relay.Received(); len(received) != 1 || received[0].Hash() != tx.Hash() {
+		t.Fatalf("Expected the relay to receive the feed first, got %d txs", len(received))
+	}
+	if count := metrics.GetOrRegisterCounter("feeder.broadcast.accepted", nil).Count(); count != public+1 {
+		t.Fatalf("Expected the feed to be broadcasted publicly once, got %d", count-public)
+	}
+	if n := h.backend.Reserve.PrivateTxs(); n != 0 {
+		t.Fatalf("Expected the feed broadcasted publicly not to be tracked as private anymore, got %d txs", n)
+	}
+}
+
+type fakeLeadership struct {
+	mu      sync.Mutex
+	leader  bool
+	elected chan struct{}
+}
+
+func (self *fakeLeadership) IsLeader() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.leader
+}
+
+func (self *fakeLeadership) Elected() <-chan struct{} {
+	return self.elected
+}
+
+func (self *fakeLeadership) elect() {
+	self.mu.Lock()
+	self.leader = true
+	self.mu.Unlock()
+	self.elected <- struct{}{}
+}
+
+func (self *fakeLeadership) lose() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.leader = false
+}
+
+func TestLeaderWhichLostTheLeaseDoesNotReplace(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	h := newHarnessWithClock(t, STUCK_POLICY_REPORT, clk)
+	defer h.Close()
+	leadership := &fakeLeadership{leader: true, elected: make(chan struct{}, 1)}
+	h.feeder.SetLeadership(leadership)
+
+	// another instance takes over while the feed is pending, which is
+	// never mined
+	tx, status, err := h.waitAdvancing(t, clk, leadership.lose, h.feeder.FeedOnce)
+	if err != ErrNotLeader || status != "pending" {
+		t.Fatalf("Expected to stop monitoring without replacing, got status %s, err %v", status, err)
+	}
+	if tx.GasPrice().Int64() != INIT_GASPRICE {
+		t.Fatalf("Expected the feed not to be replaced, got gas price %s", tx.GasPrice())
+	}
+}
+
+func TestStandbyTakesOverWhenElected(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	h := newHarnessWithClock(t, STUCK_POLICY_REPORT, clk)
+	defer h.Close()
+	leadership := &fakeLeadership{elected: make(chan struct{}, 1)}
+	h.feeder.SetLeadership(leadership)
+	lines := h.logLines()
+	_, before, _, _, err := h.feeder.reserve.CurrentFeed()
+	if err != nil {
+		t.Fatalf("Expected to get the on-chain feed but got error: %v", err)
+	}
+
+	go h.feeder.Run()
+	defer h.feeder.Stop()
+	// the standby fetches the feed but doesn't send it
+	waitForLog(t, lines, "Standby: feed is valid")
+	if txs := h.backend.Chain.PendingTxs(h.backend.Operator()); len(txs) != 0 {
+		t.Fatalf("Expected the standby not to send any tx, got %d", len(txs))
+	}
+	h.assertOnchainNonce(t, before)
+
+	// the ticker never ticks as the clock doesn't move, the feed must
+	// follow the election. Its tx is sent once the cycle waits to poll it.
+	leadership.elect()
+	clk.BlockUntil(1)
+	h.backend.Chain.Mine()
+	if _, nonce, _, _, err := h.feeder.reserve.CurrentFeed(); err != nil || nonce.Cmp(before) <= 0 {
+		t.Fatalf("Expected the feed to be mined after the election, got nonce %s, err %v", nonce, err)
+	}
+}
+
+func TestHistoryKeepsFeedsAndReceipts(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	store := history.NewStore(filepath.Join(h.backend.Dir, "history.db"), clock.NewRealClock())
+	h.feeder.SetHistory(store)
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err: %v", status, err)
+	}
+	h.backend.Feeds.SetDown(true)
+	if _, _, err := wait(t, h.feeder.FeedOnce); err == nil {
+		t.Fatalf("Expected the feed to fail while the endpoint is down")
+	}
+
+	feeds, err := store.Feeds(history.Filter{})
+	if err != nil || len(feeds) != 1+NO_RETRY {
+		t.Fatalf("Expected the mined feed and %d failed fetches, got %d, err: %v", NO_RETRY, len(feeds), err)
+	}
+	submitted := feeds[0]
+	if submitted.Decision != history.DECISION_SUBMITTED || !submitted.Verified || *submitted.Tx != tx.Hash() || submitted.Raw == "" {
+		t.Fatalf("Expected the first feed to be verified and submitted with tx %s, got %+v", tx.Hash().Hex(), submitted)
+	}
+	if feeds[1].Decision != history.DECISION_REJECTED || feeds[1].Reason == "" {
+		t.Fatalf("Expected the second feed to be rejected with a reason, got %+v", feeds[1])
+	}
+	txs, err := store.Txs(history.Filter{Outcome: "mined"})
+	if err != nil || len(txs) != 1 || txs[0].Hash != tx.Hash() || txs[0].Block == 0 || txs[0].GasUsed == 0 {
+		t.Fatalf("Expected tx %s to be mined with its receipt, got %+v, err: %v", tx.Hash().Hex(), txs, err)
+	}
+}
@@ -0,0 +1,71 @@
+package dgxpricing
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/broadcast"
+	"github.com/KyberNetwork/dgx-price-feeder/feed"
+)
+
+const (
+	RETRY_INITIAL_DELAY time.Duration = 5 * time.Second
+	RETRY_MAX_DELAY     time.Duration = 2 * time.Minute
+	// RETRY_JITTER is the fraction of a delay which is randomized so
+	// feeders don't retry in lockstep
+	RETRY_JITTER float64 = 0.2
+)
+
+// RetryPolicy tells how many times a feed is tried and how long to wait
+// between tries
+type RetryPolicy struct {
+	Attempts     int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Jitter       float64
+}
+
+// DefaultRetryPolicy tries NO_RETRY times, waiting 5s, 10s, 20s... up to
+// 2 minutes between tries
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{NO_RETRY, RETRY_INITIAL_DELAY, RETRY_MAX_DELAY, RETRY_JITTER}
+}
+
+// Delay returns how long to wait after the attempt-th failed try,
+// starting at 1: the initial delay doubled after every try up to the max
+// delay, give or take the jitter
+func (self RetryPolicy) Delay(attempt int) time.Duration {
+	delay := self.InitialDelay
+	for i := 1; i < attempt && delay < self.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > self.MaxDelay {
+		delay = self.MaxDelay
+	}
+	if self.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * self.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// Retryable tells if a failed try can succeed with a fresh feed. It can't
+// when the pricing operator can't pay for the tx or isn't an operator of
+// the reserve, its nonce is already used, the feed isn't signed by Digix,
+// the gas budget is exceeded or the instance isn't the leader. Every
+// other error, ie an endpoint being down, an invalid feed or a rejected
+// tx, might be gone on the next try.
+func Retryable(err error) bool {
+	if IsBudgetError(err) || IsOperatorError(err) || feed.IsSignatureError(err) || err == ErrNotLeader {
+		return false
+	}
+	if result := broadcast.ResultOf(err); result != nil &&
+		(result.Rejected(broadcast.INSUFFICIENT_FUNDS) || result.Rejected(broadcast.NONCE_TOO_LOW)) {
+		return false
+	}
+	return true
+}
+
+// SetRetryPolicy sets how feeds are retried, DefaultRetryPolicy by default
+func (self *PriceFeeder) SetRetryPolicy(policy RetryPolicy) {
+	self.retry = policy
+}
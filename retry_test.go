@@ -0,0 +1,135 @@
+package dgxpricing
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/alert"
+	"github.com/KyberNetwork/dgx-price-feeder/broadcast"
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/feed"
+	"github.com/KyberNetwork/dgx-price-feeder/history"
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+)
+
+func TestRetryDelay(t *testing.T) {
+	policy := RetryPolicy{NO_RETRY, time.Second, 5 * time.Second, 0}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, delay := range expected {
+		if got := policy.Delay(i + 1); got != delay {
+			t.Fatalf("Expected a delay of %s after try %d, got %s", delay, i+1, got)
+		}
+	}
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.Delay(2); got < time.Second || got > 3*time.Second {
+			t.Fatalf("Expected a delay of 2s give or take 50%%, got %s", got)
+		}
+	}
+}
+
+func TestGivesUpAfterRetries(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	h := newHarnessWithClock(t, STUCK_POLICY_REPORT, clk)
+	defer h.Close()
+	alerter := &recordingAlerter{}
+	h.feeder.SetAlerter(alerter)
+	store := history.NewStore(filepath.Join(h.backend.Dir, "history.db"), clk)
+	h.feeder.SetHistory(store)
+	h.feeder.SetRetryPolicy(RetryPolicy{4, 10 * time.Second, 30 * time.Second, 0})
+	h.backend.Feeds.SetDown(true)
+
+	start := clk.Now()
+	done := make(chan cycleResult, 1)
+	go func() {
+		tx, status, err := h.feeder.FeedOnce()
+		done <- cycleResult{tx, status, err}
+	}()
+	r := runUntilDone(t, clk, done)
+	if r.err == nil || !strings.Contains(r.err.Error(), "after 4 tries") {
+		t.Fatalf("Expected the feeder to give up after 4 tries, got err %v", r.err)
+	}
+	if elapsed := clk.Now().Sub(start); elapsed != 60*time.Second {
+		t.Fatalf("Expected to wait 10s, 20s then 30s between tries, waited %s", elapsed)
+	}
+	if feeds, err := store.Feeds(history.Filter{}); err != nil || len(feeds) != 4 {
+		t.Fatalf("Expected a fresh feed to be fetched for every try, got %d feeds, err %v", len(feeds), err)
+	}
+	if alerts := alerter.take(); len(alerts) != 1 || alerts[0].level != alert.CRITICAL {
+		t.Fatalf("Expected a critical alert, got %v", alerts)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	rejected := func(outcome string) error {
+		return (&broadcast.Result{Endpoints: []broadcast.EndpointResult{{Outcome: outcome}}}).Err()
+	}
+	permanent := []error{
+		&BudgetError{"over"},
+		ErrNotLeader,
+		&OperatorError{},
+		&feed.SignatureError{Reason: "feed is signed by someone else"},
+		rejected(broadcast.INSUFFICIENT_FUNDS),
+		rejected(broadcast.NONCE_TOO_LOW),
+	}
+	for _, err := range permanent {
+		if Retryable(err) {
+			t.Fatalf("Expected %v not to be retried", err)
+		}
+	}
+	for _, err := range []error{errors.New("endpoint is down"), rejected(broadcast.UNDERPRICED), rejected(broadcast.UNREACHABLE)} {
+		if !Retryable(err) {
+			t.Fatalf("Expected %v to be retried", err)
+		}
+	}
+}
+
+func TestGivesUpWhenOperatorIsRevoked(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	alerter := &recordingAlerter{}
+	h.feeder.SetAlerter(alerter)
+	guard := NewOperatorGuard(h.backend.Reserve, alerter, clock.NewRealClock())
+	h.feeder.SetOperatorGuard(guard)
+	h.feeder.SetRetryPolicy(RetryPolicy{3, 10 * time.Second, 10 * time.Second, 0})
+	h.backend.Chain.WithReserve(func(reserve *simulation.Reserve) { reserve.RemoveOperator(h.backend.Operator()) })
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	_, status, err := wait(t, h.feeder.FeedOnce)
+	if !IsOperatorError(err) || status != "failed" {
+		t.Fatalf("Expected the feeder to give up as the operator is revoked, got status %s, err %v", status, err)
+	}
+	if txs := h.journal.TxsAtNonce(h.backend.Operator(), 0); len(txs) != 1 {
+		t.Fatalf("Expected a single try, got %d txs", len(txs))
+	}
+	// the guard alerts the lost role, the give up isn't alerted again
+	if alerts := alerter.take(); len(alerts) != 1 || alerts[0].level != alert.CRITICAL {
+		t.Fatalf("Expected a critical alert, got %v", alerts)
+	}
+}
+
+func TestMonitorsFeedWhichMightBeInPool(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	// the node takes the feed tx but its answer never arrives, the
+	// broadcast finds it unreachable
+	h.backend.Node.LoseSendAnswers(1)
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be monitored until it is mined, got status %s, err %v", status, err)
+	}
+	if tx.Nonce() != 0 || tx.GasPrice().Int64() != INIT_GASPRICE {
+		t.Fatalf("Expected the first feed tx to be mined, got nonce %d, gas price %s", tx.Nonce(), tx.GasPrice())
+	}
+	if mined, err := h.feeder.reserve.MinedNonce(); err != nil || mined != 1 {
+		t.Fatalf("Expected no other feed to be sent, got mined nonce %d, err %v", mined, err)
+	}
+	if txs := h.journal.TxsAtNonce(h.backend.Operator(), 1); len(txs) != 0 {
+		t.Fatalf("Expected no fresh feed to be tried, got %d txs", len(txs))
+	}
+}
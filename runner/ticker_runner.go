@@ -2,35 +2,39 @@ package runner
 
 import (
 	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
 )
 
 type TickerRunner struct {
 	duration time.Duration
-	clock    *time.Ticker
+	clock    clock.Clock
+	ticker   clock.Ticker
 	signal   chan bool
 }
 
 func (self *TickerRunner) GetPricingTicker() <-chan time.Time {
-	if self.clock == nil {
+	if self.ticker == nil {
 		<-self.signal
 	}
-	return self.clock.C
+	return self.ticker.C()
 }
 
 func (self *TickerRunner) Start() error {
-	self.clock = time.NewTicker(self.duration)
+	self.ticker = self.clock.NewTicker(self.duration)
 	self.signal <- true
 	return nil
 }
 
 func (self *TickerRunner) Stop() error {
-	self.clock.Stop()
+	self.ticker.Stop()
 	return nil
 }
 
-func NewTickerRunner(duration time.Duration) *TickerRunner {
+func NewTickerRunner(duration time.Duration, clock clock.Clock) *TickerRunner {
 	return &TickerRunner{
 		duration,
+		clock,
 		nil,
 		make(chan bool, 1),
 	}
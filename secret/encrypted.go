@@ -0,0 +1,117 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt parameters deriving the file key from the master key, the same
+// as the standard parameters of geth keystores
+const (
+	SCRYPT_N      int = 1 << 18
+	SCRYPT_R      int = 8
+	SCRYPT_P      int = 1
+	SCRYPT_KEYLEN int = 32
+)
+
+// encryptedFile is the json content of an encrypted secret file, the
+// secret is sealed with AES-256-GCM under a key derived with scrypt
+// from the master key and salt
+type encryptedFile struct {
+	KDF        string        `json:"kdf"`
+	N          int           `json:"n"`
+	Salt       hexutil.Bytes `json:"salt"`
+	Nonce      hexutil.Bytes `json:"nonce"`
+	Ciphertext hexutil.Bytes `json:"ciphertext"`
+}
+
+func newGCM(masterKey []byte, salt []byte, n int) (cipher.AEAD, error) {
+	key, err := scrypt.Key(masterKey, salt, n, SCRYPT_R, SCRYPT_P, SCRYPT_KEYLEN)
+	if err != nil {
+		return nil, err
+	}
+	defer Zero(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt seals secret with masterKey in the format read by
+// EncryptedFileSource
+func Encrypt(secret []byte, masterKey []byte) ([]byte, error) {
+	return encrypt(secret, masterKey, SCRYPT_N)
+}
+
+func encrypt(secret []byte, masterKey []byte, n int) ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(masterKey, salt, n)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(encryptedFile{
+		KDF:        "scrypt",
+		N:          n,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, secret, nil),
+	}, "", "  ")
+}
+
+// EncryptedFileSource reads the secret from a file written by Encrypt,
+// unlocked by the master key read from MasterKey
+type EncryptedFileSource struct {
+	Path      string
+	MasterKey Source
+}
+
+func (self *EncryptedFileSource) Read() ([]byte, error) {
+	data, err := ioutil.ReadFile(self.Path)
+	if err != nil {
+		return nil, err
+	}
+	file := encryptedFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid encrypted secret: %s", err))
+	}
+	if file.KDF != "scrypt" {
+		return nil, errors.New(fmt.Sprintf("unsupported kdf %s", file.KDF))
+	}
+	masterKey, err := self.MasterKey.Read()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Reading master key from %s failed: %s", self.MasterKey, err))
+	}
+	defer Zero(masterKey)
+	gcm, err := newGCM(masterKey, file.Salt, file.N)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce in encrypted secret")
+	}
+	secret, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("decrypting secret failed, wrong master key?")
+	}
+	return secret, nil
+}
+
+func (self *EncryptedFileSource) String() string {
+	return fmt.Sprintf("encrypted file %s (master key from %s)", self.Path, self.MasterKey)
+}
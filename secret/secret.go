@@ -0,0 +1,196 @@
+// Package secret reads secrets like the keystore passphrase from
+// pluggable sources. Secrets are kept as byte slices so callers can
+// zero them once used.
+package secret
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// how surrounding whitespace of a secret is trimmed
+const (
+	// keep the secret as is
+	TRIM_NONE string = "none"
+	// remove one trailing new line (\n or \r\n), as written by editors
+	// and echo
+	TRIM_NEWLINE string = "newline"
+	// remove all leading and trailing whitespace
+	TRIM_SPACE string = "space"
+
+	// DOCKER_SECRETS_DIR is where docker mounts secrets in containers
+	DOCKER_SECRETS_DIR string = "/run/secrets"
+)
+
+// Source gives a secret. The caller owns the returned slice and should
+// Zero it once used.
+type Source interface {
+	Read() ([]byte, error)
+	// String describes the source without revealing the secret
+	String() string
+}
+
+// Zero overwrites b so the secret doesn't stay in memory
+func Zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// Trim returns the part of secret kept by the trim rule, sharing the
+// underlying array of secret
+func Trim(secret []byte, rule string) ([]byte, error) {
+	switch rule {
+	case TRIM_NONE:
+		return secret, nil
+	case TRIM_NEWLINE:
+		if bytes.HasSuffix(secret, []byte("\r\n")) {
+			return secret[:len(secret)-2], nil
+		}
+		return bytes.TrimSuffix(secret, []byte("\n")), nil
+	case TRIM_SPACE:
+		return bytes.TrimSpace(secret), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown trim rule %s, expected none, newline or space", rule))
+	}
+}
+
+// FileSource reads the secret from a file
+type FileSource struct {
+	Path string
+}
+
+func (self *FileSource) Read() ([]byte, error) {
+	return ioutil.ReadFile(self.Path)
+}
+
+func (self *FileSource) String() string {
+	return "file " + self.Path
+}
+
+// NewDockerSecret reads the docker secret name
+func NewDockerSecret(name string) *FileSource {
+	return &FileSource{filepath.Join(DOCKER_SECRETS_DIR, name)}
+}
+
+// EnvSource reads the secret from an environment variable, which is
+// unset once read so child processes don't inherit it
+type EnvSource struct {
+	Name string
+}
+
+func (self *EnvSource) Read() ([]byte, error) {
+	value, ok := os.LookupEnv(self.Name)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("environment variable %s is not set", self.Name))
+	}
+	os.Unsetenv(self.Name)
+	return []byte(value), nil
+}
+
+func (self *EnvSource) String() string {
+	return "environment variable " + self.Name
+}
+
+// FDSource reads the secret from an inherited file descriptor until EOF,
+// ie a pipe set up by the parent process. The descriptor is closed once
+// read.
+type FDSource struct {
+	FD uintptr
+}
+
+func (self *FDSource) Read() ([]byte, error) {
+	f := os.NewFile(self.FD, self.String())
+	if f == nil {
+		return nil, errors.New(fmt.Sprintf("invalid file descriptor %d", self.FD))
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f)
+}
+
+func (self *FDSource) String() string {
+	return fmt.Sprintf("file descriptor %d", self.FD)
+}
+
+// Parse returns the source described by spec:
+//
+//	file:<path>       a file
+//	env:<name>        an environment variable
+//	fd:<number>       an inherited file descriptor
+//	docker:<name>     a docker secret in /run/secrets
+//	tty               prompted on the terminal as name
+//	encrypted:<path>  a file encrypted with the key given by masterKey
+//
+// A spec without prefix is a file path.
+func Parse(name string, spec string, masterKey Source) (Source, error) {
+	if spec == "tty" {
+		return &TTYSource{name + ": "}, nil
+	}
+	kind, value := "file", spec
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, value = spec[:i], spec[i+1:]
+	}
+	switch kind {
+	case "file":
+		return &FileSource{value}, nil
+	case "env":
+		return &EnvSource{value}, nil
+	case "fd":
+		fd, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid file descriptor %s", value))
+		}
+		return &FDSource{uintptr(fd)}, nil
+	case "docker":
+		return NewDockerSecret(value), nil
+	case "encrypted":
+		if masterKey == nil {
+			return nil, errors.New("an encrypted secret needs a master key")
+		}
+		return &EncryptedFileSource{value, masterKey}, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown secret source %s", spec))
+	}
+}
+
+// ReadTrimmed reads the secret of source and trims it with rule. The
+// untrimmed part of the secret is zeroed.
+func ReadTrimmed(source Source, rule string) ([]byte, error) {
+	raw, err := source.Read()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Reading secret from %s failed: %s", source, err))
+	}
+	trimmed, err := Trim(raw, rule)
+	if err != nil {
+		Zero(raw)
+		return nil, err
+	}
+	result := make([]byte, len(trimmed))
+	copy(result, trimmed)
+	Zero(raw)
+	return result, nil
+}
+
+type trimmedSource struct {
+	source Source
+	rule   string
+}
+
+func (self *trimmedSource) Read() ([]byte, error) {
+	return ReadTrimmed(self.source, self.rule)
+}
+
+func (self *trimmedSource) String() string {
+	return self.source.String()
+}
+
+// Trimmed returns a source giving the secret of source trimmed with rule
+func Trimmed(source Source, rule string) Source {
+	return &trimmedSource{source, rule}
+}
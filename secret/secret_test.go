@@ -0,0 +1,87 @@
+package secret
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrim(t *testing.T) {
+	cases := []struct {
+		rule     string
+		expected string
+	}{
+		{TRIM_NONE, " pass word \r\n"},
+		{TRIM_NEWLINE, " pass word "},
+		{TRIM_SPACE, "pass word"},
+	}
+	for _, c := range cases {
+		trimmed, err := Trim([]byte(" pass word \r\n"), c.rule)
+		if err != nil || string(trimmed) != c.expected {
+			t.Fatalf("Expected %q with rule %s, got %q, err(%v)", c.expected, c.rule, trimmed, err)
+		}
+	}
+	if _, err := Trim([]byte("secret"), "all"); err == nil {
+		t.Fatalf("Expected an unknown trim rule to be rejected")
+	}
+}
+
+func TestEnvSourceUnsetsVariable(t *testing.T) {
+	os.Setenv("FEEDER_TEST_SECRET", "secret\n")
+	source, err := Parse("Passphrase", "env:FEEDER_TEST_SECRET", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := ReadTrimmed(source, TRIM_NEWLINE)
+	if err != nil || string(secret) != "secret" {
+		t.Fatalf("Expected to read the secret, got %q, err(%v)", secret, err)
+	}
+	if _, set := os.LookupEnv("FEEDER_TEST_SECRET"); set {
+		t.Fatalf("Expected the variable to be unset once read")
+	}
+}
+
+func TestFDSource(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("secret"))
+	w.Close()
+	secret, err := (&FDSource{r.Fd()}).Read()
+	if err != nil || string(secret) != "secret" {
+		t.Fatalf("Expected to read the secret from the pipe, got %q, err(%v)", secret, err)
+	}
+}
+
+func TestEncryptedFileSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// a cheap kdf so the test is fast
+	data, err := encrypt([]byte(" secret "), []byte("master"), 1<<4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "passphrase.json")
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("FEEDER_TEST_MASTER_KEY", "master")
+	source, err := Parse("Passphrase", "encrypted:"+path, &EnvSource{"FEEDER_TEST_MASTER_KEY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := ReadTrimmed(source, TRIM_NONE)
+	if err != nil || string(secret) != " secret " {
+		t.Fatalf("Expected to decrypt the secret, got %q, err(%v)", secret, err)
+	}
+
+	os.Setenv("FEEDER_TEST_MASTER_KEY", "wrong")
+	if _, err := source.Read(); err == nil {
+		t.Fatalf("Expected decrypting with a wrong master key to fail")
+	}
+}
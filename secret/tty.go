@@ -0,0 +1,53 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TTYSource prompts for the secret on the controlling terminal without
+// echoing it. The secret ends at the first new line, which is removed.
+type TTYSource struct {
+	Prompt string
+}
+
+func (self *TTYSource) Read() ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("no terminal to prompt for the secret: %s", err))
+	}
+	defer tty.Close()
+	fmt.Fprint(tty, self.Prompt)
+	restore, err := disableEcho(tty)
+	if err != nil {
+		return nil, err
+	}
+	defer fmt.Fprintln(tty)
+	defer restore()
+	// read byte by byte so no copy of the secret is left in a buffer
+	b := make([]byte, 1)
+	defer Zero(b)
+	secret := []byte{}
+	for {
+		if n, err := tty.Read(b); err != nil || n == 0 || b[0] == '\n' {
+			break
+		}
+		if len(secret) == cap(secret) {
+			grown := make([]byte, len(secret), 2*cap(secret)+16)
+			copy(grown, secret)
+			Zero(secret)
+			secret = grown
+		}
+		secret = append(secret, b[0])
+	}
+	if len(secret) > 0 && secret[len(secret)-1] == '\r' {
+		secret[len(secret)-1] = 0
+		secret = secret[:len(secret)-1]
+	}
+	return secret, nil
+}
+
+func (self *TTYSource) String() string {
+	return "terminal"
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package secret
+
+import (
+	"errors"
+	"os"
+)
+
+func disableEcho(tty *os.File) (func(), error) {
+	return nil, errors.New("prompting for secrets is only supported on linux")
+}
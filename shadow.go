@@ -0,0 +1,48 @@
+package dgxpricing
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// shadowDecision is the feed a shadow feeder would have sent
+type shadowDecision struct {
+	tx          *types.Transaction
+	blockNumber *big.Int
+	nonce       *big.Int
+	ask         *big.Int
+	bid         *big.Int
+}
+
+// compareWithOnchain reports how the last shadow decision differs from
+// the feed which actually landed on-chain since then
+func (self *PriceFeeder) compareWithOnchain(ctx context.Context) {
+	d := self.lastShadow
+	if d == nil {
+		return
+	}
+	blockno, nonce, ask, bid, err := self.reserve.CurrentFeed()
+	if err != nil {
+		self.log(ctx).Warnf("Shadow mode: getting on-chain feed failed: %s", err)
+		return
+	}
+	if blockno.Cmp(d.blockNumber) == 0 && nonce.Cmp(d.nonce) == 0 && ask.Cmp(d.ask) == 0 && bid.Cmp(d.bid) == 0 {
+		self.log(ctx).Infof("Shadow mode: on-chain feed matches shadow tx %s", d.tx.Hash().Hex())
+		return
+	}
+	shadowDivergences.Inc(1)
+	switch nonce.Cmp(d.nonce) {
+	case -1:
+		self.log(ctx).Warnf("Shadow mode: on-chain feed is older than shadow tx %s", d.tx.Hash().Hex())
+	case 1:
+		self.log(ctx).Warnf("Shadow mode: on-chain feed is newer than shadow tx %s", d.tx.Hash().Hex())
+	default:
+		self.log(ctx).Warnf("Shadow mode: on-chain feed has the same nonce but different values from shadow tx %s", d.tx.Hash().Hex())
+	}
+	self.log(ctx).Infof(
+		"Shadow mode: shadow(block %s, nonce %s, ask %s, bid %s), on-chain(block %s, nonce %s, ask %s, bid %s)",
+		d.blockNumber, d.nonce, d.ask, d.bid, blockno, nonce, ask, bid,
+	)
+}
@@ -0,0 +1,193 @@
+// Package simulation is an in-memory chain served over json rpc for
+// integration tests. The reserve is a Go stand-in of the contract rather
+// than its bytecode on go-ethereum's simulated backend: the vendored
+// go-ethereum only has the packages the feeder links, without the EVM,
+// the state or accounts/abi/bind/backends, and the tree has no compiled
+// reserve. Calls and txs go through the same json rpc, abi packing and
+// signing as in production, but the gas used and the revert conditions
+// are the stand-in's, not the contract's.
+package simulation
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	rsblockchain "github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/KyberNetwork/reserve-data/common/blockchain"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pborman/uuid"
+)
+
+const (
+	START_BLOCK     uint64  = 5392391
+	MAX_BLOCK_DRIFT uint64  = 300
+	PASSPHRASE      string  = "simulation"
+	ETH_USD_RATE    float64 = 500
+)
+
+var RESERVE_ADDRESS = ethereum.HexToAddress("0xce076f8ab3f5af34ecf70b99995b11039190edc1")
+
+type fixedRate float64
+
+func (self fixedRate) GetUSDRate(timepoint uint64) float64 {
+	return float64(self)
+}
+
+// Backend is a simulated chain with the stand-in reserve deployed, a Digix
+// feed server and a DGXReserve whose pricing operator is authorised on
+// the stand-in reserve
+type Backend struct {
+	Chain *Chain
+	// Node is the first of Nodes, all nodes serve the same chain
+	Node  *Node
+	Nodes []*Node
+	Pool  *rsblockchain.ClientPool
+	Feeds *FeedServer
+	// Relay is not used by Reserve unless it is enabled
+	Relay       *Relay
+	Reserve     *rsblockchain.DGXReserve
+	OperatorKey *ecdsa.PrivateKey
+	// OperatorKeys are all pricing operators of Reserve, OperatorKey first
+	OperatorKeys []*ecdsa.PrivateKey
+	DigixKey     *ecdsa.PrivateKey
+	AdminKey     *ecdsa.PrivateKey
+	// Dir is a temporary directory removed by Close
+	Dir string
+}
+
+func (self *Backend) Operator() ethereum.Address {
+	return crypto.PubkeyToAddress(self.OperatorKey.PublicKey)
+}
+
+func (self *Backend) Close() {
+	self.Feeds.Close()
+	self.Relay.Close()
+	for _, node := range self.Nodes {
+		node.Close()
+	}
+	os.RemoveAll(self.Dir)
+}
+
+// WriteKeystore encrypts key into a keystore file in the backend's directory
+func (self *Backend) WriteKeystore(key *ecdsa.PrivateKey, name string) string {
+	ksKey := &keystore.Key{
+		Id:         uuid.NewRandom(),
+		Address:    crypto.PubkeyToAddress(key.PublicKey),
+		PrivateKey: key,
+	}
+	data, err := keystore.EncryptKey(ksKey, PASSPHRASE, keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(self.Dir, name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// NewBaseBlockchain returns a BaseBlockchain connected to the simulated node
+func (self *Backend) NewBaseBlockchain() (*blockchain.BaseBlockchain, *rpc.Client) {
+	rpcClient, err := rpc.Dial(self.Node.URL())
+	if err != nil {
+		panic(err)
+	}
+	client := ethclient.NewClient(rpcClient)
+	return blockchain.NewBaseBlockchain(
+		rpcClient, client, map[string]*blockchain.Operator{},
+		blockchain.NewBroadcaster(map[string]*ethclient.Client{self.Node.URL(): client}),
+		fixedRate(ETH_USD_RATE),
+		"byzantium",
+		blockchain.NewContractCaller([]*ethclient.Client{client}, []string{self.Node.URL()}),
+	), rpcClient
+}
+
+func mustGenerateKey() *ecdsa.PrivateKey {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func NewBackend() *Backend {
+	return NewBackendWithNodes(1)
+}
+
+// NewBackendWithNodes returns a backend whose reserve reads from a pool
+// of n nodes serving the same chain
+func NewBackendWithNodes(n int) *Backend {
+	return newBackend(n, 1)
+}
+
+// NewBackendWithOperators returns a backend whose reserve has n pricing
+// operators, all authorised on the stand-in reserve and funded
+func NewBackendWithOperators(n int) *Backend {
+	return newBackend(1, n)
+}
+
+func newBackend(n int, operators int) *Backend {
+	dir, err := ioutil.TempDir("", "dgx-simulation")
+	if err != nil {
+		panic(err)
+	}
+	digixKey, adminKey := mustGenerateKey(), mustGenerateKey()
+	operatorKeys := []*ecdsa.PrivateKey{}
+	for i := 0; i < operators; i++ {
+		operatorKeys = append(operatorKeys, mustGenerateKey())
+	}
+	reserve := NewReserve(
+		RESERVE_ADDRESS,
+		crypto.PubkeyToAddress(adminKey.PublicKey),
+		crypto.PubkeyToAddress(digixKey.PublicKey),
+		MAX_BLOCK_DRIFT,
+	)
+	for _, key := range operatorKeys {
+		reserve.AddOperator(crypto.PubkeyToAddress(key.PublicKey))
+	}
+	chain := NewChain(reserve, START_BLOCK)
+	for _, key := range operatorKeys {
+		// 100 ETH
+		chain.Fund(crypto.PubkeyToAddress(key.PublicKey), new(big.Int).Mul(big.NewInt(100), big.NewInt(1000000000000000000)))
+	}
+	nodes := []*Node{}
+	urls := []string{}
+	for i := 0; i < n; i++ {
+		node := NewNode(chain)
+		nodes = append(nodes, node)
+		urls = append(urls, node.URL())
+	}
+	pool, err := rsblockchain.NewClientPool(urls)
+	if err != nil {
+		panic(err)
+	}
+	backend := &Backend{
+		Chain:        chain,
+		Node:         nodes[0],
+		Nodes:        nodes,
+		Pool:         pool,
+		Feeds:        NewFeedServer(digixKey, chain),
+		Relay:        NewRelay(chain),
+		OperatorKey:  operatorKeys[0],
+		OperatorKeys: operatorKeys,
+		DigixKey:     digixKey,
+		AdminKey:     adminKey,
+		Dir:          dir,
+	}
+	signers := []blockchain.Signer{}
+	for i, key := range operatorKeys {
+		keystore := backend.WriteKeystore(key, fmt.Sprintf("keystore%d", i))
+		signers = append(signers, blockchain.NewEthereumSigner(keystore, PASSPHRASE))
+	}
+	base, _ := backend.NewBaseBlockchain()
+	backend.Reserve = rsblockchain.NewDGXReserve(base, pool, RESERVE_ADDRESS, signers...)
+	return backend
+}
@@ -0,0 +1,378 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	TRANSFER_GAS uint64 = 21000
+	CONTRACT_GAS uint64 = 60000
+)
+
+type minedLog struct {
+	Log
+	blockNumber uint64
+	txHash      ethereum.Hash
+}
+
+type minedTx struct {
+	tx          *types.Transaction
+	from        ethereum.Address
+	blockNumber uint64
+	status      uint
+	gasUsed     uint64
+}
+
+// Chain is an in-memory chain with a single reserve contract. Blocks are
+// only mined by Mine or by the miner started with StartMining, which makes
+// tests decide exactly when txs are mined, delayed or dropped.
+// Chain is thread safe.
+type Chain struct {
+	mu          sync.Mutex
+	blockNumber uint64
+	nonces      map[ethereum.Address]uint64
+	balances    map[ethereum.Address]*big.Int
+	pool        map[ethereum.Address]map[uint64]*types.Transaction
+	mined       map[ethereum.Hash]*minedTx
+	logs        []minedLog
+	// txs sent through a private relay, they are mined like pool txs
+	// but nodes don't see them until then
+	private map[ethereum.Address]map[uint64]*privateTx
+	Reserve *Reserve
+
+	// txs with lower gas price stay in the pool, used to simulate
+	// delayed mining until the gas price is bumped
+	minGasPrice *big.Int
+	// number of next incoming txs to be accepted but silently dropped
+	dropIncoming int
+	stop         chan struct{}
+	// subscribers of new blocks
+	heads map[chan uint64]bool
+}
+
+type privateTx struct {
+	tx       *types.Transaction
+	maxBlock uint64
+}
+
+func sender(tx *types.Transaction) (ethereum.Address, error) {
+	return types.Sender(types.HomesteadSigner{}, tx)
+}
+
+func (self *Chain) BlockNumber() uint64 {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.blockNumber
+}
+
+func (self *Chain) Fund(addr ethereum.Address, amount *big.Int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.balances[addr] = new(big.Int).Add(self.balance(addr), amount)
+}
+
+func (self *Chain) balance(addr ethereum.Address) *big.Int {
+	if b, found := self.balances[addr]; found {
+		return b
+	}
+	return big.NewInt(0)
+}
+
+func (self *Chain) Balance(addr ethereum.Address) *big.Int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return new(big.Int).Set(self.balance(addr))
+}
+
+func (self *Chain) SetMinGasPrice(gasPrice *big.Int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.minGasPrice = gasPrice
+}
+
+// DropIncoming makes the chain accept the next n txs without keeping them
+func (self *Chain) DropIncoming(n int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.dropIncoming = n
+}
+
+// Drop removes a pending tx from the pool
+func (self *Chain) Drop(hash ethereum.Hash) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, txs := range self.pool {
+		for n, tx := range txs {
+			if tx.Hash() == hash {
+				delete(txs, n)
+			}
+		}
+	}
+}
+
+func (self *Chain) minedNonce(addr ethereum.Address) uint64 {
+	return self.nonces[addr]
+}
+
+func (self *Chain) pendingNonce(addr ethereum.Address) uint64 {
+	n := self.minedNonce(addr)
+	for {
+		if _, found := self.pool[addr][n]; !found {
+			return n
+		}
+		n++
+	}
+}
+
+func (self *Chain) SendTransaction(tx *types.Transaction) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	from, err := sender(tx)
+	if err != nil {
+		return err
+	}
+	if _, found := self.mined[tx.Hash()]; found {
+		return errors.New(fmt.Sprintf("known transaction: %x", tx.Hash()))
+	}
+	if tx.Nonce() < self.minedNonce(from) {
+		return errors.New("nonce too low")
+	}
+	cost := new(big.Int).Mul(tx.Gas(), tx.GasPrice())
+	cost.Add(cost, tx.Value())
+	if self.balance(from).Cmp(cost) < 0 {
+		return errors.New("insufficient funds for gas * price + value")
+	}
+	if old, found := self.pool[from][tx.Nonce()]; found {
+		if old.Hash() == tx.Hash() {
+			return errors.New(fmt.Sprintf("known transaction: %x", tx.Hash()))
+		}
+		// replacements must pay at least 10% more
+		threshold := new(big.Int).Div(new(big.Int).Mul(old.GasPrice(), big.NewInt(110)), big.NewInt(100))
+		if tx.GasPrice().Cmp(threshold) < 0 {
+			return errors.New("replacement transaction underpriced")
+		}
+	}
+	if self.dropIncoming > 0 {
+		self.dropIncoming--
+		return nil
+	}
+	if self.pool[from] == nil {
+		self.pool[from] = map[uint64]*types.Transaction{}
+	}
+	self.pool[from][tx.Nonce()] = tx
+	return nil
+}
+
+// SendPrivateTransaction adds tx to the txs miners will include up to
+// block maxBlock without showing it in the pool
+func (self *Chain) SendPrivateTransaction(tx *types.Transaction, maxBlock uint64) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	from, err := sender(tx)
+	if err != nil {
+		return err
+	}
+	if tx.Nonce() < self.minedNonce(from) {
+		return errors.New("nonce too low")
+	}
+	if self.private[from] == nil {
+		self.private[from] = map[uint64]*privateTx{}
+	}
+	self.private[from][tx.Nonce()] = &privateTx{tx, maxBlock}
+	return nil
+}
+
+// nextTx returns the tx from from at nonce n to be mined, private txs
+// come first, expired ones are dropped
+func (self *Chain) nextTx(from ethereum.Address, n uint64) (*types.Transaction, bool) {
+	if p, found := self.private[from][n]; found {
+		if p.maxBlock >= self.blockNumber {
+			return p.tx, true
+		}
+		delete(self.private[from], n)
+	}
+	tx, found := self.pool[from][n]
+	return tx, found
+}
+
+func (self *Chain) execute(from ethereum.Address, tx *types.Transaction, commit bool) (status uint, gasUsed uint64) {
+	if tx.To() != nil && *tx.To() == self.Reserve.Address {
+		if err := self.Reserve.Execute(from, tx.Data(), self.blockNumber, commit); err != nil {
+			return 0, CONTRACT_GAS
+		}
+		return 1, CONTRACT_GAS
+	}
+	if commit && tx.To() != nil {
+		self.balances[from] = new(big.Int).Sub(self.balance(from), tx.Value())
+		self.balances[*tx.To()] = new(big.Int).Add(self.balance(*tx.To()), tx.Value())
+	}
+	return 1, TRANSFER_GAS
+}
+
+// Mine mines one block with every executable tx in the pool
+// which pays at least the min gas price
+func (self *Chain) Mine() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.blockNumber++
+	senders := []ethereum.Address{}
+	for from := range self.pool {
+		senders = append(senders, from)
+	}
+	for from := range self.private {
+		if _, found := self.pool[from]; !found {
+			senders = append(senders, from)
+		}
+	}
+	sort.Slice(senders, func(i, j int) bool { return senders[i].Hex() < senders[j].Hex() })
+	for _, from := range senders {
+		for {
+			n := self.minedNonce(from)
+			tx, found := self.nextTx(from, n)
+			if !found || (self.minGasPrice != nil && tx.GasPrice().Cmp(self.minGasPrice) < 0) {
+				break
+			}
+			delete(self.pool[from], n)
+			delete(self.private[from], n)
+			status, gasUsed := self.execute(from, tx, true)
+			fee := new(big.Int).Mul(new(big.Int).SetUint64(gasUsed), tx.GasPrice())
+			self.balances[from] = new(big.Int).Sub(self.balance(from), fee)
+			self.nonces[from] = n + 1
+			self.mined[tx.Hash()] = &minedTx{tx, from, self.blockNumber, status, gasUsed}
+			self.collectLogs(tx.Hash())
+		}
+	}
+	// events of changes made directly to the reserve are in the block
+	// without a tx
+	self.collectLogs(ethereum.Hash{})
+	for heads := range self.heads {
+		select {
+		case heads <- self.blockNumber:
+		default:
+		}
+	}
+}
+
+func (self *Chain) collectLogs(txHash ethereum.Hash) {
+	for _, l := range self.Reserve.takeLogs() {
+		self.logs = append(self.logs, minedLog{l, self.blockNumber, txHash})
+	}
+}
+
+// Logs returns the events of the reserve mined from block fromBlock to
+// block toBlock included
+func (self *Chain) Logs(fromBlock uint64, toBlock uint64) []minedLog {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []minedLog{}
+	for _, l := range self.logs {
+		if l.blockNumber >= fromBlock && l.blockNumber <= toBlock {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// SubscribeHeads sends the number of every mined block to the returned
+// channel until unsubscribe is called, blocks are dropped for slow receivers
+func (self *Chain) SubscribeHeads() (<-chan uint64, func()) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	heads := make(chan uint64, 16)
+	self.heads[heads] = true
+	return heads, func() {
+		self.mu.Lock()
+		defer self.mu.Unlock()
+		delete(self.heads, heads)
+	}
+}
+
+// HeadSubscribers returns the number of subscriptions to new blocks
+func (self *Chain) HeadSubscribers() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.heads)
+}
+
+// StartMining mines a block every interval until StopMining is called
+func (self *Chain) StartMining(interval time.Duration) {
+	stop := make(chan struct{})
+	self.mu.Lock()
+	self.stop = stop
+	self.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				self.Mine()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (self *Chain) StopMining() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.stop != nil {
+		close(self.stop)
+		self.stop = nil
+	}
+}
+
+// Receipt returns status, block number and gas used of a mined tx
+func (self *Chain) Receipt(hash ethereum.Hash) (status uint, blockNumber uint64, gasUsed uint64, found bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	m, found := self.mined[hash]
+	if !found {
+		return 0, 0, 0, false
+	}
+	return m.status, m.blockNumber, m.gasUsed, true
+}
+
+// PendingTxs returns txs from addr in the pool
+func (self *Chain) PendingTxs(addr ethereum.Address) []*types.Transaction {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	result := []*types.Transaction{}
+	for _, tx := range self.pool[addr] {
+		result = append(result, tx)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Nonce() < result[j].Nonce() })
+	return result
+}
+
+// WithReserve runs f while holding the chain lock so the
+// reserve can be inspected or reconfigured safely
+func (self *Chain) WithReserve(f func(reserve *Reserve)) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	f(self.Reserve)
+}
+
+func NewChain(reserve *Reserve, startBlock uint64) *Chain {
+	// the reserve is set up before the chain starts
+	reserve.takeLogs()
+	return &Chain{
+		blockNumber: startBlock,
+		nonces:      map[ethereum.Address]uint64{},
+		balances:    map[ethereum.Address]*big.Int{},
+		pool:        map[ethereum.Address]map[uint64]*types.Transaction{},
+		mined:       map[ethereum.Hash]*minedTx{},
+		private:     map[ethereum.Address]map[uint64]*privateTx{},
+		Reserve:     reserve,
+		heads:       map[chan uint64]bool{},
+	}
+}
@@ -0,0 +1,77 @@
+package simulation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/KyberNetwork/dgx-price-feeder/tracing"
+)
+
+// Collector is a stand-in OTLP/HTTP collector accepting traces with the
+// JSON encoding on /v1/traces
+type Collector struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	services []string
+	spans    []tracing.SpanData
+}
+
+func (self *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != tracing.OTLP_PATH {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "only the json encoding is supported", http.StatusUnsupportedMediaType)
+		return
+	}
+	request := tracing.Request{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, resource := range request.ResourceSpans {
+		for _, kv := range resource.Resource.Attributes {
+			if kv.Key == "service.name" {
+				self.services = append(self.services, kv.Value.String())
+			}
+		}
+		for _, scope := range resource.ScopeSpans {
+			self.spans = append(self.spans, scope.Spans...)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("{}"))
+}
+
+// Spans returns the spans received so far
+func (self *Collector) Spans() []tracing.SpanData {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return append([]tracing.SpanData{}, self.spans...)
+}
+
+// Services returns the service name of every batch received so far
+func (self *Collector) Services() []string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return append([]string{}, self.services...)
+}
+
+func (self *Collector) URL() string {
+	return self.server.URL
+}
+
+func (self *Collector) Close() {
+	self.server.Close()
+}
+
+func NewCollector() *Collector {
+	result := &Collector{}
+	result.server = httptest.NewServer(result)
+	return result
+}
@@ -0,0 +1,112 @@
+package simulation
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SignFeed returns a feed signed by key in the format of the Digix endpoint,
+// it is the Go version of cmd/test_feed.py
+func SignFeed(key *ecdsa.PrivateKey, blockNumber *big.Int, nonce *big.Int, ask *big.Int, bid *big.Int) []byte {
+	msg := []byte{}
+	for _, n := range []*big.Int{blockNumber, nonce, ask, bid} {
+		msg = append(msg, math.PaddedBigBytes(n, 32)...)
+	}
+	hash := crypto.Keccak256(msg)
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		panic(err)
+	}
+	feed := map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"block_number": blockNumber,
+			"nonce":        nonce,
+			"ask_for_1000": ask,
+			"bid_for_1000": bid,
+			"message":      hexutil.Encode(msg),
+			"hash":         hexutil.Encode(hash),
+			"signer":       crypto.PubkeyToAddress(key.PublicKey),
+			"v":            sig[64] + 27,
+			"r":            hexutil.Encode(sig[0:32]),
+			"s":            hexutil.Encode(sig[32:64]),
+		},
+	}
+	data, err := json.Marshal(feed)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// FeedServer serves feeds signed by a key at the chain's current block,
+// every request gets a higher nonce
+type FeedServer struct {
+	mu     sync.Mutex
+	key    *ecdsa.PrivateKey
+	chain  *Chain
+	nonce  *big.Int
+	ask    *big.Int
+	bid    *big.Int
+	down   bool
+	server *httptest.Server
+}
+
+func (self *FeedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.down {
+		http.Error(w, "feed is down", http.StatusServiceUnavailable)
+		return
+	}
+	self.nonce = new(big.Int).Add(self.nonce, big.NewInt(1))
+	blockNumber := new(big.Int).SetUint64(self.chain.BlockNumber())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(SignFeed(self.key, blockNumber, self.nonce, self.ask, self.bid))
+}
+
+func (self *FeedServer) URL() string {
+	return self.server.URL
+}
+
+func (self *FeedServer) Signer() ethereum.Address {
+	return crypto.PubkeyToAddress(self.key.PublicKey)
+}
+
+func (self *FeedServer) SetPrice(ask *big.Int, bid *big.Int) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.ask, self.bid = ask, bid
+}
+
+// SetDown makes the server answer every request with an error
+func (self *FeedServer) SetDown(down bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.down = down
+}
+
+func (self *FeedServer) Close() {
+	self.server.Close()
+}
+
+func NewFeedServer(key *ecdsa.PrivateKey, chain *Chain) *FeedServer {
+	result := &FeedServer{
+		key:   key,
+		chain: chain,
+		nonce: big.NewInt(1523036543),
+		ask:   big.NewInt(48082),
+		bid:   big.NewInt(46440),
+	}
+	result.server = httptest.NewServer(result)
+	return result
+}
@@ -0,0 +1,194 @@
+package simulation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/lease"
+)
+
+type redisValue struct {
+	value   string
+	expires time.Time
+}
+
+// Redis is a stand-in redis server speaking RESP. It supports PING, GET,
+// SET with PX, DEL and EVAL of the lease scripts, which is what
+// lease.RedisLease needs.
+// Redis is thread safe.
+type Redis struct {
+	listener net.Listener
+	mu       sync.Mutex
+	values   map[string]redisValue
+}
+
+func (self *Redis) get(key string) (string, bool) {
+	v, found := self.values[key]
+	if !found {
+		return "", false
+	}
+	if !v.expires.IsZero() && !time.Now().Before(v.expires) {
+		delete(self.values, key)
+		return "", false
+	}
+	return v.value, true
+}
+
+func (self *Redis) set(key string, value string, px string) error {
+	v := redisValue{value: value}
+	if px != "" {
+		ms, err := strconv.ParseInt(px, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.expires = time.Now().Add(time.Duration(ms) * time.Millisecond)
+	}
+	self.values[key] = v
+	return nil
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, errors.New("expected an array")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := []string{}
+	for i := 0; i < n; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// execute returns the RESP reply of the command
+func (self *Redis) execute(args []string) string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if v, found := self.get(args[1]); found {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "SET":
+		px := ""
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			px = args[4]
+		}
+		if err := self.set(args[1], args[2], px); err != nil {
+			return "-ERR " + err.Error() + "\r\n"
+		}
+		return "+OK\r\n"
+	case "DEL":
+		if _, found := self.get(args[1]); found {
+			delete(self.values, args[1])
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "EVAL":
+		if len(args) < 5 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+		key, holder := args[3], args[4]
+		v, found := self.get(key)
+		switch args[1] {
+		case lease.ACQUIRE_SCRIPT:
+			if len(args) < 6 {
+				return "-ERR missing ttl\r\n"
+			}
+			if !found || v == holder {
+				if err := self.set(key, holder, args[5]); err != nil {
+					return "-ERR " + err.Error() + "\r\n"
+				}
+				return ":1\r\n"
+			}
+			return ":0\r\n"
+		case lease.RELEASE_SCRIPT:
+			if found && v == holder {
+				delete(self.values, key)
+				return ":1\r\n"
+			}
+			return ":0\r\n"
+		}
+		return "-ERR unknown script\r\n"
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+func (self *Redis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write([]byte(self.execute(args))); err != nil {
+			return
+		}
+	}
+}
+
+// Addr returns host:port of the server
+func (self *Redis) Addr() string {
+	return self.listener.Addr().String()
+}
+
+func (self *Redis) Close() {
+	self.listener.Close()
+}
+
+func NewRedis() *Redis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	server := &Redis{
+		listener: listener,
+		values:   map[string]redisValue{},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,79 @@
+package simulation
+
+import (
+	"net/http/httptest"
+	"sync"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type PrivateTxArgs struct {
+	Tx             hexutil.Bytes  `json:"tx"`
+	MaxBlockNumber hexutil.Uint64 `json:"maxBlockNumber"`
+}
+
+// RelayAPI serves eth_sendPrivateTransaction
+type RelayAPI struct {
+	relay *Relay
+}
+
+func (self *RelayAPI) SendPrivateTransaction(args PrivateTxArgs) (ethereum.Hash, error) {
+	tx := &types.Transaction{}
+	if err := rlp.DecodeBytes(args.Tx, tx); err != nil {
+		return ethereum.Hash{}, err
+	}
+	self.relay.mu.Lock()
+	defer self.relay.mu.Unlock()
+	self.relay.received = append(self.relay.received, tx)
+	if !self.relay.including {
+		// accepted but never given to miners
+		return tx.Hash(), nil
+	}
+	return tx.Hash(), self.relay.chain.SendPrivateTransaction(tx, uint64(args.MaxBlockNumber))
+}
+
+// Relay is a stand-in private relay, txs it receives are mined without
+// going through the nodes' pools. When it is not including, txs are
+// accepted and silently dropped.
+type Relay struct {
+	mu        sync.Mutex
+	chain     *Chain
+	server    *httptest.Server
+	including bool
+	received  []*types.Transaction
+}
+
+func (self *Relay) SetIncluding(including bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.including = including
+}
+
+// Received returns the txs sent to the relay so far
+func (self *Relay) Received() []*types.Transaction {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return append([]*types.Transaction{}, self.received...)
+}
+
+func (self *Relay) URL() string {
+	return self.server.URL
+}
+
+func (self *Relay) Close() {
+	self.server.Close()
+}
+
+func NewRelay(chain *Chain) *Relay {
+	relay := &Relay{chain: chain, including: true}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &RelayAPI{relay}); err != nil {
+		panic(err)
+	}
+	relay.server = httptest.NewServer(server)
+	return relay
+}
@@ -0,0 +1,288 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	rsblockchain "github.com/KyberNetwork/dgx-price-feeder/blockchain"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Reserve is a stand-in of the DGX reserve contract. It implements the
+// methods of the reserve abi the feeder uses, setPriceFeed checks the sender
+// is an operator, the feed is signed by the Digix signer, its nonce is
+// increasing and its block is within maxBlockDrift of the current block.
+type Reserve struct {
+	Address ethereum.Address
+	abi     abi.ABI
+
+	admin         ethereum.Address
+	operators     []ethereum.Address
+	alerters      []ethereum.Address
+	digixSigner   ethereum.Address
+	maxBlockDrift uint64
+	tradeEnabled  bool
+	pendingAdmin  ethereum.Address
+	// contracts are the addresses returned by the view functions of the
+	// same name, ie digix, balances are the tokens held by the reserve
+	contracts       map[string]ethereum.Address
+	balances        map[ethereum.Address]*big.Int
+	buyTransferFee  *big.Int
+	sellTransferFee *big.Int
+
+	feedBlock *big.Int
+	nonce     *big.Int
+	ask       *big.Int
+	bid       *big.Int
+
+	// events emitted since the chain last took them
+	logs []Log
+}
+
+// Log is an event emitted by the reserve
+type Log struct {
+	Topics []ethereum.Hash
+	Data   []byte
+}
+
+func (self *Reserve) method(data []byte) (abi.Method, error) {
+	if len(data) < 4 {
+		return abi.Method{}, errors.New("missing method id")
+	}
+	for _, m := range self.abi.Methods {
+		if string(m.Id()) == string(data[:4]) {
+			return m, nil
+		}
+	}
+	return abi.Method{}, errors.New(fmt.Sprintf("unknown method id %x", data[:4]))
+}
+
+func (self *Reserve) isOperator(addr ethereum.Address) bool {
+	for _, op := range self.operators {
+		if op == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func word(data []byte, i int) []byte {
+	start := 4 + i*32
+	if len(data) < start+32 {
+		return make([]byte, 32)
+	}
+	return data[start : start+32]
+}
+
+// setPriceFeed validates the call and applies it if commit is true
+func (self *Reserve) setPriceFeed(from ethereum.Address, data []byte, blockNumber uint64, commit bool) error {
+	if !self.isOperator(from) {
+		return errors.New("sender is not an operator")
+	}
+	feedBlock := new(big.Int).SetBytes(word(data, 0))
+	nonce := new(big.Int).SetBytes(word(data, 1))
+	ask := new(big.Int).SetBytes(word(data, 2))
+	bid := new(big.Int).SetBytes(word(data, 3))
+	v := new(big.Int).SetBytes(word(data, 4)).Uint64()
+	if v != 27 && v != 28 {
+		return errors.New("invalid v")
+	}
+	msg := []byte{}
+	for _, n := range []*big.Int{feedBlock, nonce, ask, bid} {
+		msg = append(msg, math.PaddedBigBytes(n, 32)...)
+	}
+	sig := append(append(append([]byte{}, word(data, 5)...), word(data, 6)...), byte(v-27))
+	pub, err := crypto.SigToPub(crypto.Keccak256(msg), sig)
+	if err != nil {
+		return err
+	}
+	if crypto.PubkeyToAddress(*pub) != self.digixSigner {
+		return errors.New("feed is not signed by digix")
+	}
+	if feedBlock.Uint64() > blockNumber || blockNumber-feedBlock.Uint64() > self.maxBlockDrift {
+		return errors.New("feed block drifts too much")
+	}
+	if nonce.Cmp(self.nonce) <= 0 {
+		return errors.New("feed nonce is not increasing")
+	}
+	if commit {
+		self.feedBlock, self.nonce, self.ask, self.bid = feedBlock, nonce, ask, bid
+	}
+	return nil
+}
+
+// Execute runs a tx sent to the reserve, it returns error if the tx reverts
+func (self *Reserve) Execute(from ethereum.Address, data []byte, blockNumber uint64, commit bool) error {
+	m, err := self.method(data)
+	if err != nil {
+		return err
+	}
+	switch m.Name {
+	case "setPriceFeed":
+		return self.setPriceFeed(from, data, blockNumber, commit)
+	case "addOperator":
+		if from != self.admin {
+			return errors.New("sender is not admin")
+		}
+		if commit {
+			self.AddOperator(ethereum.BytesToAddress(word(data, 0)))
+		}
+		return nil
+	case "removeOperator":
+		if from != self.admin {
+			return errors.New("sender is not admin")
+		}
+		if commit {
+			self.RemoveOperator(ethereum.BytesToAddress(word(data, 0)))
+		}
+		return nil
+	}
+	if m.Const {
+		_, err := self.Call(data)
+		return err
+	}
+	return errors.New(fmt.Sprintf("%s is not supported by the simulated reserve", m.Name))
+}
+
+func encodeUint(n *big.Int) []byte {
+	return math.PaddedBigBytes(n, 32)
+}
+
+func encodeBool(b bool) []byte {
+	if b {
+		return encodeUint(big.NewInt(1))
+	}
+	return encodeUint(big.NewInt(0))
+}
+
+func encodeAddress(addr ethereum.Address) []byte {
+	return ethereum.LeftPadBytes(addr.Bytes(), 32)
+}
+
+func encodeAddresses(addrs []ethereum.Address) []byte {
+	result := append(encodeUint(big.NewInt(32)), encodeUint(big.NewInt(int64(len(addrs))))...)
+	for _, addr := range addrs {
+		result = append(result, encodeAddress(addr)...)
+	}
+	return result
+}
+
+// Call runs a view method of the reserve and returns its abi encoded output
+func (self *Reserve) Call(data []byte) ([]byte, error) {
+	m, err := self.method(data)
+	if err != nil {
+		return nil, err
+	}
+	switch m.Name {
+	case "getPriceFeed":
+		result := []byte{}
+		for _, n := range []*big.Int{self.feedBlock, self.nonce, self.ask, self.bid} {
+			result = append(result, encodeUint(n)...)
+		}
+		return result, nil
+	case "priceFeed":
+		return encodeUint(self.ask), nil
+	case "maxBlockDrift":
+		return encodeUint(big.NewInt(int64(self.maxBlockDrift))), nil
+	case "tradeEnabled":
+		return encodeBool(self.tradeEnabled), nil
+	case "getOperators":
+		return encodeAddresses(self.operators), nil
+	case "getAlerters":
+		return encodeAddresses(self.alerters), nil
+	case "admin":
+		return encodeAddress(self.admin), nil
+	case "pendingAdmin":
+		return encodeAddress(self.pendingAdmin), nil
+	case "digix", "kyberNetwork", "makerDaoContract", "conversionRatesContract", "sanityRatesContract":
+		return encodeAddress(self.contracts[m.Name]), nil
+	case "buyTransferFee":
+		return encodeUint(self.buyTransferFee), nil
+	case "sellTransferFee":
+		return encodeUint(self.sellTransferFee), nil
+	case "getBalance":
+		balance, found := self.balances[ethereum.BytesToAddress(word(data, 0))]
+		if !found {
+			balance = big.NewInt(0)
+		}
+		return encodeUint(balance), nil
+	}
+	return nil, errors.New(fmt.Sprintf("%s is not supported by the simulated reserve", m.Name))
+}
+
+func (self *Reserve) emitOperatorAdded(addr ethereum.Address, added bool) {
+	self.logs = append(self.logs, Log{
+		Topics: []ethereum.Hash{self.abi.Events["OperatorAdded"].Id()},
+		Data:   append(encodeAddress(addr), encodeBool(added)...),
+	})
+}
+
+// takeLogs returns the events emitted since the last call
+func (self *Reserve) takeLogs() []Log {
+	logs := self.logs
+	self.logs = nil
+	return logs
+}
+
+func (self *Reserve) AddOperator(addr ethereum.Address) {
+	self.operators = append(self.operators, addr)
+	self.emitOperatorAdded(addr, true)
+}
+
+func (self *Reserve) RemoveOperator(addr ethereum.Address) {
+	operators := []ethereum.Address{}
+	for _, op := range self.operators {
+		if op != addr {
+			operators = append(operators, op)
+		}
+	}
+	self.operators = operators
+	self.emitOperatorAdded(addr, false)
+}
+
+// SetContract sets the address returned by the view function name, ie
+// digix or kyberNetwork
+func (self *Reserve) SetContract(name string, addr ethereum.Address) {
+	self.contracts[name] = addr
+}
+
+func (self *Reserve) SetPendingAdmin(addr ethereum.Address) {
+	self.pendingAdmin = addr
+}
+
+func (self *Reserve) SetTransferFees(buy *big.Int, sell *big.Int) {
+	self.buyTransferFee, self.sellTransferFee = buy, sell
+}
+
+// SetBalance sets the balance of token held by the reserve
+func (self *Reserve) SetBalance(token ethereum.Address, amount *big.Int) {
+	self.balances[token] = amount
+}
+
+// Feed returns the feed stored in the reserve
+func (self *Reserve) Feed() (feedBlock *big.Int, nonce *big.Int, ask *big.Int, bid *big.Int) {
+	return self.feedBlock, self.nonce, self.ask, self.bid
+}
+
+func NewReserve(address ethereum.Address, admin ethereum.Address, digixSigner ethereum.Address, maxBlockDrift uint64) *Reserve {
+	return &Reserve{
+		Address:         address,
+		abi:             rsblockchain.ReserveABI(),
+		admin:           admin,
+		digixSigner:     digixSigner,
+		maxBlockDrift:   maxBlockDrift,
+		tradeEnabled:    true,
+		contracts:       map[string]ethereum.Address{},
+		balances:        map[ethereum.Address]*big.Int{},
+		buyTransferFee:  big.NewInt(0),
+		sellTransferFee: big.NewInt(0),
+		feedBlock:       big.NewInt(0),
+		nonce:           big.NewInt(0),
+		ask:             big.NewInt(0),
+		bid:             big.NewInt(0),
+	}
+}
@@ -0,0 +1,434 @@
+package simulation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const GAS_PRICE int64 = 1000000000
+
+type CallArgs struct {
+	From     ethereum.Address  `json:"from"`
+	To       *ethereum.Address `json:"to"`
+	Gas      *hexutil.Big      `json:"gas"`
+	GasPrice *hexutil.Big      `json:"gasPrice"`
+	Value    *hexutil.Big      `json:"value"`
+	Data     hexutil.Bytes     `json:"data"`
+}
+
+type FilterArgs struct {
+	FromBlock hexutil.Uint64    `json:"fromBlock"`
+	ToBlock   hexutil.Uint64    `json:"toBlock"`
+	Address   *ethereum.Address `json:"address"`
+	Topics    [][]ethereum.Hash `json:"topics"`
+}
+
+// EthAPI serves the eth_ methods the feeder uses
+type EthAPI struct {
+	chain *Chain
+	// headLag is how many blocks the node reports behind the chain
+	headLag uint64
+}
+
+func (self *EthAPI) BlockNumber() hexutil.Uint64 {
+	number := self.chain.BlockNumber()
+	lag := atomic.LoadUint64(&self.headLag)
+	if lag > number {
+		return 0
+	}
+	return hexutil.Uint64(number - lag)
+}
+
+func (self *EthAPI) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(GAS_PRICE))
+}
+
+func (self *EthAPI) GetBalance(addr ethereum.Address, block string) *hexutil.Big {
+	return (*hexutil.Big)(self.chain.Balance(addr))
+}
+
+func (self *EthAPI) GetTransactionCount(addr ethereum.Address, block string) hexutil.Uint64 {
+	self.chain.mu.Lock()
+	defer self.chain.mu.Unlock()
+	if block == "pending" {
+		return hexutil.Uint64(self.chain.pendingNonce(addr))
+	}
+	return hexutil.Uint64(self.chain.minedNonce(addr))
+}
+
+func (self *EthAPI) GetCode(addr ethereum.Address, block string) hexutil.Bytes {
+	if addr == self.chain.Reserve.Address {
+		// any non empty code makes the contract callable
+		return hexutil.Bytes{0x60, 0x60}
+	}
+	return hexutil.Bytes{}
+}
+
+func (self *EthAPI) SendRawTransaction(data hexutil.Bytes) (ethereum.Hash, error) {
+	tx := &types.Transaction{}
+	if err := rlp.DecodeBytes(data, tx); err != nil {
+		return ethereum.Hash{}, err
+	}
+	return tx.Hash(), self.chain.SendTransaction(tx)
+}
+
+// EstimateGas doesn't execute the call, like old nodes which return
+// the gas limit for calls which would revert
+func (self *EthAPI) EstimateGas(args CallArgs) *hexutil.Big {
+	if args.To != nil && *args.To == self.chain.Reserve.Address {
+		return (*hexutil.Big)(new(big.Int).SetUint64(CONTRACT_GAS))
+	}
+	return (*hexutil.Big)(new(big.Int).SetUint64(TRANSFER_GAS))
+}
+
+func (self *EthAPI) Call(args CallArgs, block string) (hexutil.Bytes, error) {
+	self.chain.mu.Lock()
+	defer self.chain.mu.Unlock()
+	if args.To == nil || *args.To != self.chain.Reserve.Address {
+		return hexutil.Bytes{}, nil
+	}
+	m, err := self.chain.Reserve.method(args.Data)
+	if err != nil {
+		return nil, err
+	}
+	if m.Const {
+		return self.chain.Reserve.Call(args.Data)
+	}
+	if err := self.chain.Reserve.Execute(args.From, args.Data, self.chain.blockNumber, false); err != nil {
+		return nil, errors.New(fmt.Sprintf("execution reverted: %s", err))
+	}
+	return hexutil.Bytes{}, nil
+}
+
+func rpcTx(tx *types.Transaction, from ethereum.Address, blockNumber *uint64) map[string]interface{} {
+	v, r, s := tx.RawSignatureValues()
+	result := map[string]interface{}{
+		"hash":     tx.Hash(),
+		"from":     from,
+		"nonce":    hexutil.Uint64(tx.Nonce()),
+		"gasPrice": (*hexutil.Big)(tx.GasPrice()),
+		"gas":      (*hexutil.Big)(tx.Gas()),
+		"to":       tx.To(),
+		"value":    (*hexutil.Big)(tx.Value()),
+		"input":    hexutil.Bytes(tx.Data()),
+		"v":        (*hexutil.Big)(v),
+		"r":        (*hexutil.Big)(r),
+		"s":        (*hexutil.Big)(s),
+	}
+	if blockNumber == nil {
+		result["blockNumber"] = nil
+		result["blockHash"] = nil
+	} else {
+		result["blockNumber"] = hexutil.Uint64(*blockNumber)
+		result["blockHash"] = blockHash(*blockNumber)
+	}
+	return result
+}
+
+func blockHash(blockNumber uint64) ethereum.Hash {
+	return ethereum.BigToHash(new(big.Int).SetUint64(blockNumber + 1))
+}
+
+// GetLogs supports block ranges given as numbers and filters on the
+// address and the first topic
+func (self *EthAPI) GetLogs(filter FilterArgs) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	if filter.Address != nil && *filter.Address != self.chain.Reserve.Address {
+		return result
+	}
+	for _, l := range self.chain.Logs(uint64(filter.FromBlock), uint64(filter.ToBlock)) {
+		if len(filter.Topics) > 0 && len(filter.Topics[0]) > 0 {
+			match := false
+			for _, topic := range filter.Topics[0] {
+				match = match || topic == l.Topics[0]
+			}
+			if !match {
+				continue
+			}
+		}
+		result = append(result, map[string]interface{}{
+			"address":         self.chain.Reserve.Address,
+			"blockNumber":     hexutil.Uint64(l.blockNumber),
+			"blockHash":       blockHash(l.blockNumber),
+			"transactionHash": l.txHash,
+			"topics":          l.Topics,
+			"data":            hexutil.Bytes(l.Data),
+		})
+	}
+	return result
+}
+
+func (self *EthAPI) GetTransactionByHash(hash ethereum.Hash) map[string]interface{} {
+	self.chain.mu.Lock()
+	defer self.chain.mu.Unlock()
+	if m, found := self.chain.mined[hash]; found {
+		return rpcTx(m.tx, m.from, &m.blockNumber)
+	}
+	for from, txs := range self.chain.pool {
+		for _, tx := range txs {
+			if tx.Hash() == hash {
+				return rpcTx(tx, from, nil)
+			}
+		}
+	}
+	return nil
+}
+
+func (self *EthAPI) GetTransactionReceipt(hash ethereum.Hash) map[string]interface{} {
+	self.chain.mu.Lock()
+	defer self.chain.mu.Unlock()
+	m, found := self.chain.mined[hash]
+	if !found {
+		return nil
+	}
+	return map[string]interface{}{
+		"transactionHash":   hash,
+		"blockNumber":       hexutil.Uint64(m.blockNumber),
+		"blockHash":         blockHash(m.blockNumber),
+		"status":            hexutil.Uint(m.status),
+		"gasUsed":           (*hexutil.Big)(new(big.Int).SetUint64(m.gasUsed)),
+		"cumulativeGasUsed": (*hexutil.Big)(new(big.Int).SetUint64(m.gasUsed)),
+		"logsBloom":         types.Bloom{},
+		"logs":              []*types.Log{},
+		"contractAddress":   nil,
+	}
+}
+
+// NewHeads serves eth_subscribe("newHeads") over websocket, only the
+// number and the hash of blocks are sent
+func (self *EthAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	heads, unsubscribe := self.chain.SubscribeHeads()
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case number := <-heads:
+				notifier.Notify(sub.ID, map[string]interface{}{
+					"number": hexutil.Uint64(number),
+					"hash":   blockHash(number),
+				})
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// TxPoolAPI serves txpool_content like geth does
+type TxPoolAPI struct {
+	chain *Chain
+}
+
+func (self *TxPoolAPI) Content() map[string]map[string]map[string]map[string]interface{} {
+	self.chain.mu.Lock()
+	defer self.chain.mu.Unlock()
+	content := map[string]map[string]map[string]map[string]interface{}{
+		"pending": {},
+		"queued":  {},
+	}
+	for from, txs := range self.chain.pool {
+		pending := self.chain.pendingNonce(from)
+		for n, tx := range txs {
+			kind := "pending"
+			if n >= pending {
+				kind = "queued"
+			}
+			if content[kind][from.Hex()] == nil {
+				content[kind][from.Hex()] = map[string]map[string]interface{}{}
+			}
+			content[kind][from.Hex()][fmt.Sprintf("%d", n)] = rpcTx(tx, from, nil)
+		}
+	}
+	return content
+}
+
+// Node serves the chain over json rpc on a local http server, on
+// http and websocket
+type Node struct {
+	Chain  *Chain
+	eth    *EthAPI
+	server *httptest.Server
+	// number of http requests, a batch is one request
+	requests int64
+	// down makes the node answer every http request with 503 and refuse
+	// websocket connections
+	down int32
+	// hanging makes the node never answer, until the request is cancelled
+	// or closed is closed
+	hanging int32
+	// lostSends is the number of the next eth_sendRawTransaction calls
+	// which are handled but never answered
+	lostSends int32
+	closed    chan struct{}
+
+	mu sync.Mutex
+	// conns are the open websocket connections
+	conns map[net.Conn]bool
+}
+
+// hijacker records the websocket connections of node so they can be
+// dropped
+type hijacker struct {
+	http.ResponseWriter
+	node *Node
+}
+
+func (self hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := self.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		self.node.mu.Lock()
+		self.node.conns[conn] = true
+		self.node.mu.Unlock()
+	}
+	return conn, rw, err
+}
+
+// DropConnections closes the open websocket connections, as a node
+// which restarts
+func (self *Node) DropConnections() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for conn := range self.conns {
+		conn.Close()
+	}
+	self.conns = map[net.Conn]bool{}
+}
+
+func (self *Node) URL() string {
+	return self.server.URL
+}
+
+func (self *Node) WSURL() string {
+	return "ws" + strings.TrimPrefix(self.server.URL, "http")
+}
+
+// SetDown makes the node unavailable, or available again
+func (self *Node) SetDown(down bool) {
+	var value int32
+	if down {
+		value = 1
+	}
+	atomic.StoreInt32(&self.down, value)
+}
+
+// SetHanging makes the node accept requests without answering them, or
+// answer them again
+func (self *Node) SetHanging(hanging bool) {
+	var value int32
+	if hanging {
+		value = 1
+	}
+	atomic.StoreInt32(&self.hanging, value)
+}
+
+// LoseSendAnswers makes the node take the txs of the next n
+// eth_sendRawTransaction calls without answering them, as a node which
+// times out once it has the tx in its pool
+func (self *Node) LoseSendAnswers(n int) {
+	atomic.StoreInt32(&self.lostSends, int32(n))
+}
+
+// loseAnswer tells if the answer to the request with body must be lost
+func (self *Node) loseAnswer(body []byte) bool {
+	if !bytes.Contains(body, []byte("eth_sendRawTransaction")) {
+		return false
+	}
+	for {
+		n := atomic.LoadInt32(&self.lostSends)
+		if n <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&self.lostSends, n, n-1) {
+			return true
+		}
+	}
+}
+
+// SetHeadLag makes eth_blockNumber report lag blocks behind the chain
+func (self *Node) SetHeadLag(lag uint64) {
+	atomic.StoreUint64(&self.eth.headLag, lag)
+}
+
+// Requests returns the number of http requests served so far
+func (self *Node) Requests() int64 {
+	return atomic.LoadInt64(&self.requests)
+}
+
+func (self *Node) Close() {
+	self.Chain.StopMining()
+	close(self.closed)
+	self.server.Close()
+}
+
+func NewNode(chain *Chain) *Node {
+	server := rpc.NewServer()
+	eth := &EthAPI{chain: chain}
+	if err := server.RegisterName("eth", eth); err != nil {
+		panic(err)
+	}
+	if err := server.RegisterName("txpool", &TxPoolAPI{chain}); err != nil {
+		panic(err)
+	}
+	node := &Node{Chain: chain, eth: eth, closed: make(chan struct{}), conns: map[net.Conn]bool{}}
+	ws := server.WebsocketHandler([]string{"*"})
+	node.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			if atomic.LoadInt32(&node.down) == 1 {
+				http.Error(w, "node is down", http.StatusServiceUnavailable)
+				return
+			}
+			ws.ServeHTTP(hijacker{w, node}, r)
+			return
+		}
+		atomic.AddInt64(&node.requests, 1)
+		if atomic.LoadInt32(&node.down) == 1 {
+			http.Error(w, "node is down", http.StatusServiceUnavailable)
+			return
+		}
+		if atomic.LoadInt32(&node.hanging) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-node.closed:
+			}
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if node.loseAnswer(body) {
+			server.ServeHTTP(httptest.NewRecorder(), r)
+			http.Error(w, "connection lost", http.StatusBadGateway)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	return node
+}
@@ -0,0 +1,98 @@
+package simulation
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+
+	ethereum "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+type SendTxArgs struct {
+	From     ethereum.Address  `json:"from"`
+	To       *ethereum.Address `json:"to"`
+	Gas      hexutil.Uint64    `json:"gas"`
+	GasPrice *hexutil.Big      `json:"gasPrice"`
+	Value    *hexutil.Big      `json:"value"`
+	Nonce    hexutil.Uint64    `json:"nonce"`
+	Data     hexutil.Bytes     `json:"data"`
+}
+
+type SignTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// AccountAPI serves account_list and account_signTransaction like clef
+// does, signing every request without asking
+type AccountAPI struct {
+	key *ecdsa.PrivateKey
+}
+
+func (self *AccountAPI) List() []ethereum.Address {
+	return []ethereum.Address{crypto.PubkeyToAddress(self.key.PublicKey)}
+}
+
+func (self *AccountAPI) SignTransaction(args SendTxArgs) (*SignTxResult, error) {
+	tx := types.NewTransaction(
+		uint64(args.Nonce), *args.To, (*big.Int)(args.Value),
+		new(big.Int).SetUint64(uint64(args.Gas)), (*big.Int)(args.GasPrice), args.Data,
+	)
+	signed, err := types.SignTx(tx, types.HomesteadSigner{}, self.key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, err
+	}
+	return &SignTxResult{raw, signed}, nil
+}
+
+// SignerServer is a stand-in remote signer holding key, served over
+// http and a unix socket
+type SignerServer struct {
+	server   *httptest.Server
+	listener net.Listener
+	socket   string
+}
+
+func (self *SignerServer) URL() string {
+	return self.server.URL
+}
+
+func (self *SignerServer) Socket() string {
+	return self.socket
+}
+
+func (self *SignerServer) Close() {
+	self.server.Close()
+	self.listener.Close()
+	os.Remove(self.socket)
+}
+
+// NewSignerServer serves the signer over http and on the unix socket
+// at socket
+func NewSignerServer(key *ecdsa.PrivateKey, socket string) *SignerServer {
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", &AccountAPI{key}); err != nil {
+		panic(err)
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		panic(err)
+	}
+	go server.ServeListener(listener)
+	return &SignerServer{
+		server:   httptest.NewServer(server),
+		listener: listener,
+		socket:   socket,
+	}
+}
@@ -1,9 +1,13 @@
 package dgxpricing
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"time"
 
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
@@ -17,10 +21,20 @@ type Blockchain interface {
 	TxStatus(common.Hash) (status string, blockno uint64, err error)
 }
 
+// BatchBlockchain gets statuses of many txs in one round trip, the
+// statuses are the same as TxStatus's
+type BatchBlockchain interface {
+	TxStatuses(ctx context.Context, hashes []common.Hash) (map[common.Hash]string, error)
+}
+
 // StatusMonitor is not thread safe
 type StatusMonitor struct {
 	// txs always has at least 1 tx
-	txs []*types.Transaction
+	txs   []*types.Transaction
+	clock clock.Clock
+	// lastPush is when the last tx was pushed
+	lastPush time.Time
+	logger   *logging.Logger
 }
 
 func (self *StatusMonitor) GetOneStatus(tx *types.Transaction, bc Blockchain, data *sync.Map, wg *sync.WaitGroup) {
@@ -50,6 +64,38 @@ func (self *StatusMonitor) ConcurrentlyGetStatus(bc Blockchain) map[string]strin
 	return result
 }
 
+// BatchGetStatus gets statuses of all txs with one batch call so the
+// number of requests doesn't grow with the number of replacements
+func (self *StatusMonitor) BatchGetStatus(ctx context.Context, bc BatchBlockchain) (map[string]string, error) {
+	hashes := []common.Hash{}
+	for _, tx := range self.txs {
+		hashes = append(hashes, tx.Hash())
+	}
+	statuses, err := bc.TxStatuses(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]string{}
+	for hash, status := range statuses {
+		if status == "" {
+			// convert "" to "pending"
+			status = "pending"
+		}
+		result[hash.Hex()] = status
+	}
+	return result, nil
+}
+
+// Replacements returns the number of txs pushed after the first one
+func (self *StatusMonitor) Replacements() int {
+	return len(self.txs) - 1
+}
+
+// Last returns the last tx pushed
+func (self *StatusMonitor) Last() *types.Transaction {
+	return self.txs[len(self.txs)-1]
+}
+
 func (self *StatusMonitor) GetTxByHash(hash string) *types.Transaction {
 	for _, tx := range self.txs {
 		if tx.Hash().Hex() == hash {
@@ -64,8 +110,22 @@ func (self *StatusMonitor) GetTxByHash(hash string) *types.Transaction {
 // 2. failed: if one of the txs is failed
 // 3. lost: if not in the case of 1 nor 2 and the last tx is not found
 // 4. pending: if not in the case of 1 nor 2 nor 3 and the last tx is pending
-func (self *StatusMonitor) GetStatus(bc Blockchain) (st string, tx *types.Transaction, err error) {
-	statuses := self.ConcurrentlyGetStatus(bc)
+// Statuses are looked up in one batch if bc supports it, ctx carries the
+// logger of the cycle the txs are monitored for.
+func (self *StatusMonitor) GetStatus(ctx context.Context, bc Blockchain) (st string, tx *types.Transaction, err error) {
+	var statuses map[string]string
+	if batch, ok := bc.(BatchBlockchain); ok {
+		if statuses, err = self.BatchGetStatus(ctx, batch); err != nil {
+			return "", nil, err
+		}
+	} else {
+		statuses = self.ConcurrentlyGetStatus(bc)
+	}
+	if self.logger.Enabled(logging.DEBUG) {
+		for hash, status := range statuses {
+			self.logger.With("tx", hash).Debugf("Tx status: %s", status)
+		}
+	}
 	// check if any txs is mined
 	for hash, status := range statuses {
 		if status == "mined" {
@@ -92,14 +152,25 @@ func (self *StatusMonitor) PushTx(tx *types.Transaction) error {
 		return errors.New("you must push tx with higher gas price than the last one")
 	}
 	self.txs = append(self.txs, tx)
+	self.lastPush = self.clock.Now()
+	self.logger.With("tx", tx.Hash().Hex(), "nonce", tx.Nonce(), "gas_price", tx.GasPrice()).Infof("Monitoring tx %s along with %d previous ones", tx.Hash().Hex(), len(self.txs)-1)
 	return nil
 }
 
-func NewStatusMonitor(initTx *types.Transaction) *StatusMonitor {
+// WaitingTime returns how long the last tx has been waiting to be mined
+func (self *StatusMonitor) WaitingTime() time.Duration {
+	return self.clock.Now().Sub(self.lastPush)
+}
+
+func NewStatusMonitor(initTx *types.Transaction, clock clock.Clock, logger *logging.Logger) *StatusMonitor {
 	if initTx == nil {
 		panic("initTx must not be nil")
 	}
+	logger.With("tx", initTx.Hash().Hex(), "nonce", initTx.Nonce(), "gas_price", initTx.GasPrice()).Infof("Monitoring tx %s", initTx.Hash().Hex())
 	return &StatusMonitor{
 		[]*types.Transaction{initTx},
+		clock,
+		clock.Now(),
+		logger,
 	}
 }
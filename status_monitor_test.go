@@ -0,0 +1,36 @@
+package dgxpricing
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+)
+
+func TestGetStatusIsOneRequest(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	h.backend.Chain.SetMinGasPrice(big.NewInt(100 * GWEI))
+	tx, err := h.feeder.TryFeedingPrice()
+	if err != nil {
+		t.Fatalf("Expected to send the feed but got error: %v", err)
+	}
+	monitor := NewStatusMonitor(tx, clock.NewRealClock(), logging.Std())
+	for i := 0; i < 3; i++ {
+		if tx, err = h.feeder.reserve.Replace(context.Background(), tx, bumpGasPrice(tx.GasPrice())); err != nil {
+			t.Fatalf("Expected to replace the feed but got error: %v", err)
+		}
+		monitor.PushTx(tx)
+	}
+
+	before := h.backend.Node.Requests()
+	status, _, err := monitor.GetStatus(context.Background(), h.feeder.reserve)
+	if err != nil || status != "pending" {
+		t.Fatalf("Expected the txs to be pending, got status %s, err %v", status, err)
+	}
+	if requests := h.backend.Node.Requests() - before; requests != 1 {
+		t.Fatalf("Expected statuses of 4 txs in 1 request, got %d", requests)
+	}
+}
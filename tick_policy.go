@@ -0,0 +1,138 @@
+package dgxpricing
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync/atomic"
+
+	"github.com/KyberNetwork/dgx-price-feeder/history"
+	"github.com/KyberNetwork/dgx-price-feeder/tracing"
+)
+
+const (
+	// what to do with a tick which arrives while a feeding cycle is in
+	// flight, ie its tx is not mined yet
+	TICK_POLICY_SKIP      string = "skip"      // drop it
+	TICK_POLICY_SUPERSEDE string = "supersede" // replace the pending feed tx with a newer feed at the same nonce
+	TICK_POLICY_QUEUE     string = "queue"     // run another cycle once the current one is done
+)
+
+// SetTickPolicy sets what to do with ticks arriving while a cycle is in
+// flight, they are skipped by default
+func (self *PriceFeeder) SetTickPolicy(policy string) {
+	switch policy {
+	case TICK_POLICY_SKIP, TICK_POLICY_SUPERSEDE, TICK_POLICY_QUEUE:
+	default:
+		panic(fmt.Sprintf("unsupported tick policy: %s", policy))
+	}
+	self.tickPolicy = policy
+}
+
+// MissedTicks returns the number of ticks which didn't lead to a feed
+// because a cycle was in flight
+func (self *PriceFeeder) MissedTicks() int64 {
+	return atomic.LoadInt64(&self.missedTicks)
+}
+
+func (self *PriceFeeder) missTick(reason string) {
+	missed := atomic.AddInt64(&self.missedTicks, 1)
+	ticksMissed.Inc(1)
+	self.logger.Warnf("Missed a tick, %s (%d missed so far)", reason, missed)
+}
+
+// overlappingTick handles a tick arriving while a cycle is in flight
+// according to the tick policy. queued tells if a cycle is already
+// queued, it returns whether one is queued now.
+func (self *PriceFeeder) overlappingTick(queued bool) bool {
+	switch self.tickPolicy {
+	case TICK_POLICY_QUEUE:
+		if queued {
+			self.missTick("a cycle is already queued")
+		} else {
+			self.logger.Infof("A cycle is in flight, queuing the tick")
+		}
+		return true
+	case TICK_POLICY_SUPERSEDE:
+		if atomic.LoadInt32(&self.supersedable) == 0 {
+			self.missTick("no feed tx is pending to supersede")
+			return queued
+		}
+		select {
+		case self.supersede <- struct{}{}:
+			self.logger.Infof("A feed tx is pending, superseding it with a newer feed")
+		default:
+			self.missTick("superseding the pending feed tx is already requested")
+		}
+		return queued
+	default:
+		self.missTick("a cycle is in flight")
+		return queued
+	}
+}
+
+// startSuperseding lets ticks supersede the feed tx of the cycle until
+// the returned function is called, it returns nil unless the tick policy
+// is supersede
+func (self *PriceFeeder) startSuperseding() (<-chan struct{}, func()) {
+	if self.tickPolicy != TICK_POLICY_SUPERSEDE || self.shadow {
+		return nil, func() {}
+	}
+	// a request left from a previous tx must not supersede this one
+	select {
+	case <-self.supersede:
+	default:
+	}
+	atomic.StoreInt32(&self.supersedable, 1)
+	return self.supersede, func() {
+		atomic.StoreInt32(&self.supersedable, 0)
+	}
+}
+
+// supersedeTx replaces the last tx of monitor with a newer feed at the
+// same nonce, a feed which is not newer than the pending one is not sent
+func (self *PriceFeeder) supersedeTx(ctx context.Context, monitor *StatusMonitor) {
+	last := monitor.Last()
+	ctx, span := tracing.Start(ctx, "tx.supersede", "tx", last.Hash().Hex(), "nonce", last.Nonce())
+	defer span.End()
+	record := &history.Feed{Time: self.clock.Now()}
+	blockno, nonce, ask, bid, v, r, s, err := self.getFeed(ctx, self.prices, record)
+	if err != nil {
+		self.log(ctx).Warnf("Fetching a feed to supersede tx %s failed: %s", last.Hash().Hex(), err)
+		self.recordFeed(ctx, record, history.DECISION_REJECTED, err.Error(), nil)
+		span.SetError(err)
+		return
+	}
+	if self.lastFeedNonce != nil && nonce.Cmp(self.lastFeedNonce) <= 0 {
+		self.log(ctx).Infof("Feed nonce %s is not newer than the pending one, not superseding tx %s", nonce, last.Hash().Hex())
+		self.recordFeed(ctx, record, history.DECISION_SKIPPED, "not newer than the pending feed", nil)
+		return
+	}
+	gasPrice := bumpGasPrice(last.GasPrice())
+	if err := self.checkBudget(ctx, gasPrice, last.Gas().Uint64(), monitor.Replacements()+1); err != nil {
+		self.log(ctx).Warnf("Not superseding tx %s: %s", last.Hash().Hex(), err)
+		self.recordFeed(ctx, record, history.DECISION_REJECTED, err.Error(), nil)
+		span.SetError(err)
+		return
+	}
+	if !self.IsLeader() {
+		self.log(ctx).Warnf("Not the leader anymore, not superseding tx %s", last.Hash().Hex())
+		self.recordFeed(ctx, record, history.DECISION_SKIPPED, "not the leader", nil)
+		return
+	}
+	tx, err := self.reserve.SetPriceFeedAt(ctx, new(big.Int).SetUint64(last.Nonce()), gasPrice, blockno, nonce, ask, bid, v, r, s)
+	if err != nil && (tx == nil || !mightBeInPool(err)) {
+		self.log(ctx).Warnf("Superseding tx %s failed: %s", last.Hash().Hex(), err)
+		self.recordFeed(ctx, record, history.DECISION_REJECTED, err.Error(), nil)
+		span.SetError(err)
+		return
+	}
+	span.SetAttributes("new_tx", tx.Hash().Hex(), "feed_nonce", nonce, "gas_price", gasPrice)
+	self.log(ctx).Infof("Superseded tx %s with tx %s, feed nonce %s, gas price %s", last.Hash().Hex(), tx.Hash().Hex(), nonce, gasPrice)
+	self.record(ctx, "supersede", tx)
+	self.recordFeed(ctx, record, history.DECISION_SUBMITTED, "supersedes "+last.Hash().Hex(), tx)
+	self.lastFeedNonce = nonce
+	txsSuperseded.Inc(1)
+	lastGasPrice.Update(big.NewInt(0).Div(gasPrice, big.NewInt(1000000000)).Int64())
+	monitor.PushTx(tx)
+}
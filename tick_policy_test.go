@@ -0,0 +1,131 @@
+package dgxpricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/clock"
+)
+
+// manualRunner ticks when the test says so
+type manualRunner struct {
+	ticks chan time.Time
+}
+
+func (self *manualRunner) GetPricingTicker() <-chan time.Time {
+	return self.ticks
+}
+
+func (self *manualRunner) Start() error {
+	return nil
+}
+
+func (self *manualRunner) Stop() error {
+	return nil
+}
+
+// runWithTicks starts the feeder loop with policy, nothing is mined
+// until the test mines a block
+func (self *harness) runWithTicks(policy string) chan time.Time {
+	ticks := make(chan time.Time)
+	self.feeder.runner = &manualRunner{ticks}
+	self.feeder.txWaitTime = time.Hour
+	self.feeder.SetTickPolicy(policy)
+	go self.feeder.Run()
+	return ticks
+}
+
+func (self *harness) txsAtNonce(nonce uint64) func() int {
+	return func() int {
+		return len(self.journal.TxsAtNonce(self.backend.Operator(), nonce))
+	}
+}
+
+// newTicksHarness returns a harness whose clock only moves when the
+// test says so, a cycle waiting to poll its tx has sent it
+func newTicksHarness(t *testing.T) (*harness, *clock.FakeClock) {
+	clk := clock.NewFakeClock(time.Unix(1523036543, 0))
+	return newHarnessWithClock(t, STUCK_POLICY_REPORT, clk), clk
+}
+
+func TestSkippedTicksAreMissed(t *testing.T) {
+	h, clk := newTicksHarness(t)
+	defer h.Close()
+	lines := h.logLines()
+	ticks := h.runWithTicks(TICK_POLICY_SKIP)
+	txs := h.txsAtNonce(0)
+	clk.BlockUntil(1)
+	if n := txs(); n != 1 {
+		t.Fatalf("Expected the first feed to be sent, got %d txs", n)
+	}
+
+	ticks <- time.Now()
+	ticks <- time.Now()
+	waitForLog(t, lines, "(2 missed so far)")
+	if missed := h.feeder.MissedTicks(); missed != 2 {
+		t.Fatalf("Expected 2 missed ticks, got %d", missed)
+	}
+	if n := txs(); n != 1 {
+		t.Fatalf("Expected skipped ticks not to send txs, got %d txs", n)
+	}
+}
+
+func TestSupersedeTick(t *testing.T) {
+	h, clk := newTicksHarness(t)
+	defer h.Close()
+	lines := h.logLines()
+	ticks := h.runWithTicks(TICK_POLICY_SUPERSEDE)
+	txs := h.txsAtNonce(0)
+	clk.BlockUntil(1)
+	if n := txs(); n != 1 {
+		t.Fatalf("Expected the first feed to be sent, got %d txs", n)
+	}
+
+	ticks <- time.Now()
+	// the superseding tx is journaled before it is monitored
+	waitForLog(t, lines, "along with 1 previous ones")
+	if n := txs(); n != 2 {
+		t.Fatalf("Expected the feed to be superseded at the same nonce, got %d txs", n)
+	}
+	superseding := h.journal.TxsAtNonce(h.backend.Operator(), 0)[0]
+	for _, tx := range h.journal.TxsAtNonce(h.backend.Operator(), 0) {
+		if tx.GasPrice().Cmp(superseding.GasPrice()) > 0 {
+			superseding = tx
+		}
+	}
+	h.backend.Chain.Mine()
+	if _, _, _, found := h.backend.Chain.Receipt(superseding.Hash()); !found {
+		t.Fatalf("Expected the newer feed to be mined")
+	}
+	if missed := h.feeder.MissedTicks(); missed != 0 {
+		t.Fatalf("Expected no missed tick, got %d", missed)
+	}
+}
+
+func TestQueuedTickFeedsAgain(t *testing.T) {
+	h, clk := newTicksHarness(t)
+	defer h.Close()
+	lines := h.logLines()
+	ticks := h.runWithTicks(TICK_POLICY_QUEUE)
+	clk.BlockUntil(1)
+	if n := h.txsAtNonce(0)(); n != 1 {
+		t.Fatalf("Expected the first feed to be sent, got %d txs", n)
+	}
+
+	ticks <- time.Now()
+	ticks <- time.Now()
+	waitForLog(t, lines, "a cycle is already queued")
+	if missed := h.feeder.MissedTicks(); missed != 1 {
+		t.Fatalf("Expected the second tick to be missed, got %d missed ticks", missed)
+	}
+	if n := h.txsAtNonce(1)(); n != 0 {
+		t.Fatalf("Expected the queued cycle to wait for the first one, got %d txs", n)
+	}
+	// the first cycle ends at its next poll, then the queued one feeds
+	h.backend.Chain.Mine()
+	clk.Advance(h.feeder.pollInterval)
+	clk.BlockUntil(1)
+	if n := h.txsAtNonce(1)(); n != 1 {
+		t.Fatalf("Expected the queued cycle to feed once the first feed is mined, got %d txs", n)
+	}
+}
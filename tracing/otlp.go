@@ -0,0 +1,173 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// OTLP_PATH is where OTLP/HTTP collectors receive traces
+	OTLP_PATH string = "/v1/traces"
+	// EXPORT_TIMEOUT is how long the collector has to accept a batch
+	EXPORT_TIMEOUT time.Duration = 10 * time.Second
+)
+
+// The types below are the OTLP/JSON encoding of an
+// ExportTraceServiceRequest, ids are hex and times are nanoseconds since
+// the epoch as strings.
+
+type Request struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type ScopeSpans struct {
+	Scope Scope      `json:"scope"`
+	Spans []SpanData `json:"spans"`
+}
+
+type Scope struct {
+	Name string `json:"name"`
+}
+
+type SpanData struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Status            Status     `json:"status"`
+}
+
+// Attribute returns the value of the attribute key as a string, empty if
+// the span doesn't have it
+func (self SpanData) Attribute(key string) string {
+	for _, kv := range self.Attributes {
+		if kv.Key == key {
+			return kv.Value.String()
+		}
+	}
+	return ""
+}
+
+type Status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (self AnyValue) String() string {
+	switch {
+	case self.StringValue != nil:
+		return *self.StringValue
+	case self.BoolValue != nil:
+		return fmt.Sprint(*self.BoolValue)
+	case self.IntValue != nil:
+		return *self.IntValue
+	case self.DoubleValue != nil:
+		return fmt.Sprint(*self.DoubleValue)
+	}
+	return ""
+}
+
+// NewRequest returns the export request of spans of service
+func NewRequest(service string, spans []SpanData) *Request {
+	return &Request{[]ResourceSpans{{
+		Resource:   Resource{attributes([]interface{}{"service.name", service})},
+		ScopeSpans: []ScopeSpans{{Scope{"github.com/KyberNetwork/dgx-price-feeder"}, spans}},
+	}}}
+}
+
+// OTLPExporter posts spans to an OTLP/HTTP collector with the JSON
+// encoding
+type OTLPExporter struct {
+	url    string
+	client *http.Client
+}
+
+func (self *OTLPExporter) Export(service string, spans []SpanData) error {
+	data, err := json.Marshal(NewRequest(service, spans))
+	if err != nil {
+		return err
+	}
+	resp, err := self.client.Post(self.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New(fmt.Sprintf("collector %s answered %s: %s", self.url, resp.Status, strings.TrimSpace(string(body))))
+	}
+	return nil
+}
+
+// NewOTLPExporter returns an exporter to the collector at endpoint, ie
+// http://localhost:4318. Traces are sent to /v1/traces unless endpoint
+// has a path.
+func NewOTLPExporter(endpoint string) (*OTLPExporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New(fmt.Sprintf("invalid collector endpoint %s, expected http(s)://host:port", endpoint))
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = OTLP_PATH
+	}
+	return &OTLPExporter{u.String(), &http.Client{Timeout: EXPORT_TIMEOUT}}, nil
+}
+
+// WriterExporter writes every span as a json line, ie to stdout
+type WriterExporter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (self *WriterExporter) Export(service string, spans []SpanData) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	encoder := json.NewEncoder(self.out)
+	for _, span := range spans {
+		if err := encoder.Encode(struct {
+			Service string `json:"service"`
+			SpanData
+		}{service, span}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewWriterExporter(out io.Writer) *WriterExporter {
+	return &WriterExporter{out: out}
+}
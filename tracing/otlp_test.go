@@ -0,0 +1,190 @@
+package tracing_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"regexp"
+	"testing"
+
+	"github.com/KyberNetwork/dgx-price-feeder/tracing"
+)
+
+// testdata/trace.json is an ExportTraceServiceRequest as OTLP/HTTP
+// clients post it, after the example of the opentelemetry-proto
+// repository with a span of every kind of value and status the feeder
+// exports
+
+func readFixture(t *testing.T) []byte {
+	data, err := ioutil.ReadFile("testdata/trace.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// jsonType names the json type of v as decoded in an interface{}
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	}
+	return "null"
+}
+
+// fields adds the path of every field of v to result with its json type,
+// items of arrays share the path of the array
+func fields(path string, v interface{}, result map[string]string) {
+	result[path] = jsonType(v)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			fields(path+"."+key, value, result)
+		}
+	case []interface{}:
+		for _, item := range v {
+			fields(path+"[]", item, result)
+		}
+	}
+}
+
+func fieldsOf(t *testing.T, data []byte) map[string]string {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]string{}
+	fields("", v, result)
+	return result
+}
+
+func exportedRequest(t *testing.T) []byte {
+	exporter := &recordingExporter{}
+	tracer := tracing.NewTracer("feeder", exporter)
+	root := tracer.Start("feed.cycle", "cycle", "abc", "shadow", false)
+	fetch := root.Start("feed.fetch", "bytes", 512, "ratio", 0.5, "gas_price", big.NewInt(4000000000), "nonce", uint64(7))
+	fetch.SetClient()
+	fetch.SetError(errors.New("timeout"))
+	fetch.End()
+	root.End()
+	if err := tracer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(tracing.NewRequest(exporter.service, exporter.spans))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+type recordingExporter struct {
+	service string
+	spans   []tracing.SpanData
+}
+
+func (self *recordingExporter) Export(service string, spans []tracing.SpanData) error {
+	self.service = service
+	self.spans = append(self.spans, spans...)
+	return nil
+}
+
+func TestRequestHasTheFieldsOfOTLP(t *testing.T) {
+	expected := fieldsOf(t, readFixture(t))
+	got := fieldsOf(t, exportedRequest(t))
+	for path, kind := range got {
+		if expected[path] == "" {
+			t.Errorf("Expected %s not to be exported, OTLP has no such field", path)
+		} else if expected[path] != kind {
+			t.Errorf("Expected %s to be a json %s, got %s", path, expected[path], kind)
+		}
+	}
+	for _, path := range []string{
+		".resourceSpans[].resource.attributes[].value.stringValue",
+		".resourceSpans[].scopeSpans[].scope.name",
+		".resourceSpans[].scopeSpans[].spans[].traceId",
+		".resourceSpans[].scopeSpans[].spans[].spanId",
+		".resourceSpans[].scopeSpans[].spans[].parentSpanId",
+		".resourceSpans[].scopeSpans[].spans[].kind",
+		".resourceSpans[].scopeSpans[].spans[].startTimeUnixNano",
+		".resourceSpans[].scopeSpans[].spans[].endTimeUnixNano",
+		".resourceSpans[].scopeSpans[].spans[].attributes[].value.boolValue",
+		".resourceSpans[].scopeSpans[].spans[].attributes[].value.intValue",
+		".resourceSpans[].scopeSpans[].spans[].attributes[].value.doubleValue",
+		".resourceSpans[].scopeSpans[].spans[].status.code",
+		".resourceSpans[].scopeSpans[].spans[].status.message",
+	} {
+		if got[path] == "" {
+			t.Errorf("Expected %s to be exported", path)
+		}
+	}
+}
+
+func TestRequestEncodesIDsAndIntegersAsOTLP(t *testing.T) {
+	request := struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []map[string]interface{} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}{}
+	if err := json.Unmarshal(exportedRequest(t), &request); err != nil {
+		t.Fatal(err)
+	}
+	// ids are hex, not base64 as protobuf bytes are in json, and 64 bits
+	// integers are decimal strings
+	traceID := regexp.MustCompile("^[0-9a-f]{32}$")
+	spanID := regexp.MustCompile("^[0-9a-f]{16}$")
+	decimal := regexp.MustCompile("^[0-9]+$")
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if id, _ := span["traceId"].(string); !traceID.MatchString(id) {
+			t.Errorf("Expected a 16 bytes hex trace id, got %v", span["traceId"])
+		}
+		if id, _ := span["spanId"].(string); !spanID.MatchString(id) {
+			t.Errorf("Expected a 8 bytes hex span id, got %v", span["spanId"])
+		}
+		if id, ok := span["parentSpanId"].(string); ok && !spanID.MatchString(id) {
+			t.Errorf("Expected a 8 bytes hex parent span id, got %v", id)
+		}
+		for _, key := range []string{"startTimeUnixNano", "endTimeUnixNano"} {
+			if time, _ := span[key].(string); !decimal.MatchString(time) {
+				t.Errorf("Expected %s to be a decimal string, got %v", key, span[key])
+			}
+		}
+	}
+}
+
+func TestCollectorPayloadDecodes(t *testing.T) {
+	request := tracing.Request{}
+	if err := json.Unmarshal(readFixture(t), &request); err != nil {
+		t.Fatal(err)
+	}
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	server, client := spans[0], spans[1]
+	if client.ParentSpanID != server.SpanID || client.TraceID != server.TraceID {
+		t.Fatalf("Expected the client span to be a child of the server span, got %+v and %+v", server, client)
+	}
+	if client.Kind != tracing.KIND_CLIENT || client.Status.Code != tracing.STATUS_ERROR || client.Status.Message != "service unavailable" {
+		t.Fatalf("Expected a failed client span, got kind %d and status %+v", client.Kind, client.Status)
+	}
+	for key, value := range map[string]string{"http.url": "http://localhost:8545", "retry": "true", "http.status_code": "503", "sample.ratio": "0.25"} {
+		if got := client.Attribute(key); got != value {
+			t.Errorf("Expected attribute %s to be %s, got %q", key, value, got)
+		}
+	}
+}
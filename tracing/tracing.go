@@ -0,0 +1,276 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/logging"
+)
+
+const (
+	// MAX_QUEUE is the number of ended spans kept until they are
+	// exported, spans ended while the queue is full are dropped
+	MAX_QUEUE int = 4096
+
+	// span status codes of OTLP
+	STATUS_UNSET int = 0
+	STATUS_OK    int = 1
+	STATUS_ERROR int = 2
+
+	// span kinds of OTLP
+	KIND_INTERNAL int = 1
+	KIND_CLIENT   int = 3
+)
+
+// Exporter sends ended spans to a collector
+type Exporter interface {
+	Export(service string, spans []SpanData) error
+}
+
+// Tracer starts spans and exports them in batches.
+// Tracer is thread safe.
+type Tracer struct {
+	service  string
+	exporter Exporter
+
+	mu      sync.Mutex
+	queue   []SpanData
+	dropped int
+}
+
+// Span is an operation of a trace. Methods of a nil span do nothing so
+// code can be traced whether a tracer is set or not.
+// Span is thread safe.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+func newID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// attributes converts key value pairs to OTLP attributes, big numbers
+// are kept as strings
+func attributes(kv []interface{}) []KeyValue {
+	result := []KeyValue{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		value := AnyValue{}
+		switch v := kv[i+1].(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int:
+			str := strconv.FormatInt(int64(v), 10)
+			value.IntValue = &str
+		case int64:
+			str := strconv.FormatInt(v, 10)
+			value.IntValue = &str
+		case uint64:
+			str := strconv.FormatUint(v, 10)
+			value.IntValue = &str
+		case float64:
+			value.DoubleValue = &v
+		case *big.Int:
+			str := "<nil>"
+			if v != nil {
+				str = v.String()
+			}
+			value.StringValue = &str
+		case error:
+			str := v.Error()
+			value.StringValue = &str
+		case fmt.Stringer:
+			str := v.String()
+			value.StringValue = &str
+		default:
+			str := fmt.Sprint(v)
+			value.StringValue = &str
+		}
+		result = append(result, KeyValue{fmt.Sprint(kv[i]), value})
+	}
+	return result
+}
+
+func (self *Tracer) start(traceID string, parentID string, name string, kind int, start time.Time, kv []interface{}) *Span {
+	if self == nil {
+		return nil
+	}
+	return &Span{
+		tracer: self,
+		data: SpanData{
+			TraceID:           traceID,
+			SpanID:            newID(8),
+			ParentSpanID:      parentID,
+			Name:              name,
+			Kind:              kind,
+			StartTimeUnixNano: unixNano(start),
+			Attributes:        attributes(kv),
+		},
+	}
+}
+
+// Start starts a root span, ie a new trace, with the key value pairs kv
+// as attributes. It returns nil on a nil tracer.
+func (self *Tracer) Start(name string, kv ...interface{}) *Span {
+	return self.start(newID(16), "", name, KIND_INTERNAL, time.Now(), kv)
+}
+
+// Flush exports the spans ended so far
+func (self *Tracer) Flush() error {
+	if self == nil {
+		return nil
+	}
+	self.mu.Lock()
+	spans := self.queue
+	self.queue = nil
+	dropped := self.dropped
+	self.dropped = 0
+	self.mu.Unlock()
+	if dropped > 0 {
+		logging.Std().Warnf("Tracing: dropped %d spans, the exporter is too slow", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	return self.exporter.Export(self.service, spans)
+}
+
+// Run exports ended spans every interval, it never returns
+func (self *Tracer) Run(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := self.Flush(); err != nil {
+			logging.Std().Warnf("Tracing: exporting spans failed: %s", err)
+		}
+	}
+}
+
+func (self *Tracer) enqueue(data SpanData) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if len(self.queue) >= MAX_QUEUE {
+		self.dropped++
+		return
+	}
+	self.queue = append(self.queue, data)
+}
+
+// Start starts a child span of self with the key value pairs kv as
+// attributes
+func (self *Span) Start(name string, kv ...interface{}) *Span {
+	return self.StartAt(name, time.Now(), kv...)
+}
+
+// StartAt starts a child span of self which started at start, ie an
+// operation which is traced once it is done
+func (self *Span) StartAt(name string, start time.Time, kv ...interface{}) *Span {
+	if self == nil {
+		return nil
+	}
+	return self.tracer.start(self.data.TraceID, self.data.SpanID, name, KIND_INTERNAL, start, kv)
+}
+
+// SetClient marks the span as a call to a remote service, ie an rpc
+// endpoint
+func (self *Span) SetClient() {
+	if self == nil {
+		return
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.data.Kind = KIND_CLIENT
+}
+
+// SetAttributes adds the key value pairs kv to the attributes of the span
+func (self *Span) SetAttributes(kv ...interface{}) {
+	if self == nil {
+		return
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.data.Attributes = append(self.data.Attributes, attributes(kv)...)
+}
+
+// SetError marks the span as failed with err, nil errors are ignored
+func (self *Span) SetError(err error) {
+	if self == nil || err == nil {
+		return
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.data.Status = Status{STATUS_ERROR, err.Error()}
+}
+
+// TraceID returns the hex ID of the trace of the span, empty for a nil
+// span
+func (self *Span) TraceID() string {
+	if self == nil {
+		return ""
+	}
+	return self.data.TraceID
+}
+
+// End ends the span and queues it for export, ending it again does
+// nothing
+func (self *Span) End() {
+	self.EndAt(time.Now())
+}
+
+// EndAt is End for an operation which ended at end
+func (self *Span) EndAt(end time.Time) {
+	if self == nil {
+		return
+	}
+	self.mu.Lock()
+	if self.ended {
+		self.mu.Unlock()
+		return
+	}
+	self.ended = true
+	self.data.EndTimeUnixNano = unixNano(end)
+	data := self.data
+	self.mu.Unlock()
+	self.tracer.enqueue(data)
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying span, ie the span of the
+// operation components work for, their own spans are started under it
+func NewContext(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, contextKey{}, span)
+}
+
+// FromContext returns the span ctx carries, nil if it carries none
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(contextKey{}).(*Span)
+	return span
+}
+
+// Start starts a child span of the span ctx carries and returns it with a
+// copy of ctx carrying it. The span is nil if ctx carries none.
+func Start(ctx context.Context, name string, kv ...interface{}) (context.Context, *Span) {
+	span := FromContext(ctx).Start(name, kv...)
+	return NewContext(ctx, span), span
+}
+
+// NewTracer returns a tracer exporting spans of service with exporter,
+// spans are exported by Flush or Run
+func NewTracer(service string, exporter Exporter) *Tracer {
+	return &Tracer{service: service, exporter: exporter}
+}
@@ -0,0 +1,70 @@
+package tracing_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	"github.com/KyberNetwork/dgx-price-feeder/tracing"
+)
+
+func TestExportToCollector(t *testing.T) {
+	collector := simulation.NewCollector()
+	defer collector.Close()
+	exporter, err := tracing.NewOTLPExporter(collector.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := tracing.NewTracer("test", exporter)
+	root := tracer.Start("cycle", "cycle", "abc")
+	child := root.Start("tx.sign", "nonce", uint64(7), "gas_price", big.NewInt(4000000000))
+	child.SetError(errors.New("locked"))
+	child.End()
+	root.End()
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Expected the collector to accept the spans, got %s", err)
+	}
+
+	spans := collector.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	sign, cycle := spans[0], spans[1]
+	if sign.TraceID != cycle.TraceID || sign.ParentSpanID != cycle.SpanID || cycle.ParentSpanID != "" {
+		t.Fatalf("Expected tx.sign to be a child of cycle, got %+v and %+v", sign, cycle)
+	}
+	if sign.Attribute("nonce") != "7" || sign.Attribute("gas_price") != "4000000000" {
+		t.Fatalf("Expected the attributes of tx.sign, got %+v", sign.Attributes)
+	}
+	if sign.Status.Code != tracing.STATUS_ERROR || sign.Status.Message != "locked" {
+		t.Fatalf("Expected tx.sign to fail, got %+v", sign.Status)
+	}
+	if services := collector.Services(); len(services) != 1 || services[0] != "test" {
+		t.Fatalf("Expected the spans of service test, got %v", services)
+	}
+}
+
+func TestWriterExporterAndNilSpans(t *testing.T) {
+	out := &bytes.Buffer{}
+	tracer := tracing.NewTracer("test", tracing.NewWriterExporter(out))
+	tracer.Start("cycle").End()
+	if err := tracer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	line := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil || line["service"] != "test" || line["name"] != "cycle" {
+		t.Fatalf("Expected a json line of the cycle span, got %q", out.String())
+	}
+
+	// components trace whether a tracer is set or not
+	var none *tracing.Tracer
+	span := none.Start("cycle")
+	span.Start("child").End()
+	span.End()
+	if err := none.Flush(); err != nil || span.TraceID() != "" {
+		t.Fatalf("Expected a nil tracer to do nothing")
+	}
+}
@@ -0,0 +1,62 @@
+package dgxpricing
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/KyberNetwork/dgx-price-feeder/simulation"
+	"github.com/KyberNetwork/dgx-price-feeder/tracing"
+)
+
+func TestCycleIsOneTrace(t *testing.T) {
+	h := newHarness(t, STUCK_POLICY_REPORT)
+	defer h.Close()
+	collector := simulation.NewCollector()
+	defer collector.Close()
+	exporter, err := tracing.NewOTLPExporter(collector.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer := tracing.NewTracer("feeder-test", exporter)
+	h.feeder.SetTracer(tracer)
+	// the first tx is only mined once it is replaced
+	h.backend.Chain.SetMinGasPrice(big.NewInt(10 * GWEI))
+	h.backend.Chain.StartMining(50 * time.Millisecond)
+
+	tx, status, err := wait(t, h.feeder.FeedOnce)
+	if err != nil || status != "mined" {
+		t.Fatalf("Expected the feed to be mined, got status %s, err %v", status, err)
+	}
+	if err := tracer.Flush(); err != nil {
+		t.Fatalf("Expected the spans to be exported, got %s", err)
+	}
+
+	spans := map[string][]tracing.SpanData{}
+	var root tracing.SpanData
+	for _, span := range collector.Spans() {
+		spans[span.Name] = append(spans[span.Name], span)
+		if span.ParentSpanID == "" {
+			root = span
+		}
+	}
+	if root.Name != "feed.cycle" || len(spans["feed.cycle"]) != 1 {
+		t.Fatalf("Expected one feed.cycle root span, got %v", spans["feed.cycle"])
+	}
+	for _, name := range []string{"feed.fetch", "feed.validate", "tx.build", "tx.sign", "tx.broadcast", "tx.broadcast.endpoint", "tx.monitor", "tx.status", "tx.replace"} {
+		if len(spans[name]) == 0 {
+			t.Fatalf("Expected %s spans, got none", name)
+		}
+		for _, span := range spans[name] {
+			if span.TraceID != root.TraceID {
+				t.Fatalf("Expected %s to be in the trace of the cycle", name)
+			}
+		}
+	}
+	if got := spans["tx.monitor"][0].Attribute("final_tx"); got != tx.Hash().Hex() {
+		t.Fatalf("Expected the monitor span to carry the mined tx %s, got %s", tx.Hash().Hex(), got)
+	}
+	if got := spans["tx.replace"][0].Attribute("gas_price"); got == "" {
+		t.Fatalf("Expected the replacement span to carry its gas price")
+	}
+}